@@ -8,16 +8,20 @@ type config struct {
 	RendezvousString string
 	ProtocolID       string
 	listenHost       string
+	listenHost6      string
 	listenPort       int
+	hostConfigFile   string
 }
 
 func parseFlags() *config {
 	c := &config{}
 
 	flag.StringVar(&c.RendezvousString, "rendezvous", "meetme", "Unique string to identify group of nodes. Share this with your friends to let them connect with you")
-	flag.StringVar(&c.listenHost, "host", "0.0.0.0", "The bootstrap node host listen address\n")
+	flag.StringVar(&c.listenHost, "host", "0.0.0.0", "The IPv4 address to listen on")
+	flag.StringVar(&c.listenHost6, "host6", "::", "The IPv6 address to listen on, empty to not use IPv6")
 	flag.StringVar(&c.ProtocolID, "pid", "/chat/1.1.0", "Sets a protocol id for stream headers")
-	flag.IntVar(&c.listenPort, "port", 4001, "node listen port")
+	flag.IntVar(&c.listenPort, "port", 0, "node listen port, 0 picks a free one")
+	flag.StringVar(&c.hostConfigFile, "config", "", "Build the host from this configuration file, YAML or JSON. -host, -host6 and -port are used if it has no listen addresses")
 
 	flag.Parse()
 	return c
@@ -3,72 +3,137 @@ package main
 import (
 	"bufio"
 	"context"
-	"crypto/rand"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
-	"github.com/libp2p/go-libp2p"
-	"github.com/libp2p/go-libp2p-core/crypto"
-	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/libp2p/go-libp2p-examples/chatproto"
+	"github.com/libp2p/go-libp2p-examples/chatroom"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
 
 	"github.com/multiformats/go-multiaddr"
 )
 
-func handleStream(stream network.Stream) {
-	fmt.Println("Got a new stream!")
+// listenIPv6 makes h listen on the IPv6 address ip too, on the same port as
+// its IPv4 listener. mDNS announces a single port for all addresses, so
+// using different ports would make the IPv6 addresses undialable.
+func listenIPv6(h host.Host, ip string) error {
+	var port string
+	for _, la := range h.Network().ListenAddresses() {
+		if p, err := la.ValueForProtocol(multiaddr.P_TCP); err == nil {
+			port = p
+			break
+		}
+	}
+	if port == "" {
+		return fmt.Errorf("no TCP listener")
+	}
+
+	addr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip6/%s/tcp/%s", ip, port))
+	if err != nil {
+		return err
+	}
+	return h.Network().Listen(addr)
+}
 
-	// Create a buffer stream for non blocking read and write.
-	rw := bufio.NewReadWriter(bufio.NewReader(stream), bufio.NewWriter(stream))
+// handleStream adds a chat stream to the room, and prints what the peer
+// sends until it leaves.
+func handleStream(r *chatroom.Room, c *chatproto.Conn) {
+	if !r.Join(c) {
+		// We already talk to this peer over another stream.
+		return
+	}
+	fmt.Printf("\nChatting with %s\n> ", c.RemotePeer().Pretty())
 
-	go readData(rw)
-	go writeData(rw)
+	go readData(r, c)
 
 	// 'stream' will stay open until you close it (or the other side closes it).
 }
 
-func readData(rw *bufio.ReadWriter) {
+func readData(r *chatroom.Room, c *chatproto.Conn) {
 	for {
-		str, err := rw.ReadString('\n')
+		msg, err := c.Receive()
 		if err != nil {
-			fmt.Println("Error reading from buffer")
-			panic(err)
-		}
-
-		if str == "" {
+			// The peer hung up, or the connection broke. Either way
+			// there is nothing left to read.
+			if !r.Leave(c) {
+				// Replaced by another stream to the same peer.
+				return
+			}
+			if err == io.EOF {
+				fmt.Printf("\n%s left the chat\n> ", c.RemotePeer().Pretty())
+			} else {
+				fmt.Printf("\nLost connection to %s: %s\n> ", c.RemotePeer().Pretty(), err)
+			}
 			return
 		}
-		if str != "\n" {
-			// Green console colour: 	\x1b[32m
-			// Reset console colour: 	\x1b[0m
-			fmt.Printf("\x1b[32m%s\x1b[0m> ", str)
-		}
 
+		if msg.Ack {
+			continue
+		}
+		// Green console colour: 	\x1b[32m
+		// Reset console colour: 	\x1b[0m
+		fmt.Printf("\x1b[32m%s\x1b[0m\n> ", msg.Body)
 	}
 }
 
-func writeData(rw *bufio.ReadWriter) {
+// writeData sends every line typed to all peers in the room, until stdin is
+// closed. It is the only reader of stdin, however many peers there are.
+func writeData(r *chatroom.Room) {
 	stdReader := bufio.NewReader(os.Stdin)
 
 	for {
 		fmt.Print("> ")
 		sendData, err := stdReader.ReadString('\n')
+		if err == io.EOF {
+			// Ctrl-D, say goodbye.
+			r.Close()
+			fmt.Println()
+			return
+		}
 		if err != nil {
 			fmt.Println("Error reading from stdin")
 			panic(err)
 		}
 
-		_, err = rw.WriteString(fmt.Sprintf("%s\n", sendData))
-		if err != nil {
-			fmt.Println("Error writing to buffer")
-			panic(err)
+		sent, errs := r.Broadcast(strings.TrimRight(sendData, "\r\n"))
+		if sent == 0 && len(errs) == 0 {
+			fmt.Println("No peers found yet, message not sent")
+		}
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+	}
+}
+
+// connectPeers opens a chat stream to every peer mDNS finds, for as long as
+// the program runs. Peers we already talk to are skipped, they show up again
+// on every mDNS query.
+func connectPeers(ctx context.Context, h host.Host, r *chatroom.Room, pid protocol.ID, peerChan <-chan peer.AddrInfo) {
+	for pi := range peerChan {
+		if pi.ID == h.ID() || r.Has(pi.ID) {
+			continue
+		}
+		fmt.Printf("\nFound peer: %s, connecting\n> ", pi)
+
+		if err := h.Connect(ctx, pi); err != nil {
+			fmt.Printf("\nConnection to %s failed: %s\n> ", pi.ID.Pretty(), err)
+			continue
 		}
-		err = rw.Flush()
+
+		// open a stream, this stream will be handled by handleStream other end
+		// Peers that support it speak /chat/2.0.0, older ones the -pid protocol.
+		c, err := chatproto.NewStream(ctx, h, pi.ID, pid)
 		if err != nil {
-			fmt.Println("Error flushing buffer")
-			panic(err)
+			fmt.Printf("\nStream open failed: %s\n> ", err)
+			continue
 		}
+		handleStream(r, c)
 	}
 }
 
@@ -78,64 +143,66 @@ func main() {
 
 	if *help {
 		fmt.Printf("Simple example for peer discovery using mDNS. mDNS is great when you have multiple peers in local LAN.")
-		fmt.Printf("Usage: \n   Run './chat-with-mdns'\nor Run './chat-with-mdns -host [host] -port [port] -rendezvous [string] -pid [proto ID]'\n")
+		fmt.Printf("Usage: \n   Run './chat-with-mdns'\nor Run './chat-with-mdns -host [host] -host6 [host] -port [port] -rendezvous [string] -pid [proto ID] -config [file]'\n")
 
 		os.Exit(0)
 	}
 
-	fmt.Printf("[*] Listening on: %s with port: %d\n", cfg.listenHost, cfg.listenPort)
-
 	ctx := context.Background()
-	r := rand.Reader
 
-	// Creates a new RSA key pair for this host.
-	prvKey, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, r)
-	if err != nil {
-		panic(err)
+	// Without a configuration file, the host gets a new RSA key pair.
+	hcfg := &hostconfig.Config{Identity: hostconfig.Identity{Type: "rsa"}}
+	if cfg.hostConfigFile != "" {
+		var err error
+		if hcfg, err = hostconfig.Load(cfg.hostConfigFile); err != nil {
+			panic(err)
+		}
 	}
 
-	// 0.0.0.0 will listen on any interface device.
-	sourceMultiAddr, _ := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d", cfg.listenHost, cfg.listenPort))
-
-	// libp2p.New constructs a new libp2p Host.
-	// Other options can be added here.
-	host, err := libp2p.New(
-		ctx,
-		libp2p.ListenAddrs(sourceMultiAddr),
-		libp2p.Identity(prvKey),
-	)
+	// 0.0.0.0 will listen on any interface device. Port 0 picks a free
+	// port, so several instances can run on the same machine. The IPv6
+	// listener is only added to these defaults; a configuration file that
+	// lists its own addresses gets exactly those.
+	listen6 := len(hcfg.ListenAddrs) == 0 && cfg.listenHost6 != ""
+	if len(hcfg.ListenAddrs) == 0 {
+		hcfg.ListenAddrs = []string{fmt.Sprintf("/ip4/%s/tcp/%d", cfg.listenHost, cfg.listenPort)}
+	}
 
+	// hostconfig.New constructs a new libp2p Host.
+	// Other options can be set in the configuration file.
+	host, err := hostconfig.New(ctx, hcfg)
 	if err != nil {
 		panic(err)
 	}
 
+	if listen6 {
+		if err := listenIPv6(host, cfg.listenHost6); err != nil {
+			fmt.Println("Not listening on IPv6:", err)
+		}
+	}
+
 	// Set a function as stream handler.
 	// This function is called when a peer initiates a connection and starts a stream with this peer.
-	host.SetStreamHandler(protocol.ID(cfg.ProtocolID), handleStream)
-
-	fmt.Printf("\n[*] Your Multiaddress Is: /ip4/%s/tcp/%v/p2p/%s\n", cfg.listenHost, cfg.listenPort, host.ID().Pretty())
-
-	peerChan := initMDNS(ctx, host, cfg.RendezvousString)
-
-	peer := <-peerChan // will block untill we discover a peer
-	fmt.Println("Found peer:", peer, ", connecting")
-
-	if err := host.Connect(ctx, peer); err != nil {
-		fmt.Println("Connection failed:", err)
+	// The -pid protocol is the newline delimited legacy protocol, /chat/2.0.0
+	// is accepted as well.
+	chat := chatroom.New(host.ID())
+	chatproto.SetStreamHandler(host, protocol.ID(cfg.ProtocolID), func(c *chatproto.Conn) {
+		handleStream(chat, c)
+	})
+
+	// host.Addrs() resolves unspecified listen addresses like 0.0.0.0 to
+	// the addresses of all interfaces, and has the actual port. These are
+	// the addresses other peers can dial, and the ones mDNS announces.
+	fmt.Println("[*] Your Multiaddresses are:")
+	for _, a := range host.Addrs() {
+		fmt.Printf("    %s/p2p/%s\n", a, host.ID().Pretty())
 	}
+	fmt.Println()
 
-	// open a stream, this stream will be handled by handleStream other end
-	stream, err := host.NewStream(ctx, peer.ID, protocol.ID(cfg.ProtocolID))
-
-	if err != nil {
-		fmt.Println("Stream open failed", err)
-	} else {
-		rw := bufio.NewReadWriter(bufio.NewReader(stream), bufio.NewWriter(stream))
-
-		go writeData(rw)
-		go readData(rw)
-		fmt.Println("Connected to:", peer)
-	}
+	peerChan := initMDNS(ctx, host, cfg.RendezvousString)
 
-	select {} //wait here
+	// Connect to peers as they are found, and chat with all of them.
+	go connectPeers(ctx, host, chat, protocol.ID(cfg.ProtocolID), peerChan)
+	writeData(chat)
+	host.Close()
 }
@@ -9,26 +9,31 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/discovery"
 )
 
+// queryInterval is the time between two mDNS queries.
+const queryInterval = 10 * time.Second
+
 type discoveryNotifee struct {
 	PeerChan chan peer.AddrInfo
 }
 
 //interface to be called when new  peer is found
+//it is called for every peer on every query, from its own goroutine
 func (n *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
 	n.PeerChan <- pi
 }
 
 //Initialize the MDNS service
 func initMDNS(ctx context.Context, peerhost host.Host, rendezvous string) chan peer.AddrInfo {
-	// An hour might be a long long period in practical applications. But this is fine for us
-	ser, err := discovery.NewMdnsService(ctx, peerhost, time.Hour, rendezvous)
+	// Query the network every few seconds, so that peers joining late, or
+	// coming back after they left, are found quickly.
+	ser, err := discovery.NewMdnsService(ctx, peerhost, queryInterval, rendezvous)
 	if err != nil {
 		panic(err)
 	}
 
 	//register with service so that we get notified about peer discovery
 	n := &discoveryNotifee{}
-	n.PeerChan = make(chan peer.AddrInfo)
+	n.PeerChan = make(chan peer.AddrInfo, 16)
 
 	ser.RegisterNotifee(n)
 	return n.PeerChan
@@ -5,14 +5,21 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
 	"github.com/libp2p/go-libp2p-core/protocol"
 	"github.com/libp2p/go-libp2p-discovery"
+	"github.com/libp2p/go-libp2p-examples/chatproto"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+	"github.com/libp2p/go-libp2p-examples/rendezvous"
 
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	multiaddr "github.com/multiformats/go-multiaddr"
@@ -22,59 +29,87 @@ import (
 
 var logger = log.Logger("rendezvous")
 
-func handleStream(stream network.Stream) {
-	logger.Info("Got a new stream!")
+// accept checks that the peer of a new chat stream is a member of the group,
+// and starts chatting with it if so.
+func accept(t *peerTable, g *group, c *chatproto.Conn) bool {
+	if err := g.admit(c); err != nil {
+		c.Reset()
+		if t.reject(c.RemotePeer(), err) {
+			logger.Warning("Rejected ", c.RemotePeer(), ", it doesn't prove it knows the secret: ", err)
+		}
+		return false
+	}
+	return handleStream(t, c)
+}
 
-	// Create a buffer stream for non blocking read and write.
-	rw := bufio.NewReadWriter(bufio.NewReader(stream), bufio.NewWriter(stream))
+// handleStream adds a chat stream to the peer table and prints what the peer
+// sends until it leaves. It reports whether the stream was kept, streams to
+// peers we already talk to are closed.
+func handleStream(t *peerTable, c *chatproto.Conn) bool {
+	if !t.join(c) {
+		return false
+	}
+	logger.Info("Chatting with ", c.RemotePeer(), " over ", c.Stream().Protocol())
 
-	go readData(rw)
-	go writeData(rw)
+	go readData(t, c)
 
 	// 'stream' will stay open until you close it (or the other side closes it).
+	return true
 }
 
-func readData(rw *bufio.ReadWriter) {
+func readData(t *peerTable, c *chatproto.Conn) {
 	for {
-		str, err := rw.ReadString('\n')
+		msg, err := c.Receive()
 		if err != nil {
-			fmt.Println("Error reading from buffer")
-			panic(err)
-		}
-
-		if str == "" {
+			// The peer hung up, or the connection broke. Either way
+			// there is nothing left to read.
+			if !t.leave(c) {
+				// Replaced by another stream to the same peer.
+				return
+			}
+			if err == io.EOF {
+				fmt.Printf("\n%s left the chat\n> ", c.RemotePeer().Pretty())
+			} else {
+				fmt.Printf("\nLost connection to %s: %s\n> ", c.RemotePeer().Pretty(), err)
+			}
 			return
 		}
-		if str != "\n" {
-			// Green console colour: 	\x1b[32m
-			// Reset console colour: 	\x1b[0m
-			fmt.Printf("\x1b[32m%s\x1b[0m> ", str)
-		}
 
+		if msg.Ack {
+			continue
+		}
+		// Green console colour: 	\x1b[32m
+		// Reset console colour: 	\x1b[0m
+		fmt.Printf("\x1b[32m%s\x1b[0m\n> ", msg.Body)
 	}
 }
 
-func writeData(rw *bufio.ReadWriter) {
+// writeData sends every line typed to all peers, until stdin is closed. It is
+// the only reader of stdin, however many peers there are. The /peers command
+// lists the members of the rendezvous group.
+func writeData(t *peerTable) {
 	stdReader := bufio.NewReader(os.Stdin)
 
 	for {
 		fmt.Print("> ")
 		sendData, err := stdReader.ReadString('\n')
+		if err == io.EOF {
+			// Ctrl-D, say goodbye.
+			t.close()
+			fmt.Println()
+			return
+		}
 		if err != nil {
 			fmt.Println("Error reading from stdin")
 			panic(err)
 		}
 
-		_, err = rw.WriteString(fmt.Sprintf("%s\n", sendData))
-		if err != nil {
-			fmt.Println("Error writing to buffer")
-			panic(err)
-		}
-		err = rw.Flush()
-		if err != nil {
-			fmt.Println("Error flushing buffer")
-			panic(err)
+		sendData = strings.TrimRight(sendData, "\r\n")
+		if sendData == "/peers" {
+			t.print()
+			continue
 		}
+		t.broadcast(sendData)
 	}
 }
 
@@ -97,11 +132,35 @@ func main() {
 
 	ctx := context.Background()
 
-	// libp2p.New constructs a new libp2p Host. Other options can be added
-	// here.
-	host, err := libp2p.New(ctx,
-		libp2p.ListenAddrs([]multiaddr.Multiaddr(config.ListenAddresses)...),
-	)
+	g := publicGroup(config.RendezvousString, protocol.ID(config.ProtocolID))
+	if config.Secret != "" {
+		if g, err = privateGroup(config.RendezvousString, config.Secret); err != nil {
+			panic(err)
+		}
+	}
+
+	// hostconfig.New constructs a new libp2p Host. Other options can be
+	// added to the configuration file.
+	hcfg := &hostconfig.Config{}
+	if config.HostConfigFile != "" {
+		if hcfg, err = hostconfig.Load(config.HostConfigFile); err != nil {
+			panic(err)
+		}
+	}
+	for _, a := range config.ListenAddresses {
+		hcfg.ListenAddrs = append(hcfg.ListenAddrs, a.String())
+	}
+	var opts []libp2p.Option
+	if config.PSKFile != "" {
+		// With a private network key, the host only talks to peers that
+		// have the same key, chat members or not.
+		psk, err := loadPSK(config.PSKFile)
+		if err != nil {
+			panic(err)
+		}
+		opts = append(opts, libp2p.PrivateNetwork(psk))
+	}
+	host, err := hostconfig.New(ctx, hcfg, opts...)
 	if err != nil {
 		panic(err)
 	}
@@ -109,29 +168,74 @@ func main() {
 	logger.Info(host.Addrs())
 
 	// Set a function as stream handler. This function is called when a peer
-	// initiates a connection and starts a stream with this peer.
-	host.SetStreamHandler(protocol.ID(config.ProtocolID), handleStream)
+	// initiates a connection and starts a stream with this peer. The -pid
+	// protocol is the newline delimited legacy protocol, /chat/2.0.0 is
+	// accepted as well, and is the only one in a private group.
+	peers := newPeerTable(host.ID())
+	for _, pid := range g.protocols {
+		host.SetStreamHandler(pid, func(s network.Stream) {
+			accept(peers, g, chatproto.NewConn(s))
+		})
+	}
 
+	// Find a place to meet: our own rendezvous server if we have one, the
+	// public DHT otherwise.
+	var rendezvousPoint discovery.Discovery
+	if config.RendezvousServer != nil {
+		rendezvousPoint, err = useRendezvousServer(ctx, host, *config.RendezvousServer)
+	} else {
+		rendezvousPoint, err = joinDHT(ctx, host, host.DHT, config.BootstrapPeers)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	// We use a rendezvous point "meet me here" to announce our location.
+	// This is like telling your friends to meet you at the Eiffel Tower.
+	// Announcements expire, so we keep renewing ours.
+	logger.Info("Announcing ourselves...")
+	go advertise(ctx, rendezvousPoint, g.namespace)
+
+	// Now, look for others who have announced, and keep looking for those
+	// who arrive later.
+	// This is like your friend telling you the location to meet you.
+	go discoverPeers(ctx, host, rendezvousPoint, g, config.DiscoveryInterval, peers)
+
+	writeData(peers)
+	if rc, ok := rendezvousPoint.(*rendezvous.Client); ok {
+		// Don't leave others trying to reach us until our registration
+		// expires.
+		rc.Unregister(ctx, g.namespace)
+	}
+	host.Close()
+}
+
+// joinDHT bootstraps a DHT node from the given peers, and returns it as a
+// rendezvous point. The node is kademliaDHT if the host configuration made
+// one, or else a new one.
+func joinDHT(ctx context.Context, host host.Host, kademliaDHT *dht.IpfsDHT, bootstrapPeers []multiaddr.Multiaddr) (discovery.Discovery, error) {
 	// Start a DHT, for use in peer discovery. We can't just make a new DHT
 	// client because we want each peer to maintain its own local copy of the
 	// DHT, so that the bootstrapping node of the DHT can go down without
 	// inhibiting future peer discovery.
-	kademliaDHT, err := dht.New(ctx, host)
-	if err != nil {
-		panic(err)
+	if kademliaDHT == nil {
+		var err error
+		if kademliaDHT, err = dht.New(ctx, host); err != nil {
+			return nil, err
+		}
 	}
 
 	// Bootstrap the DHT. In the default configuration, this spawns a Background
 	// thread that will refresh the peer table every five minutes.
 	logger.Debug("Bootstrapping the DHT")
-	if err = kademliaDHT.Bootstrap(ctx); err != nil {
-		panic(err)
+	if err := kademliaDHT.Bootstrap(ctx); err != nil {
+		return nil, err
 	}
 
 	// Let's connect to the bootstrap nodes first. They will tell us about the
 	// other nodes in the network.
 	var wg sync.WaitGroup
-	for _, peerAddr := range config.BootstrapPeers {
+	for _, peerAddr := range bootstrapPeers {
 		peerinfo, _ := peer.AddrInfoFromP2pAddr(peerAddr)
 		wg.Add(1)
 		go func() {
@@ -145,42 +249,18 @@ func main() {
 	}
 	wg.Wait()
 
-	// We use a rendezvous point "meet me here" to announce our location.
-	// This is like telling your friends to meet you at the Eiffel Tower.
-	logger.Info("Announcing ourselves...")
-	routingDiscovery := discovery.NewRoutingDiscovery(kademliaDHT)
-	discovery.Advertise(ctx, routingDiscovery, config.RendezvousString)
-	logger.Debug("Successfully announced!")
-
-	// Now, look for others who have announced
-	// This is like your friend telling you the location to meet you.
-	logger.Debug("Searching for other peers...")
-	peerChan, err := routingDiscovery.FindPeers(ctx, config.RendezvousString)
-	if err != nil {
-		panic(err)
-	}
-
-	for peer := range peerChan {
-		if peer.ID == host.ID() {
-			continue
-		}
-		logger.Debug("Found peer:", peer)
-
-		logger.Debug("Connecting to:", peer)
-		stream, err := host.NewStream(ctx, peer.ID, protocol.ID(config.ProtocolID))
-
-		if err != nil {
-			logger.Warning("Connection failed:", err)
-			continue
-		} else {
-			rw := bufio.NewReadWriter(bufio.NewReader(stream), bufio.NewWriter(stream))
-
-			go writeData(rw)
-			go readData(rw)
-		}
+	return discovery.NewRoutingDiscovery(kademliaDHT), nil
+}
 
-		logger.Info("Connected to:", peer)
+// useRendezvousServer connects to a rendezvous server, and returns it as a
+// rendezvous point.
+func useRendezvousServer(ctx context.Context, host host.Host, server peer.AddrInfo) (discovery.Discovery, error) {
+	if err := host.Connect(ctx, server); err != nil {
+		return nil, err
 	}
-
-	select {}
+	// Keep the server's addresses around, we dial it again on every
+	// request.
+	host.Peerstore().AddAddrs(server.ID, server.Addrs, peerstore.PermanentAddrTTL)
+	logger.Info("Connection established with rendezvous server:", server)
+	return rendezvous.NewClient(host, server.ID), nil
 }
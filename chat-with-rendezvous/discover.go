@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/discovery"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-examples/chatproto"
+)
+
+// advertiseRetry is how long to wait before trying again after a failed
+// advertisement.
+const advertiseRetry = 30 * time.Second
+
+// advertise announces us under the rendezvous string, and announces us again
+// before the advertisement expires.
+func advertise(ctx context.Context, a discovery.Advertiser, ns string) {
+	for {
+		ttl, err := a.Advertise(ctx, ns)
+		wait := advertiseRetry
+		if err != nil {
+			logger.Warning("Announcing ourselves failed, retrying in ", wait, ": ", err)
+		} else {
+			// Leave some margin, so that there is no gap between the
+			// old advertisement expiring and the new one.
+			wait = 7 * ttl / 8
+			logger.Info("Announced ourselves for ", ttl, ", announcing again in ", wait.Round(time.Second))
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// discoverPeers looks for the members of the rendezvous group every interval,
+// and opens a chat stream to those we aren't talking to yet.
+func discoverPeers(ctx context.Context, h host.Host, d discovery.Discoverer, g *group, interval time.Duration, t *peerTable) {
+	for {
+		logger.Debug("Searching for other peers...")
+		peerChan, err := d.FindPeers(ctx, g.namespace)
+		if err != nil {
+			logger.Warning("Searching for peers failed: ", err)
+		} else {
+			for p := range peerChan {
+				if p.ID == h.ID() || len(p.Addrs) == 0 {
+					continue
+				}
+				if !t.seen(p.ID) {
+					// We already have a chat stream with this peer.
+					continue
+				}
+				go connect(ctx, h, t, g, p)
+			}
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// connect opens a chat stream to a discovered peer.
+func connect(ctx context.Context, h host.Host, t *peerTable, g *group, p peer.AddrInfo) {
+	logger.Debug("Connecting to:", p)
+	// Connecting first remembers the addresses discovery returned, the
+	// DHT keeps them in the peerstore but a rendezvous server doesn't.
+	if err := h.Connect(ctx, p); err != nil {
+		logger.Debug("Connection failed:", err)
+		return
+	}
+	s, err := h.NewStream(ctx, p.ID, g.protocols...)
+	if err != nil {
+		logger.Debug("Connection failed:", err)
+		return
+	}
+	if accept(t, g, chatproto.NewConn(s)) {
+		fmt.Printf("\nConnected to %s\n> ", p.ID.Pretty())
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/libp2p/go-libp2p-core/peer"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	maddr "github.com/multiformats/go-multiaddr"
 )
@@ -40,10 +44,15 @@ func StringsToAddrs(addrStrings []string) (maddrs []maddr.Multiaddr, err error)
 }
 
 type Config struct {
-	RendezvousString string
-	BootstrapPeers   addrList
-	ListenAddresses  addrList
-	ProtocolID       string
+	RendezvousString  string
+	BootstrapPeers    addrList
+	ListenAddresses   addrList
+	ProtocolID        string
+	DiscoveryInterval time.Duration
+	Secret            string
+	PSKFile           string
+	RendezvousServer  *peer.AddrInfo
+	HostConfigFile    string
 }
 
 func ParseFlags() (Config, error) {
@@ -53,9 +62,40 @@ func ParseFlags() (Config, error) {
 	flag.Var(&config.BootstrapPeers, "peer", "Adds a peer multiaddress to the bootstrap list")
 	flag.Var(&config.ListenAddresses, "listen", "Adds a multiaddress to the listen list")
 	flag.StringVar(&config.ProtocolID, "pid", "/chat/1.1.0", "Sets a protocol id for stream headers")
+	flag.DurationVar(&config.DiscoveryInterval, "discover-every", time.Minute, "How often to look for new peers in the rendezvous group")
+	flag.StringVar(&config.Secret, "secret", "",
+		"Only chat with peers who know this secret, the rendezvous string is then kept private too (default $"+SecretEnv+")")
+	flag.StringVar(&config.PSKFile, "psk", "", "Join the private libp2p network whose key is in this swarm.key file")
+	server := flag.String("rendezvous-server", "",
+		"Meet through the rendezvous server at this multiaddress (with /p2p/), instead of the public DHT")
+	flag.StringVar(&config.HostConfigFile, "config", "", "Build the host from this configuration file, YAML or JSON. -listen adds to its listen addresses")
 	flag.Parse()
 
+	// Read only now, as a flag default it would show up in -h.
+	if config.Secret == "" {
+		config.Secret = os.Getenv(SecretEnv)
+	}
+
+	if *server != "" {
+		addr, err := maddr.NewMultiaddr(*server)
+		if err != nil {
+			return config, err
+		}
+		info, err := peer.AddrInfoFromP2pAddr(addr)
+		if err != nil {
+			return config, err
+		}
+		config.RendezvousServer = info
+		// No DHT, no bootstrap peers needed.
+		return config, nil
+	}
+
 	if len(config.BootstrapPeers) == 0 {
+		if config.PSKFile != "" {
+			// The public bootstrap nodes aren't part of the private
+			// network, they would drop our connections.
+			return config, errors.New("-psk needs at least one -peer of the private network, or a -rendezvous-server in it")
+		}
 		config.BootstrapPeers = dht.DefaultBootstrapPeers
 	}
 
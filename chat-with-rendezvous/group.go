@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+
+	"github.com/libp2p/go-libp2p-core/pnet"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/libp2p/go-libp2p-examples/chatproto"
+	"golang.org/x/crypto/scrypt"
+)
+
+// SecretEnv is the environment variable the shared secret is read from when
+// -secret isn't given. It keeps the secret out of the process list.
+const SecretEnv = "RENDEZVOUS_SECRET"
+
+// group is the set of peers we chat with.
+type group struct {
+	// namespace is what the members announce themselves under.
+	namespace string
+	// protocols are the chat protocols the members talk, preferred first.
+	protocols []protocol.ID
+	// key proves membership of a private group, it is nil for a public
+	// group.
+	key []byte
+}
+
+// publicGroup is the group of everyone who uses the same rendezvous string.
+func publicGroup(rendezvous string, pid protocol.ID) *group {
+	return &group{
+		namespace: rendezvous,
+		protocols: chatproto.Protocols(pid),
+	}
+}
+
+// privateGroup is the group of everyone who uses the same rendezvous string
+// and knows the same secret. Neither is announced: the namespace and the
+// handshake key are both derived from the secret, with scrypt so that
+// guessing a weak secret from the public namespace is slow. Only the framed
+// protocol can carry the handshake, so peers of an old version of the chat
+// are left out.
+func privateGroup(rendezvous, secret string) (*group, error) {
+	salt := []byte("chat-with-rendezvous private group\x00" + rendezvous)
+	k, err := scrypt.Key([]byte(secret), salt, 1<<15, 8, 1, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &group{
+		namespace: "/chat-with-rendezvous/" + hex.EncodeToString(k[:32]),
+		protocols: []protocol.ID{chatproto.ProtocolID},
+		key:       k[32:],
+	}, nil
+}
+
+// admit checks that the peer at the other end of c is a member of the group.
+func (g *group) admit(c *chatproto.Conn) error {
+	if g.key == nil {
+		return nil
+	}
+	return c.Authenticate(g.key)
+}
+
+// loadPSK reads a private network key, in the swarm.key format used by
+// go-ipfs.
+func loadPSK(path string) (pnet.PSK, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return pnet.DecodeV1PSK(f)
+}
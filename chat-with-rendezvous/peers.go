@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-examples/chatproto"
+	"github.com/libp2p/go-libp2p-examples/chatroom"
+)
+
+// rejectBackoff is how long we leave a peer alone after it failed to prove
+// it belongs to the group.
+const rejectBackoff = 10 * time.Minute
+
+// peerEntry is what we know about a member of the rendezvous group, besides
+// its chat stream.
+type peerEntry struct {
+	lastSeen time.Time // last time discovery returned the peer
+	rejected time.Time // last time the peer failed to prove membership
+	reason   error     // why it was rejected
+}
+
+// peerTable tracks every peer of the rendezvous group. The room keeps at
+// most one chat stream per peer.
+type peerTable struct {
+	room *chatroom.Room
+
+	mu    sync.Mutex
+	peers map[peer.ID]*peerEntry
+}
+
+func newPeerTable(self peer.ID) *peerTable {
+	return &peerTable{room: chatroom.New(self), peers: make(map[peer.ID]*peerEntry)}
+}
+
+// entry returns the entry of p, creating it if needed. t.mu must be held.
+func (t *peerTable) entry(p peer.ID) *peerEntry {
+	e := t.peers[p]
+	if e == nil {
+		e = &peerEntry{}
+		t.peers[p] = e
+	}
+	return e
+}
+
+// seen records that discovery returned p. It reports whether we need to
+// open a chat stream to p.
+func (t *peerTable) seen(p peer.ID) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.entry(p)
+	e.lastSeen = time.Now()
+	return !t.room.Has(p) && time.Since(e.rejected) > rejectBackoff
+}
+
+// reject records that p failed to prove it belongs to the group. It reports
+// whether this is news, so that a peer which keeps trying is only reported
+// once per backoff period.
+func (t *peerTable) reject(p peer.ID, reason error) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.entry(p)
+	news := time.Since(e.rejected) > rejectBackoff
+	if news {
+		e.rejected = time.Now()
+	}
+	e.reason = reason
+	return news
+}
+
+// join adds a chat stream to the room, see chatroom.Room.Join, and clears
+// any earlier rejection of its peer. join reports whether c was kept.
+func (t *peerTable) join(c *chatproto.Conn) bool {
+	if !t.room.Join(c) {
+		return false
+	}
+	t.mu.Lock()
+	e := t.entry(c.RemotePeer())
+	e.rejected, e.reason = time.Time{}, nil
+	t.mu.Unlock()
+	return true
+}
+
+// leave forgets the chat stream c. The peer stays in the table, so /peers
+// still lists it, and the next discovery round dials it again. leave reports
+// whether c was the peer's current stream.
+func (t *peerTable) leave(c *chatproto.Conn) bool {
+	return t.room.Leave(c)
+}
+
+// broadcast sends a message to every peer we have a chat stream with.
+func (t *peerTable) broadcast(body string) {
+	sent, errs := t.room.Broadcast(body)
+	if sent == 0 && len(errs) == 0 {
+		fmt.Println("Nobody to talk to yet, message not sent. Type /peers to see who was found.")
+		return
+	}
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+}
+
+// print lists the members of the group, for the /peers command.
+func (t *peerTable) print() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.peers) == 0 {
+		fmt.Println("No peers found yet")
+		return
+	}
+
+	ids := make([]peer.ID, 0, len(t.peers))
+	for p := range t.peers {
+		ids = append(ids, p)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	now := time.Now()
+	for _, p := range ids {
+		e := t.peers[p]
+		m, chatting := t.room.Member(p)
+		seen := "never discovered, dialed us"
+		if !e.lastSeen.IsZero() {
+			seen = fmt.Sprintf("discovered %s ago", now.Sub(e.lastSeen).Round(time.Second))
+		}
+		if e.reason != nil && !chatting {
+			fmt.Printf("  %s  rejected %s ago: %s (%s)\n", p.Pretty(),
+				now.Sub(e.rejected).Round(time.Second), e.reason, seen)
+		} else if chatting {
+			fmt.Printf("  %s  chatting for %s over %s, %s (%s)\n", p.Pretty(),
+				now.Sub(m.Joined).Round(time.Second), m.Conn.Stream().Protocol(),
+				m.Conn.Stream().Conn().RemoteMultiaddr(), seen)
+		} else {
+			fmt.Printf("  %s  not connected (%s)\n", p.Pretty(), seen)
+		}
+	}
+}
+
+// close ends all chat streams.
+func (t *peerTable) close() {
+	t.room.Close()
+}
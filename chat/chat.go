@@ -28,7 +28,6 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"crypto/rand"
 	"flag"
@@ -37,65 +36,29 @@ import (
 	"log"
 	mrand "math/rand"
 	"os"
+	"strings"
 
-	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p-core/crypto"
-	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
+	"github.com/libp2p/go-libp2p-examples/chatproto"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+	"github.com/libp2p/go-libp2p-examples/identity"
+	"github.com/libp2p/go-libp2p-examples/relaydial"
 
 	"github.com/multiformats/go-multiaddr"
 )
 
-func handleStream(s network.Stream) {
-	log.Println("Got a new stream!")
-
-	// Create a buffer stream for non blocking read and write.
-	rw := bufio.NewReadWriter(bufio.NewReader(s), bufio.NewWriter(s))
-
-	go readData(rw)
-	go writeData(rw)
-
-	// stream 's' will stay open until you close it (or the other side closes it).
-}
-func readData(rw *bufio.ReadWriter) {
-	for {
-		str, _ := rw.ReadString('\n')
-
-		if str == "" {
-			return
-		}
-		if str != "\n" {
-			// Green console colour: 	\x1b[32m
-			// Reset console colour: 	\x1b[0m
-			fmt.Printf("\x1b[32m%s\x1b[0m> ", str)
-		}
-
-	}
-}
-
-func writeData(rw *bufio.ReadWriter) {
-	stdReader := bufio.NewReader(os.Stdin)
-
-	for {
-		fmt.Print("> ")
-		sendData, err := stdReader.ReadString('\n')
-
-		if err != nil {
-			panic(err)
-		}
-
-		rw.WriteString(fmt.Sprintf("%s\n", sendData))
-		rw.Flush()
-	}
-
-}
-
 func main() {
 	sourcePort := flag.Int("sp", 0, "Source port number")
 	dest := flag.String("d", "", "Destination multiaddr string")
 	help := flag.Bool("help", false, "Display help")
 	debug := flag.Bool("debug", false, "Debug generates the same node ID on every execution")
+	keyFile := flag.String("id", "", "Load the node key from this file, creating it if needed")
+	keyTypeName := flag.String("key-type", "ed25519", "Type of the key created for -id: "+strings.Join(identity.KeyTypes, ", "))
+	mailboxAddrs := flag.String("mailbox", "", "Comma separated multiaddrs of mailbox peers, to exchange messages with peers that are offline")
+	relayAddrs := flag.String("relay", "", "Comma separated multiaddrs of relays, to be reached through and to reach peers that can't be dialed directly")
+	configFile := flag.String("config", "", "Build the host from this configuration file, YAML or JSON, instead of -sp, -id and -debug")
 
 	flag.Parse()
 
@@ -107,44 +70,109 @@ func main() {
 		os.Exit(0)
 	}
 
-	// If debug is enabled, use a constant random source to generate the peer ID. Only useful for debugging,
-	// off by default. Otherwise, it uses rand.Reader.
-	var r io.Reader
-	if *debug {
-		// Use the port number as the randomness source.
-		// This will always generate the same host ID on multiple executions, if the same port number is used.
-		// Never do this in production code.
-		r = mrand.New(mrand.NewSource(int64(*sourcePort)))
+	var cfg *hostconfig.Config
+	var prvKey crypto.PrivKey
+	var err error
+	if *configFile != "" {
+		// The configuration file says it all: identity, listen addresses,
+		// transports and so on.
+		if cfg, err = hostconfig.Load(*configFile); err != nil {
+			log.Fatalln(err)
+		}
+		if prvKey, err = cfg.PrivKey(); err != nil {
+			log.Fatalln(err)
+		}
+	} else if *keyFile != "" {
+		// Keep the identity in a file so that the peer ID, and therefore the
+		// multiaddress other peers dial, stays the same across restarts.
+		keyType, err := identity.ParseKeyType(*keyTypeName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		prvKey, err = identity.LoadOrCreate(*keyFile, keyType, identity.Passphrase())
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		// If debug is enabled, use a constant random source to generate the peer ID. Only useful for debugging,
+		// off by default. Otherwise, it uses rand.Reader.
+		var r io.Reader
+		if *debug {
+			// Use the port number as the randomness source.
+			// This will always generate the same host ID on multiple executions, if the same port number is used.
+			// Never do this in production code.
+			r = mrand.New(mrand.NewSource(int64(*sourcePort)))
+		} else {
+			r = rand.Reader
+		}
+
+		// Creates a new RSA key pair for this host.
+		prvKey, _, err = crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, r)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if cfg == nil {
+		// 0.0.0.0 will listen on any interface device.
+		cfg = &hostconfig.Config{
+			Identity:    hostconfig.Identity{Key: prvKey},
+			ListenAddrs: []string{fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", *sourcePort)},
+		}
 	} else {
-		r = rand.Reader
+		cfg.Identity.Key = prvKey
 	}
 
-	// Creates a new RSA key pair for this host.
-	prvKey, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, r)
+	// hostconfig.New constructs a new libp2p Host.
+	// Other options can be added to the configuration.
+	host, err := hostconfig.New(context.Background(), cfg)
 	if err != nil {
 		panic(err)
 	}
 
-	// 0.0.0.0 will listen on any interface device.
-	sourceMultiAddr, _ := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", *sourcePort))
+	// Mailbox peers keep messages for peers that are offline. Letters are
+	// encrypted to the recipient, so mailboxes can't read them.
+	var mailboxes []peer.ID
+	if *mailboxAddrs != "" {
+		for _, a := range strings.Split(*mailboxAddrs, ",") {
+			maddr, err := multiaddr.NewMultiaddr(strings.TrimSpace(a))
+			if err != nil {
+				log.Fatalln(err)
+			}
+			info, err := peer.AddrInfoFromP2pAddr(maddr)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			host.Peerstore().AddAddrs(info.ID, info.Addrs, peerstore.PermanentAddrTTL)
+			mailboxes = append(mailboxes, info.ID)
+		}
+	}
+
+	// The session manager tracks the streams of every peer in the
+	// conversation, whether they dialed us or we dialed them.
+	relays, err := relaydial.ParseRelays(*relayAddrs)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	sessions := newSessionManager(host, prvKey, mailboxes, relays)
 
-	// libp2p.New constructs a new libp2p Host.
-	// Other options can be added here.
-	host, err := libp2p.New(
-		context.Background(),
-		libp2p.ListenAddrs(sourceMultiAddr),
-		libp2p.Identity(prvKey),
-	)
+	// Set a function as stream handler.
+	// This function is called when a peer connects, and starts a stream with this protocol.
+	// Any number of peers can join the conversation this way.
+	// Peers running older versions of this example only speak the
+	// newline delimited /chat/1.0.0 protocol, so both are accepted.
+	chatproto.SetStreamHandler(host, chatproto.LegacyProtocolID, sessions.handleConn)
 
+	// Behind a NAT, the relays are the only way in.
+	relayed, err := relaydial.ConnectRelays(context.Background(), host, relays)
 	if err != nil {
-		panic(err)
+		log.Fatalln(err)
+	}
+	for _, a := range relayed {
+		fmt.Printf("Reachable through a relay at %s\n", a)
 	}
 
 	if *dest == "" {
-		// Set a function as stream handler.
-		// This function is called when a peer connects, and starts a stream with this protocol.
-		// Only applies on the receiving side.
-		host.SetStreamHandler("/chat/1.0.0", handleStream)
 
 		// Let's get the actual TCP port from our listen multiaddr, in case we're using 0 (default; random available port).
 		var port string
@@ -161,10 +189,7 @@ func main() {
 
 		fmt.Printf("Run './chat -d /ip4/127.0.0.1/tcp/%v/p2p/%s' on another console.\n", port, host.ID().Pretty())
 		fmt.Println("You can replace 127.0.0.1 with public IP as well.")
-		fmt.Printf("\nWaiting for incoming connection\n\n")
-
-		// Hang forever
-		<-make(chan struct{})
+		fmt.Printf("\nWaiting for incoming connections\n\n")
 	} else {
 		fmt.Println("This node's multiaddresses:")
 		for _, la := range host.Addrs() {
@@ -188,21 +213,19 @@ func main() {
 		// This will be used during connection and stream creation by libp2p.
 		host.Peerstore().AddAddrs(info.ID, info.Addrs, peerstore.PermanentAddrTTL)
 
-		// Start a stream with the destination.
+		// Start a stream with the destination, preferring the framed
+		// protocol if the destination supports it. If the destination
+		// can't be reached, or goes away later on, it is dialed again
+		// until it is back.
 		// Multiaddress of the destination peer is fetched from the peerstore using 'peerId'.
-		s, err := host.NewStream(context.Background(), info.ID, "/chat/1.0.0")
-		if err != nil {
-			panic(err)
-		}
-
-		// Create a buffered stream so that read and writes are non blocking.
-		rw := bufio.NewReadWriter(bufio.NewReader(s), bufio.NewWriter(s))
+		sessions.dial(info.ID)
+	}
 
-		// Create a thread to read and write data.
-		go writeData(rw)
-		go readData(rw)
+	// Read messages from stdin and send them to all peers, until the user
+	// presses Ctrl-D.
+	sessions.writeData()
 
-		// Hang forever.
-		select {}
-	}
+	fmt.Println("Leaving the conversation")
+	sessions.close()
+	host.Close()
 }
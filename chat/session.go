@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-examples/chatproto"
+	"github.com/libp2p/go-libp2p-examples/mailbox"
+	"github.com/libp2p/go-libp2p-examples/relaydial"
+)
+
+// Delays between attempts to reconnect to a peer we lost. The delay doubles
+// after every failed attempt.
+const (
+	minRedialDelay = time.Second
+	maxRedialDelay = 30 * time.Second
+)
+
+// collectInterval is how often mailboxes are checked for offline messages.
+const collectInterval = 30 * time.Second
+
+// sessionManager keeps track of every open chat stream. Messages typed by the
+// user are sent to all of them, and messages received on any of them are
+// printed along with the peer that sent them.
+//
+// Peers we dialed ourselves are dialed again when their stream goes away,
+// peers that dialed us are expected to do the same. Messages typed while such
+// a peer is unreachable are queued and sent once it is back. If mailbox peers
+// are configured, such messages are encrypted and left there instead, so they
+// reach the peer even if we are gone by the time it is back.
+type sessionManager struct {
+	host      host.Host
+	priv      crypto.PrivKey
+	mailboxes []peer.ID
+	dialer    *relaydial.Dialer
+	ctx       context.Context
+	stop      context.CancelFunc
+
+	mu        sync.Mutex
+	sessions  map[*chatproto.Conn]struct{}
+	pending   map[*chatproto.Conn][]string // messages for sessions still sending their queue
+	dialed    map[peer.ID]bool             // peers to reconnect to
+	redialing map[peer.ID]bool             // peers with a reconnect loop running
+	offline   map[peer.ID][]string         // messages queued for dialed peers we lost
+	waiting   []string                     // messages typed while nobody was connected
+	collected map[string]bool              // ids of letters read from mailboxes
+}
+
+func newSessionManager(h host.Host, priv crypto.PrivKey, mailboxes []peer.ID, relays []peer.AddrInfo) *sessionManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &sessionManager{
+		host:      h,
+		priv:      priv,
+		mailboxes: mailboxes,
+		dialer:    relaydial.NewDialer(h, relays),
+		ctx:       ctx,
+		stop:      cancel,
+		sessions:  make(map[*chatproto.Conn]struct{}),
+		pending:   make(map[*chatproto.Conn][]string),
+		dialed:    make(map[peer.ID]bool),
+		redialing: make(map[peer.ID]bool),
+		offline:   make(map[peer.ID][]string),
+		collected: make(map[string]bool),
+	}
+	if len(mailboxes) > 0 {
+		go m.collectLoop()
+	}
+	return m
+}
+
+// handleConn is the handler for incoming chat streams.
+func (m *sessionManager) handleConn(c *chatproto.Conn) {
+	log.Printf("%s joined the conversation (%s)\n", shortID(c.RemotePeer()), c.Stream().Protocol())
+	m.add(c)
+}
+
+// dial opens a chat stream to p and adds it to the conversation. Whenever the
+// stream is lost, p is dialed again in the background. The peerstore must
+// hold addresses for p.
+func (m *sessionManager) dial(p peer.ID) {
+	m.mu.Lock()
+	m.dialed[p] = true
+	m.mu.Unlock()
+
+	c, err := m.newStream(p)
+	if err != nil {
+		log.Printf("Could not reach %s: %s\n", shortID(p), err)
+		m.goOffline(p)
+		return
+	}
+	m.add(c)
+}
+
+// newStream opens a chat stream to p, connecting to it through the relays
+// if it can't be dialed directly.
+func (m *sessionManager) newStream(p peer.ID) (*chatproto.Conn, error) {
+	path, err := m.dialer.Connect(m.ctx, peer.AddrInfo{ID: p})
+	if err != nil {
+		return nil, err
+	}
+	if !path.Direct() {
+		log.Printf("Reaching %s %s\n", shortID(p), path)
+	}
+	return chatproto.NewStream(m.ctx, m.host, p, chatproto.LegacyProtocolID)
+}
+
+// add starts a session on the chat stream c, and sends it any messages that
+// were queued for its peer. The session is removed again when the stream
+// ends.
+func (m *sessionManager) add(c *chatproto.Conn) {
+	p := c.RemotePeer()
+
+	m.mu.Lock()
+	m.sessions[c] = struct{}{}
+	// Until the queue is sent, broadcast appends to it rather than
+	// overtaking it.
+	queued := append(m.waiting, m.offline[p]...)
+	m.pending[c] = queued
+	m.waiting = nil
+	delete(m.offline, p)
+	m.mu.Unlock()
+
+	go m.readData(c)
+
+	if len(queued) > 0 {
+		log.Printf("Sending %d queued messages to %s\n", len(queued), shortID(p))
+	}
+	m.sendPending(c)
+}
+
+// sendPending sends the messages pending for c, including those broadcast
+// meanwhile, and then lets broadcast send to c directly.
+func (m *sessionManager) sendPending(c *chatproto.Conn) {
+	for {
+		m.mu.Lock()
+		queued := m.pending[c]
+		if len(queued) == 0 {
+			delete(m.pending, c)
+			m.mu.Unlock()
+			return
+		}
+		m.pending[c] = nil
+		m.mu.Unlock()
+
+		for _, body := range queued {
+			if _, err := c.Send(body); err != nil {
+				m.remove(c)
+				return
+			}
+		}
+	}
+}
+
+// remove closes and forgets the session on c. If we dialed the peer, we
+// start dialing it again.
+func (m *sessionManager) remove(c *chatproto.Conn) {
+	p := c.RemotePeer()
+
+	m.mu.Lock()
+	_, ok := m.sessions[c]
+	delete(m.sessions, c)
+	delete(m.pending, c)
+	redial := m.dialed[p] && m.ctx.Err() == nil
+	m.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	c.Close()
+	if m.ctx.Err() != nil {
+		// We are leaving ourselves.
+		return
+	}
+	if redial {
+		log.Printf("Lost connection to %s, reconnecting\n", shortID(p))
+		m.goOffline(p)
+	} else {
+		log.Printf("%s left the conversation\n", shortID(p))
+	}
+}
+
+// goOffline queues messages for p from now on, and dials p with an
+// increasing delay until a session with p is open again.
+func (m *sessionManager) goOffline(p peer.ID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.offline[p]; !ok {
+		m.offline[p] = []string{}
+	}
+	if m.redialing[p] {
+		return
+	}
+	m.redialing[p] = true
+
+	go func() {
+		delay := minRedialDelay
+		for {
+			select {
+			case <-time.After(delay):
+			case <-m.ctx.Done():
+				return
+			}
+
+			if m.connected(p) {
+				// The peer reconnected on its own.
+				m.doneRedialing(p)
+				return
+			}
+
+			c, err := m.newStream(p)
+			if err == nil {
+				log.Printf("Reconnected to %s\n", shortID(p))
+				m.doneRedialing(p)
+				m.add(c)
+				return
+			}
+
+			if delay *= 2; delay > maxRedialDelay {
+				delay = maxRedialDelay
+			}
+			log.Printf("Reconnecting to %s failed, next attempt in %s\n", shortID(p), delay)
+		}
+	}()
+}
+
+// doneRedialing lets goOffline start a new reconnect loop for p.
+func (m *sessionManager) doneRedialing(p peer.ID) {
+	m.mu.Lock()
+	delete(m.redialing, p)
+	m.mu.Unlock()
+}
+
+// connected reports whether there is an open session with p.
+func (m *sessionManager) connected(p peer.ID) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for c := range m.sessions {
+		if c.RemotePeer() == p {
+			return true
+		}
+	}
+	return false
+}
+
+// readData prints the messages received in a session until the stream ends.
+func (m *sessionManager) readData(c *chatproto.Conn) {
+	from := shortID(c.RemotePeer())
+	for {
+		msg, err := c.Receive()
+		if err != nil {
+			m.remove(c)
+			return
+		}
+
+		if msg.Ack {
+			// Grey console colour: 	\x1b[90m
+			fmt.Printf("\x1b[90m[delivered to %s]\x1b[0m\n> ", from)
+			continue
+		}
+		// Green console colour: 	\x1b[32m
+		// Reset console colour: 	\x1b[0m
+		fmt.Printf("\x1b[32m%s %s: %s\x1b[0m\n> ", msg.Time.Format("15:04:05"), from, msg.Body)
+	}
+}
+
+// broadcast sends a message to every open session, and queues it for the
+// peers we are reconnecting to, or leaves it in their mailboxes. If nobody is
+// connected at all, the message is kept for the next peer to join.
+func (m *sessionManager) broadcast(body string) {
+	m.mu.Lock()
+	conns := make([]*chatproto.Conn, 0, len(m.sessions))
+	for c := range m.sessions {
+		if queued, ok := m.pending[c]; ok {
+			m.pending[c] = append(queued, body)
+		} else {
+			conns = append(conns, c)
+		}
+	}
+	var absent []peer.ID
+	for p, queued := range m.offline {
+		if len(m.mailboxes) > 0 {
+			absent = append(absent, p)
+		} else {
+			m.offline[p] = append(queued, body)
+		}
+	}
+	if len(m.sessions) == 0 && len(m.offline) == 0 {
+		m.waiting = append(m.waiting, body)
+		log.Println("Nobody is connected, message queued")
+	}
+	m.mu.Unlock()
+
+	for _, c := range conns {
+		if _, err := c.Send(body); err != nil {
+			m.remove(c)
+		}
+	}
+	for _, p := range absent {
+		m.mail(p, body)
+	}
+}
+
+// mail leaves an encrypted message for p in the mailboxes. If no mailbox
+// takes it, it is queued like without mailboxes.
+func (m *sessionManager) mail(p peer.ID, body string) {
+	n, err := mailbox.Send(m.ctx, m.host, m.priv, p, m.mailboxes, []byte(body), 0)
+	if err == nil {
+		log.Printf("%s is offline, message left in %d mailboxes\n", shortID(p), n)
+		return
+	}
+
+	log.Printf("%s is offline and the mailboxes are unusable (%s), message queued\n", shortID(p), err)
+	m.mu.Lock()
+	if queued, ok := m.offline[p]; ok {
+		m.offline[p] = append(queued, body)
+	}
+	m.mu.Unlock()
+}
+
+// collectLoop prints the messages left for us in the mailboxes, right away
+// and then periodically.
+func (m *sessionManager) collectLoop() {
+	t := time.NewTicker(collectInterval)
+	defer t.Stop()
+	for {
+		for _, mb := range m.mailboxes {
+			// Even on error, some letters may have been collected.
+			letters, _ := mailbox.Collect(m.ctx, m.host, m.priv, mb)
+			for _, l := range letters {
+				// The same letter is usually left in several
+				// mailboxes.
+				if m.collected[l.ID] {
+					continue
+				}
+				m.collected[l.ID] = true
+				// Yellow console colour: 	\x1b[33m
+				fmt.Printf("\x1b[33m%s %s (while you were away): %s\x1b[0m\n> ", l.Time.Format("Jan 2 15:04:05"), shortID(l.From), l.Body)
+			}
+		}
+
+		select {
+		case <-t.C:
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// close ends every session and stops reconnecting.
+func (m *sessionManager) close() {
+	m.stop()
+
+	m.mu.Lock()
+	conns := make([]*chatproto.Conn, 0, len(m.sessions))
+	for c := range m.sessions {
+		conns = append(conns, c)
+	}
+	m.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// writeData reads messages from stdin and sends them to every peer in the
+// conversation, until stdin is closed (Ctrl-D). There is a single stdin
+// reader no matter how many peers are connected. A line ending with a
+// backslash is continued on the next line, so a message can span several
+// lines.
+func (m *sessionManager) writeData() {
+	stdReader := bufio.NewReader(os.Stdin)
+
+	var lines []string
+	for {
+		if len(lines) == 0 {
+			fmt.Print("> ")
+		} else {
+			fmt.Print(". ")
+		}
+		sendData, err := stdReader.ReadString('\n')
+
+		if err == io.EOF && sendData == "" && len(lines) == 0 {
+			fmt.Println()
+			return
+		}
+		if err != nil && err != io.EOF {
+			log.Println("Error reading from stdin:", err)
+			return
+		}
+
+		sendData = strings.TrimRight(sendData, "\r\n")
+		if strings.HasSuffix(sendData, "\\") {
+			lines = append(lines, strings.TrimSuffix(sendData, "\\"))
+			continue
+		}
+		body := strings.Join(append(lines, sendData), "\n")
+		lines = nil
+
+		if body != "" {
+			m.broadcast(body)
+		}
+	}
+}
+
+// shortID returns the last characters of a peer ID, which is enough to tell
+// the peers of a conversation apart.
+func shortID(p peer.ID) string {
+	pretty := p.Pretty()
+	if len(pretty) <= 8 {
+		return pretty
+	}
+	return pretty[len(pretty)-8:]
+}
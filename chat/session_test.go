@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-examples/chatproto"
+	"github.com/libp2p/go-libp2p-examples/harness"
+)
+
+// newChat starts two hosts, host 0 running the chat session manager, and
+// opens a chat stream from host 1 to it.
+func newChat(t *testing.T, opts ...harness.Option) (*sessionManager, *chatproto.Conn) {
+	// The network lives as long as the test, which ctx only does when
+	// cancelled in a cleanup.
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	nw, err := harness.New(ctx, 2, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nw.Close() })
+
+	m := newSessionManager(nw.Host(0), nil, nil, nil)
+	t.Cleanup(m.close)
+	chatproto.SetStreamHandler(nw.Host(0), chatproto.LegacyProtocolID, m.handleConn)
+
+	if err := nw.Introduce(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	sctx, scancel := context.WithTimeout(ctx, 10*time.Second)
+	defer scancel()
+	c, err := chatproto.NewStream(sctx, nw.Host(1), nw.Host(0).ID(), chatproto.LegacyProtocolID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return m, c
+}
+
+// receive returns the next message or acknowledgement received on c.
+func receive(t *testing.T, c *chatproto.Conn) *chatproto.Message {
+	t.Helper()
+	msgs := make(chan *chatproto.Message, 1)
+	errs := make(chan error, 1)
+	go func() {
+		m, err := c.Receive()
+		if err != nil {
+			errs <- err
+			return
+		}
+		msgs <- m
+	}()
+
+	select {
+	case m := <-msgs:
+		return m
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	return nil
+}
+
+func TestChat(t *testing.T) {
+	for name, opts := range map[string][]harness.Option{
+		"loopback": nil,
+		"mocknet":  {harness.Mocknet()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, c := newChat(t, opts...)
+
+			// The stream only reaches the handler of host 0 once host 1
+			// writes to it.
+			id, err := c.Send("hi")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg := receive(t, c); !msg.Ack || msg.ID != id {
+				t.Fatalf("received %+v, expected the ack of %s", msg, id)
+			}
+
+			// Mocknet streams are unbuffered, writes wait for the reader.
+			go m.broadcast("hello")
+			if msg := receive(t, c); msg.Ack || msg.Body != "hello" {
+				t.Fatalf("received %+v, expected hello", msg)
+			}
+		})
+	}
+}
+
+func TestChatQueuedFirst(t *testing.T) {
+	// Both ends acknowledge what they receive while the other one is still
+	// sending, which only works with the buffering of real streams.
+	m, c := newChat(t)
+
+	// Nobody is connected yet, so these are queued for the next peer.
+	m.broadcast("queued 1")
+	m.broadcast("queued 2")
+
+	if _, err := c.Send("hi"); err != nil {
+		t.Fatal(err)
+	}
+	// Broadcast as soon as the session shows up, while the queue may still
+	// be on its way.
+	go func() {
+		harness.Eventually(5*time.Second, func() bool { return m.connected(c.Stream().Conn().LocalPeer()) })
+		m.broadcast("new")
+	}()
+
+	var bodies []string
+	for len(bodies) < 3 {
+		if msg := receive(t, c); !msg.Ack {
+			bodies = append(bodies, msg.Body)
+		}
+	}
+	if want := []string{"queued 1", "queued 2", "new"}; !reflect.DeepEqual(bodies, want) {
+		t.Fatalf("received %q, expected %q", bodies, want)
+	}
+}
@@ -0,0 +1,110 @@
+package chatproto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	pb "github.com/libp2p/go-libp2p-examples/chatproto/pb"
+)
+
+// ErrNotFramed is returned by Authenticate on a legacy stream, which has no
+// room for a handshake.
+var ErrNotFramed = errors.New("chatproto: legacy streams can't authenticate")
+
+// ErrWrongSecret is returned by Authenticate when the peer's response shows
+// it doesn't know the shared secret.
+var ErrWrongSecret = errors.New("chatproto: peer doesn't know the shared secret")
+
+// challengeSize is the size of the nonce each side sends.
+const challengeSize = 32
+
+// authTimeout bounds the handshake, so that a peer which never answers
+// doesn't hold the stream forever.
+const authTimeout = 10 * time.Second
+
+// Authenticate runs the shared secret handshake on a framed stream. Both
+// sides send a random challenge and answer the other's with an HMAC of it,
+// keyed with the secret. Call it on both ends, right after opening or
+// accepting the stream and before exchanging messages; the stream must not
+// be used if it fails.
+//
+// The response covers both peer IDs, which the secure channel has already
+// verified, so it can neither be replayed to another peer nor reflected back
+// to the peer that sent the challenge.
+func (c *Conn) Authenticate(key []byte) error {
+	if !c.framed {
+		return ErrNotFramed
+	}
+
+	c.stream.SetDeadline(time.Now().Add(authTimeout))
+	defer c.stream.SetDeadline(time.Time{})
+
+	ours := make([]byte, challengeSize)
+	if _, err := rand.Read(ours); err != nil {
+		return err
+	}
+	if err := c.writeFrame(&pb.Frame{Type: pb.Frame_CHALLENGE, Body: ours}); err != nil {
+		return err
+	}
+
+	theirs, err := c.readFrame(pb.Frame_CHALLENGE)
+	if err != nil {
+		return err
+	}
+	if len(theirs) != challengeSize {
+		return fmt.Errorf("chatproto: challenge of %d bytes, expected %d", len(theirs), challengeSize)
+	}
+
+	local, remote := c.stream.Conn().LocalPeer(), c.RemotePeer()
+	if err := c.writeFrame(&pb.Frame{
+		Type: pb.Frame_RESPONSE,
+		Body: authMAC(key, theirs, local, remote),
+	}); err != nil {
+		return err
+	}
+
+	resp, err := c.readFrame(pb.Frame_RESPONSE)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(resp, authMAC(key, ours, remote, local)) {
+		return ErrWrongSecret
+	}
+	return nil
+}
+
+// authMAC computes the response to a challenge.
+func authMAC(key, challenge []byte, responder, challenger peer.ID) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(ProtocolID + " auth\x00"))
+	mac.Write(challenge)
+	mac.Write([]byte(responder))
+	mac.Write([]byte(challenger))
+	return mac.Sum(nil)
+}
+
+func (c *Conn) writeFrame(f *pb.Frame) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	f.Timestamp = time.Now().UnixNano()
+	return c.frameWriter.WriteMsg(f)
+}
+
+// readFrame reads the next frame and returns its body, failing if it isn't
+// of the expected type.
+func (c *Conn) readFrame(t pb.Frame_Type) ([]byte, error) {
+	f := &pb.Frame{}
+	if err := c.frameReader.ReadMsg(f); err != nil {
+		return nil, err
+	}
+	if f.Type != t {
+		return nil, fmt.Errorf("chatproto: got a %s frame, expected %s", f.Type, t)
+	}
+	return f.Body, nil
+}
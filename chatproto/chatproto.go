@@ -0,0 +1,216 @@
+// Package chatproto implements the wire protocols of the chat examples.
+//
+// The original chat protocol sends every message as a newline terminated
+// string, which rules out multi-line messages and leaves no room for
+// metadata. /chat/2.0.0 sends length-prefixed protobuf frames instead, each
+// carrying a message ID, a timestamp and the body, and acknowledges every
+// message it receives. Both are offered when opening a stream, so peers
+// running older versions of the examples can still be talked to.
+package chatproto
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+
+	ggio "github.com/gogo/protobuf/io"
+	uuid "github.com/google/uuid"
+	pb "github.com/libp2p/go-libp2p-examples/chatproto/pb"
+)
+
+// ProtocolID is the framed chat protocol.
+const ProtocolID = "/chat/2.0.0"
+
+// LegacyProtocolID is the newline delimited protocol of the chat example.
+// The other chat examples let the user pick their own legacy protocol ID.
+const LegacyProtocolID = "/chat/1.0.0"
+
+// maxFrameSize bounds the size of a single frame.
+const maxFrameSize = 1 << 20
+
+// Message is a chat message, or the acknowledgement of one.
+type Message struct {
+	// ID identifies the message. It is empty for messages received over
+	// the legacy protocol, which has no IDs.
+	ID string
+	// Time is when the author wrote the message. For legacy messages it
+	// is when the message was received.
+	Time time.Time
+	// Body is the message text, without a trailing newline.
+	Body string
+	// Ack is set when this is not a message but the acknowledgement that
+	// the message with the given ID was delivered.
+	Ack bool
+}
+
+// Conn is a chat stream, using whichever protocol was negotiated.
+type Conn struct {
+	stream network.Stream
+	framed bool
+
+	// Only one of these is used, depending on the protocol.
+	lineReader  *bufio.Reader
+	frameReader ggio.Reader
+
+	wmu         sync.Mutex // guards writes, acks are sent by Receive
+	lineWriter  *bufio.Writer
+	frameWriter ggio.Writer
+}
+
+// NewConn wraps a stream opened for ProtocolID or a legacy protocol.
+func NewConn(s network.Stream) *Conn {
+	c := &Conn{
+		stream: s,
+		framed: s.Protocol() == ProtocolID,
+	}
+	if c.framed {
+		c.frameReader = ggio.NewDelimitedReader(s, maxFrameSize)
+		c.frameWriter = ggio.NewDelimitedWriter(s)
+	} else {
+		c.lineReader = bufio.NewReader(s)
+		c.lineWriter = bufio.NewWriter(s)
+	}
+	return c
+}
+
+// Protocols returns the protocol IDs to offer when opening a chat stream,
+// preferred first.
+func Protocols(legacy protocol.ID) []protocol.ID {
+	return []protocol.ID{ProtocolID, legacy}
+}
+
+// SetStreamHandler makes h accept chat streams over both protocols.
+func SetStreamHandler(h host.Host, legacy protocol.ID, handler func(*Conn)) {
+	for _, pid := range Protocols(legacy) {
+		h.SetStreamHandler(pid, func(s network.Stream) {
+			handler(NewConn(s))
+		})
+	}
+}
+
+// NewStream opens a chat stream to p, using the framed protocol if p
+// supports it.
+func NewStream(ctx context.Context, h host.Host, p peer.ID, legacy protocol.ID) (*Conn, error) {
+	s, err := h.NewStream(ctx, p, Protocols(legacy)...)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(s), nil
+}
+
+// Framed reports whether the stream uses the framed protocol. Only framed
+// streams carry message IDs, timestamps and acknowledgements.
+func (c *Conn) Framed() bool {
+	return c.framed
+}
+
+// Stream returns the underlying stream.
+func (c *Conn) Stream() network.Stream {
+	return c.stream
+}
+
+// RemotePeer returns the peer at the other end of the stream.
+func (c *Conn) RemotePeer() peer.ID {
+	return c.stream.Conn().RemotePeer()
+}
+
+// Send sends a message and returns its ID, or an empty ID on a legacy
+// stream. On a legacy stream a multi-line body arrives as several messages.
+func (c *Conn) Send(body string) (string, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if !c.framed {
+		if _, err := c.lineWriter.WriteString(strings.TrimSuffix(body, "\n") + "\n"); err != nil {
+			return "", err
+		}
+		return "", c.lineWriter.Flush()
+	}
+
+	id := uuid.New().String()
+	err := c.frameWriter.WriteMsg(&pb.Frame{
+		Type:      pb.Frame_MESSAGE,
+		Id:        id,
+		Timestamp: time.Now().UnixNano(),
+		Body:      []byte(body),
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Receive waits for the next message or acknowledgement. Messages received
+// over the framed protocol are acknowledged before Receive returns them.
+func (c *Conn) Receive() (*Message, error) {
+	if !c.framed {
+		return c.receiveLine()
+	}
+
+	f := &pb.Frame{}
+	for {
+		if err := c.frameReader.ReadMsg(f); err != nil {
+			return nil, err
+		}
+		// A peer that expects the shared secret handshake sends a
+		// challenge first, it is no message to show.
+		if f.Type == pb.Frame_MESSAGE || f.Type == pb.Frame_ACK {
+			break
+		}
+	}
+
+	m := &Message{
+		ID:   f.Id,
+		Time: time.Unix(0, f.Timestamp),
+		Body: string(f.Body),
+		Ack:  f.Type == pb.Frame_ACK,
+	}
+	if !m.Ack {
+		if err := c.ack(f.Id); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// receiveLine reads the next non-empty line of a legacy stream.
+func (c *Conn) receiveLine() (*Message, error) {
+	for {
+		str, err := c.lineReader.ReadString('\n')
+		// Old versions of the examples send an extra empty line after
+		// every message.
+		if str = strings.TrimSuffix(str, "\n"); str != "" {
+			return &Message{Time: time.Now(), Body: str}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Conn) ack(id string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.frameWriter.WriteMsg(&pb.Frame{
+		Type:      pb.Frame_ACK,
+		Id:        id,
+		Timestamp: time.Now().UnixNano(),
+	})
+}
+
+// Close closes the stream.
+func (c *Conn) Close() error {
+	return c.stream.Close()
+}
+
+// Reset aborts the stream.
+func (c *Conn) Reset() error {
+	return c.stream.Reset()
+}
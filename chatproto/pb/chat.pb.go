@@ -0,0 +1,139 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: chat.proto
+
+package chatproto_pb
+
+import (
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+type Frame_Type int32
+
+const (
+	Frame_MESSAGE   Frame_Type = 0
+	Frame_ACK       Frame_Type = 1
+	Frame_CHALLENGE Frame_Type = 2
+	Frame_RESPONSE  Frame_Type = 3
+)
+
+var Frame_Type_name = map[int32]string{
+	0: "MESSAGE",
+	1: "ACK",
+	2: "CHALLENGE",
+	3: "RESPONSE",
+}
+
+var Frame_Type_value = map[string]int32{
+	"MESSAGE":   0,
+	"ACK":       1,
+	"CHALLENGE": 2,
+	"RESPONSE":  3,
+}
+
+func (x Frame_Type) String() string {
+	return proto.EnumName(Frame_Type_name, int32(x))
+}
+
+func (Frame_Type) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_8c585a45e2093e54, []int{0, 0}
+}
+
+// every frame sent on a /chat/2.0.0 stream. frames are length-prefixed with an unsigned varint
+type Frame struct {
+	Type                 Frame_Type `protobuf:"varint,1,opt,name=type,proto3,enum=chatproto.pb.Frame_Type" json:"type,omitempty"`
+	Id                   string     `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Timestamp            int64      `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Body                 []byte     `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *Frame) Reset()         { *m = Frame{} }
+func (m *Frame) String() string { return proto.CompactTextString(m) }
+func (*Frame) ProtoMessage()    {}
+func (*Frame) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8c585a45e2093e54, []int{0}
+}
+func (m *Frame) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Frame.Unmarshal(m, b)
+}
+func (m *Frame) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Frame.Marshal(b, m, deterministic)
+}
+func (m *Frame) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Frame.Merge(m, src)
+}
+func (m *Frame) XXX_Size() int {
+	return xxx_messageInfo_Frame.Size(m)
+}
+func (m *Frame) XXX_DiscardUnknown() {
+	xxx_messageInfo_Frame.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Frame proto.InternalMessageInfo
+
+func (m *Frame) GetType() Frame_Type {
+	if m != nil {
+		return m.Type
+	}
+	return Frame_MESSAGE
+}
+
+func (m *Frame) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Frame) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *Frame) GetBody() []byte {
+	if m != nil {
+		return m.Body
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("chatproto.pb.Frame_Type", Frame_Type_name, Frame_Type_value)
+	proto.RegisterType((*Frame)(nil), "chatproto.pb.Frame")
+}
+
+func init() { proto.RegisterFile("chat.proto", fileDescriptor_8c585a45e2093e54) }
+
+var fileDescriptor_8c585a45e2093e54 = []byte{
+	// 198 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0xce, 0xbf, 0x4a, 0xc5, 0x30,
+	0x14, 0xc7, 0x71, 0xf3, 0x47, 0xaf, 0x3d, 0xd6, 0x4b, 0x38, 0x53, 0x06, 0x87, 0x70, 0xa7, 0x0c,
+	0x92, 0x41, 0x27, 0xc7, 0x52, 0x62, 0x05, 0x6b, 0x95, 0xc4, 0x17, 0x68, 0x6d, 0xc0, 0x0e, 0xa5,
+	0xa1, 0x66, 0xe9, 0x6b, 0xf9, 0x84, 0xd2, 0x2c, 0xde, 0xed, 0xcb, 0xe1, 0xc3, 0xe1, 0x07, 0xf0,
+	0xf5, 0xdd, 0x27, 0x13, 0xd7, 0x25, 0x2d, 0x58, 0xee, 0x9d, 0xd3, 0xc4, 0xe1, 0xf4, 0x4b, 0xe0,
+	0xf2, 0x79, 0xed, 0xe7, 0x80, 0xf7, 0xc0, 0xd3, 0x16, 0x83, 0x24, 0x8a, 0xe8, 0xe3, 0x83, 0x34,
+	0xe7, 0xcc, 0x64, 0x62, 0x3e, 0xb7, 0x18, 0x5c, 0x56, 0x78, 0x04, 0x3a, 0x8d, 0x92, 0x2a, 0xa2,
+	0x0b, 0x47, 0xa7, 0x11, 0xef, 0xa0, 0x48, 0xd3, 0x1c, 0x7e, 0x52, 0x3f, 0x47, 0xc9, 0x14, 0xd1,
+	0xcc, 0xfd, 0x1f, 0x10, 0x81, 0x0f, 0xcb, 0xb8, 0x49, 0xae, 0x88, 0x2e, 0x5d, 0xee, 0xd3, 0x13,
+	0xf0, 0xfd, 0x1f, 0xde, 0xc0, 0xe1, 0xcd, 0x7a, 0x5f, 0x35, 0x56, 0x5c, 0xe0, 0x01, 0x58, 0x55,
+	0xbf, 0x0a, 0x82, 0xb7, 0x50, 0xd4, 0x2f, 0x55, 0xdb, 0xda, 0xae, 0xb1, 0x82, 0x62, 0x09, 0xd7,
+	0xce, 0xfa, 0x8f, 0xf7, 0xce, 0x5b, 0xc1, 0x86, 0xab, 0xbc, 0xeb, 0xf1, 0x6f, 0x00, 0x99, 0x49,
+	0x28, 0xa6, 0xd7, 0x00, 0x00, 0x00,
+}
@@ -0,0 +1,128 @@
+// Package chatroom keeps track of the chat streams of a group chat, one per
+// peer, for the chat examples that find their peers on their own and may
+// open a stream to a peer while it opens one to them.
+package chatroom
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-examples/chatproto"
+)
+
+// Member is a peer we have a chat stream with.
+type Member struct {
+	Conn *chatproto.Conn
+	// Joined is when the stream was added to the room.
+	Joined time.Time
+}
+
+// Room holds the chat streams of all peers we are talking to, one per peer.
+type Room struct {
+	self peer.ID
+
+	mu      sync.Mutex
+	members map[peer.ID]Member
+}
+
+// New returns an empty room for the host with the given ID.
+func New(self peer.ID) *Room {
+	return &Room{self: self, members: make(map[peer.ID]Member)}
+}
+
+// Has reports whether we already have a chat stream with p.
+func (r *Room) Has(p peer.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.members[p]
+	return ok
+}
+
+// Member returns the chat stream of p, if we have one.
+func (r *Room) Member(p peer.ID) (Member, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.members[p]
+	return m, ok
+}
+
+// Join adds a chat stream to the room. Two peers that discover each other
+// at the same time open a stream each, both sides then keep the stream
+// opened by the peer with the lower ID and close the other one. Join reports
+// whether c was kept.
+func (r *Room) Join(c *chatproto.Conn) bool {
+	p := c.RemotePeer()
+
+	r.mu.Lock()
+	old, ok := r.members[p]
+	keep := !ok || r.opener(c) < r.opener(old.Conn)
+	if keep {
+		r.members[p] = Member{Conn: c, Joined: time.Now()}
+	}
+	r.mu.Unlock()
+
+	if !keep {
+		c.Close()
+	} else if ok {
+		old.Conn.Close()
+	}
+	return keep
+}
+
+// opener returns the peer that opened the stream of c.
+func (r *Room) opener(c *chatproto.Conn) peer.ID {
+	if c.Stream().Stat().Direction == network.DirOutbound {
+		return r.self
+	}
+	return c.RemotePeer()
+}
+
+// Leave closes a chat stream and removes it from the room, unless it was
+// replaced already. It reports whether the peer is gone from the room.
+func (r *Room) Leave(c *chatproto.Conn) bool {
+	r.mu.Lock()
+	gone := r.members[c.RemotePeer()].Conn == c
+	if gone {
+		delete(r.members, c.RemotePeer())
+	}
+	r.mu.Unlock()
+	c.Close()
+	return gone
+}
+
+// Broadcast sends a message to everyone in the room, and returns the number
+// of peers it was sent to. Peers it couldn't be sent to leave the room, the
+// errors tell who they were and why.
+func (r *Room) Broadcast(body string) (int, []error) {
+	r.mu.Lock()
+	conns := make([]*chatproto.Conn, 0, len(r.members))
+	for _, m := range r.members {
+		conns = append(conns, m.Conn)
+	}
+	r.mu.Unlock()
+
+	sent := 0
+	var errs []error
+	for _, c := range conns {
+		if _, err := c.Send(body); err != nil {
+			errs = append(errs, fmt.Errorf("not sent to %s: %w", c.RemotePeer().Pretty(), err))
+			r.Leave(c)
+			continue
+		}
+		sent++
+	}
+	return sent, errs
+}
+
+// Close ends all chat streams.
+func (r *Room) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p, m := range r.members {
+		m.Conn.Close()
+		delete(r.members, p)
+	}
+}
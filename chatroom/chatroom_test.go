@@ -0,0 +1,98 @@
+package chatroom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/libp2p/go-libp2p-examples/chatproto"
+	"github.com/libp2p/go-libp2p-examples/harness"
+)
+
+const legacy = "/chat/1.0.0"
+
+// newRooms starts two connected hosts, each with a room that every incoming
+// chat stream joins.
+func newRooms(t *testing.T) (*harness.Network, []*Room) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	nw, err := harness.New(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nw.Close() })
+	if err := nw.Connect(ctx, harness.FullMesh); err != nil {
+		t.Fatal(err)
+	}
+
+	var rooms []*Room
+	for _, h := range nw.Hosts {
+		r := New(h.ID())
+		t.Cleanup(r.Close)
+		chatproto.SetStreamHandler(h, legacy, func(c *chatproto.Conn) { r.Join(c) })
+		rooms = append(rooms, r)
+	}
+	return nw, rooms
+}
+
+// open opens a chat stream from host i to host j, and adds it to the room of
+// host i. The stream reaches the room of host j once something is sent.
+func open(t *testing.T, nw *harness.Network, r *Room, i, j int) *chatproto.Conn {
+	t.Helper()
+	c, err := chatproto.NewStream(context.Background(), nw.Host(i), nw.Host(j).ID(), legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Send("hi"); err != nil {
+		t.Fatal(err)
+	}
+	r.Join(c)
+	return c
+}
+
+func TestSimultaneousOpen(t *testing.T) {
+	nw, rooms := newRooms(t)
+	open(t, nw, rooms[0], 0, 1)
+	open(t, nw, rooms[1], 1, 0)
+
+	// Both sides end up with the stream opened by the lower peer ID.
+	lower := nw.Host(0).ID()
+	if nw.Host(1).ID() < lower {
+		lower = nw.Host(1).ID()
+	}
+	opener := func(i int) peer.ID {
+		m, ok := rooms[i].Member(nw.Host(1 - i).ID())
+		if !ok || m.Conn.Stream().Stat().Direction == network.DirInbound {
+			return nw.Host(1 - i).ID()
+		}
+		return nw.Host(i).ID()
+	}
+	if !harness.Eventually(5*time.Second, func() bool { return opener(0) == lower && opener(1) == lower }) {
+		t.Fatalf("host 0 kept the stream of %s, host 1 the one of %s, expected %s", opener(0), opener(1), lower)
+	}
+}
+
+func TestLeaveAndBroadcast(t *testing.T) {
+	nw, rooms := newRooms(t)
+	c := open(t, nw, rooms[0], 0, 1)
+
+	if sent, errs := rooms[0].Broadcast("hello"); sent != 1 || len(errs) != 0 {
+		t.Fatalf("sent to %d peers, errors %v", sent, errs)
+	}
+	if !rooms[0].Leave(c) {
+		t.Fatal("the peer didn't leave")
+	}
+	if rooms[0].Has(nw.Host(1).ID()) {
+		t.Fatal("the peer is still in the room")
+	}
+	if rooms[0].Leave(c) {
+		t.Fatal("the peer left twice")
+	}
+	if sent, errs := rooms[0].Broadcast("hello"); sent != 0 || len(errs) != 0 {
+		t.Fatalf("sent to %d peers of an empty room, errors %v", sent, errs)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	mplex "github.com/libp2p/go-libp2p-mplex"
+	libp2pquic "github.com/libp2p/go-libp2p-quic-transport"
+	yamux "github.com/libp2p/go-libp2p-yamux"
+	tcp "github.com/libp2p/go-tcp-transport"
+	ws "github.com/libp2p/go-ws-transport"
+)
+
+// benchProtocol streams echo everything written to them until they are closed.
+const benchProtocol = "/echo/bench/1.0.0"
+
+// benchConfig describes a benchmark run.
+type benchConfig struct {
+	transport string
+	muxer     string
+	streams   int
+	size      int
+	duration  time.Duration
+}
+
+// validate rejects settings the benchmark can't run with: no streams, empty
+// payloads, which would only measure how fast nothing goes round, or no time
+// to send.
+func (cfg benchConfig) validate() error {
+	switch {
+	case cfg.streams < 1:
+		return fmt.Errorf("-streams must be at least 1, not %d", cfg.streams)
+	case cfg.size < 1:
+		return fmt.Errorf("-size must be at least 1 byte, not %d", cfg.size)
+	case cfg.duration <= 0:
+		return fmt.Errorf("-duration must be positive, not %s", cfg.duration)
+	}
+	return nil
+}
+
+// benchResult holds the measurements of a benchmark run.
+type benchResult struct {
+	connect time.Duration   // time to establish the connection
+	setup   []time.Duration // time to open each stream
+	rtts    []time.Duration // round trip time of every payload
+	bytes   int64           // payload bytes echoed back
+	elapsed time.Duration   // total time spent sending
+}
+
+// benchListenOptions makes the listener reachable over every transport, with
+// every muxer, so a single listener can be benchmarked in all combinations.
+func benchListenOptions(listenPort int) []libp2p.Option {
+	return []libp2p.Option{
+		libp2p.DefaultTransports,
+		libp2p.Transport(libp2pquic.NewTransport),
+		libp2p.DefaultMuxers,
+		libp2p.ListenAddrStrings(
+			fmt.Sprintf("/ip4/127.0.0.1/udp/%d/quic", listenPort),
+			"/ip4/127.0.0.1/tcp/0/ws",
+		),
+	}
+}
+
+// benchDialOptions restricts the dialer to a single transport and muxer, so
+// the listener has to use them too. QUIC brings its own stream multiplexing,
+// the muxer is ignored for it.
+func benchDialOptions(transport, muxer string) ([]libp2p.Option, error) {
+	opts := []libp2p.Option{libp2p.NoListenAddrs}
+
+	switch transport {
+	case "tcp":
+		opts = append(opts, libp2p.Transport(tcp.NewTCPTransport))
+	case "ws":
+		opts = append(opts, libp2p.Transport(ws.New))
+	case "quic":
+		opts = append(opts, libp2p.Transport(libp2pquic.NewTransport))
+	default:
+		return nil, fmt.Errorf("unknown transport %q, use tcp, ws or quic", transport)
+	}
+
+	switch muxer {
+	case "yamux":
+		opts = append(opts, libp2p.Muxer("/yamux/1.0.0", yamux.DefaultTransport))
+	case "mplex":
+		opts = append(opts, libp2p.Muxer("/mplex/6.7.0", mplex.DefaultTransport))
+	default:
+		return nil, fmt.Errorf("unknown muxer %q, use yamux or mplex", muxer)
+	}
+
+	return opts, nil
+}
+
+// printBenchAddrs tells the user how to benchmark each transport the
+// listener is reachable on.
+func printBenchAddrs(h host.Host, listenPort int) {
+	log.Println("Benchmark this host with:")
+	for _, addr := range h.Addrs() {
+		transport := "tcp"
+		for _, p := range addr.Protocols() {
+			switch p.Name {
+			case "ws", "quic":
+				transport = p.Name
+			}
+		}
+		log.Printf("  ./echo -l %d -bench -transport %s -d %s/p2p/%s\n", listenPort+1, transport, addr, h.ID().Pretty())
+	}
+}
+
+// handleBench echoes all data back until the remote side closes the stream.
+func handleBench(s network.Stream) {
+	if _, err := io.Copy(s, s); err != nil {
+		s.Reset()
+		return
+	}
+	s.Close()
+}
+
+// runBenchmark sends payloads of cfg.size bytes over cfg.streams concurrent
+// streams for cfg.duration, waiting for each payload to be echoed back before
+// sending the next one.
+func runBenchmark(ctx context.Context, h host.Host, p peer.ID, cfg benchConfig) (*benchResult, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	res := &benchResult{}
+
+	start := time.Now()
+	if err := h.Connect(ctx, h.Peerstore().PeerInfo(p)); err != nil {
+		return nil, err
+	}
+	res.connect = time.Since(start)
+
+	payload := make([]byte, cfg.size)
+	if _, err := rand.Read(payload); err != nil {
+		return nil, err
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(chan error, cfg.streams)
+	)
+	deadline := time.Now().Add(cfg.duration)
+	start = time.Now()
+	for i := 0; i < cfg.streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			t := time.Now()
+			s, err := h.NewStream(ctx, p, benchProtocol)
+			if err != nil {
+				errs <- err
+				return
+			}
+			setup := time.Since(t)
+
+			var rtts []time.Duration
+			buf := make([]byte, cfg.size)
+			for time.Now().Before(deadline) {
+				t := time.Now()
+				if _, err := s.Write(payload); err != nil {
+					s.Reset()
+					errs <- err
+					return
+				}
+				if _, err := io.ReadFull(s, buf); err != nil {
+					s.Reset()
+					errs <- err
+					return
+				}
+				rtts = append(rtts, time.Since(t))
+			}
+			s.Close()
+
+			mu.Lock()
+			res.setup = append(res.setup, setup)
+			res.rtts = append(res.rtts, rtts...)
+			res.bytes += int64(len(rtts)) * int64(cfg.size)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	res.elapsed = time.Since(start)
+
+	close(errs)
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// percentile returns the p-th percentile of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
+
+// print writes a human readable report of the results.
+func (r *benchResult) print(cfg benchConfig) {
+	muxer := cfg.muxer
+	if cfg.transport == "quic" {
+		muxer = "native"
+	}
+	fmt.Printf("transport %s, muxer %s, %d streams, %d byte payloads, %s\n",
+		cfg.transport, muxer, cfg.streams, cfg.size, cfg.duration)
+
+	fmt.Printf("connection setup: %s\n", r.connect)
+
+	var total, max time.Duration
+	for _, d := range r.setup {
+		total += d
+		if d > max {
+			max = d
+		}
+	}
+	if len(r.setup) > 0 {
+		fmt.Printf("stream setup:     avg %s, max %s\n", total/time.Duration(len(r.setup)), max)
+	}
+
+	rtts := r.rtts
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	secs := r.elapsed.Seconds()
+	fmt.Printf("round trips:      %d (%.0f/s)\n", len(rtts), float64(len(rtts))/secs)
+	if len(rtts) > 0 {
+		fmt.Printf("rtt:              min %s, p50 %s, p90 %s, p99 %s, max %s\n",
+			rtts[0], percentile(rtts, 50), percentile(rtts, 90), percentile(rtts, 99), rtts[len(rtts)-1])
+	}
+	fmt.Printf("throughput:       %.2f MiB/s each way\n", float64(r.bytes)/secs/(1<<20))
+}
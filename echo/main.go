@@ -10,6 +10,9 @@ import (
 	"io/ioutil"
 	"log"
 	mrand "math/rand"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p-core/crypto"
@@ -17,47 +20,37 @@ import (
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+	_ "github.com/libp2p/go-libp2p-examples/hostconfig/quic"
+	"github.com/libp2p/go-libp2p-examples/identity"
+	"github.com/libp2p/go-libp2p-examples/relaydial"
 
 	golog "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
 )
 
-// makeBasicHost creates a LibP2P host with a random peer ID listening on the
-// given multiaddress. It won't encrypt the connection if insecure is true.
-func makeBasicHost(listenPort int, insecure bool, randseed int64) (host.Host, error) {
-
-	// If the seed is zero, use real cryptographic randomness. Otherwise, use a
-	// deterministic randomness source to make generated keys stay the same
-	// across multiple runs
-	var r io.Reader
-	if randseed == 0 {
-		r = rand.Reader
-	} else {
-		r = mrand.New(mrand.NewSource(randseed))
+// makeBasicHost creates a LibP2P host as cfg says, listening on the given
+// port of the loopback interface unless cfg lists addresses to listen on.
+// It won't encrypt the connection if insecure is true. Any extra options are
+// applied last.
+func makeBasicHost(listenPort int, insecure bool, cfg *hostconfig.Config, extra ...libp2p.Option) (host.Host, error) {
+	if len(cfg.ListenAddrs) == 0 {
+		cfg.ListenAddrs = []string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", listenPort)}
 	}
-
-	// Generate a key pair for this host. We will use it at least
-	// to obtain a valid host ID.
-	priv, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, r)
-	if err != nil {
-		return nil, err
-	}
-
-	opts := []libp2p.Option{
-		libp2p.ListenAddrStrings(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", listenPort)),
-		libp2p.Identity(priv),
-		libp2p.DisableRelay(),
-	}
-
 	if insecure {
-		opts = append(opts, libp2p.NoSecurity)
+		extra = append([]libp2p.Option{libp2p.NoSecurity}, extra...)
 	}
 
-	basicHost, err := libp2p.New(context.Background(), opts...)
+	basicHost, err := hostconfig.New(context.Background(), cfg, extra...)
 	if err != nil {
 		return nil, err
 	}
 
+	// A host that does not listen has no address to share
+	if len(basicHost.Addrs()) == 0 {
+		return basicHost, nil
+	}
+
 	// Build host multiaddress
 	hostAddr, _ := ma.NewMultiaddr(fmt.Sprintf("/ipfs/%s", basicHost.ID().Pretty()))
 
@@ -75,6 +68,29 @@ func makeBasicHost(listenPort int, insecure bool, randseed int64) (host.Host, er
 	return basicHost, nil
 }
 
+// hostKey returns the private key for the host: read from keyFile when one is
+// given, otherwise freshly generated.
+func hostKey(randseed int64, keyFile string, keyType int) (crypto.PrivKey, error) {
+	if keyFile != "" {
+		return identity.LoadOrCreate(keyFile, keyType, identity.Passphrase())
+	}
+
+	// If the seed is zero, use real cryptographic randomness. Otherwise, use a
+	// deterministic randomness source to make generated keys stay the same
+	// across multiple runs
+	var r io.Reader
+	if randseed == 0 {
+		r = rand.Reader
+	} else {
+		r = mrand.New(mrand.NewSource(randseed))
+	}
+
+	// Generate a key pair for this host. We will use it at least
+	// to obtain a valid host ID.
+	priv, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, r)
+	return priv, err
+}
+
 func main() {
 	// LibP2P code uses golog to log messages. They log with different
 	// string IDs (i.e. "swarm"). We can control the verbosity level for
@@ -86,61 +102,147 @@ func main() {
 	target := flag.String("d", "", "target peer to dial")
 	insecure := flag.Bool("insecure", false, "use an unencrypted connection")
 	seed := flag.Int64("seed", 0, "set random seed for id generation")
+	keyFile := flag.String("id", "", "load the host key from this file, creating it if needed")
+	keyTypeName := flag.String("key-type", "ed25519", "type of the key created for -id: "+strings.Join(identity.KeyTypes, ", "))
+	bench := flag.Bool("bench", false, "benchmark round trips and throughput instead of sending a single line")
+	streams := flag.Int("streams", 4, "benchmark: number of concurrent streams")
+	size := flag.Int("size", 1024, "benchmark: payload size in bytes")
+	duration := flag.Duration("duration", 10*time.Second, "benchmark: how long to send for")
+	transport := flag.String("transport", "tcp", "benchmark: transport to dial with (tcp, ws or quic)")
+	muxer := flag.String("muxer", "yamux", "benchmark: stream muxer to dial with (yamux or mplex)")
+	relayList := flag.String("relay", "", "comma separated multiaddrs of relays: the listener can be reached through them, the dialer goes through them when the target can't be dialed directly")
+	configFile := flag.String("config", "", "build the host from this configuration file, YAML or JSON, instead of -id, -key-type and -seed")
 	flag.Parse()
 
 	if *listenF == 0 {
 		log.Fatal("Please provide a port to bind on with -l")
 	}
+	bcfg := benchConfig{
+		transport: *transport,
+		muxer:     *muxer,
+		streams:   *streams,
+		size:      *size,
+		duration:  *duration,
+	}
+	if *bench && *target != "" {
+		if err := bcfg.validate(); err != nil {
+			fmt.Fprintln(flag.CommandLine.Output(), err)
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+	keyType, err := identity.ParseKeyType(*keyTypeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// In benchmark mode the listener accepts every transport and muxer,
+	// while the dialer only enables the ones to measure.
+	var extra []libp2p.Option
+	if *bench {
+		if *target == "" {
+			extra = benchListenOptions(*listenF)
+		} else {
+			var err error
+			if extra, err = benchDialOptions(*transport, *muxer); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	relays, err := relaydial.ParseRelays(*relayList)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var cfg *hostconfig.Config
+	if *configFile != "" {
+		if cfg, err = hostconfig.Load(*configFile); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		priv, err := hostKey(*seed, *keyFile, keyType)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg = &hostconfig.Config{Identity: hostconfig.Identity{Key: priv}}
+		// Relaying is off, unless we need the relay transport to go
+		// through relays or to be reached through them.
+		cfg.Relay.Disabled = true
+	}
+	if len(relays) > 0 || strings.Contains(*target, "/p2p-circuit") {
+		cfg.Relay.Disabled = false
+	}
 
 	// Make a host that listens on the given multiaddress
-	ha, err := makeBasicHost(*listenF, *insecure, *seed)
+	ha, err := makeBasicHost(*listenF, *insecure, cfg, extra...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// Set a stream handler on host A. /echo/1.0.0 is
 	// a user-defined protocol name.
-	ha.SetStreamHandler("/echo/1.0.0", func(s network.Stream) {
-		log.Println("Got a new stream!")
-		if err := doEcho(s); err != nil {
-			log.Println(err)
-			s.Reset()
-		} else {
-			s.Close()
-		}
-	})
+	ha.SetStreamHandler("/echo/1.0.0", handleEcho)
+
+	if *bench {
+		ha.SetStreamHandler(benchProtocol, handleBench)
+	}
 
 	if *target == "" {
+		if *bench {
+			printBenchAddrs(ha, *listenF)
+		}
+		if len(relays) > 0 {
+			addrs, err := relaydial.ConnectRelays(context.Background(), ha, relays)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			for _, a := range addrs {
+				log.Printf("I can also be reached at %s\n", a)
+			}
+			log.Printf("Peers that can't dial me directly can run \"./echo -l %d -d %s\"\n", *listenF+1, addrs[0])
+		}
 		log.Println("listening for connections")
 		select {} // hang forever
 	}
 	/**** This is where the listener code ends ****/
 
-	// The following code extracts target's the peer ID from the
-	// given multiaddress
+	// The following code extracts target's peer ID, and its address,
+	// from the given multiaddress. The peer ID is the last component:
+	// the target may be behind a relay, as in
+	// /ip4/<a.b.c.d>/tcp/<port>/p2p/<relay>/p2p-circuit/p2p/<peer>.
 	ipfsaddr, err := ma.NewMultiaddr(*target)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	pid, err := ipfsaddr.ValueForProtocol(ma.P_IPFS)
+	info, err := peer.AddrInfoFromP2pAddr(ipfsaddr)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	peerid := info.ID
 
-	peerid, err := peer.IDB58Decode(pid)
+	// We have a peer ID and its address so we add it to the peerstore
+	// so LibP2P knows how to contact it
+	ha.Peerstore().AddAddrs(peerid, info.Addrs, peerstore.PermanentAddrTTL)
+
+	if *bench {
+		log.Printf("benchmarking %s for %s\n", peerid.Pretty(), bcfg.duration)
+		res, err := runBenchmark(context.Background(), ha, peerid, bcfg)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		res.print(bcfg)
+		return
+	}
+
+	// Dial the target directly, and through the relays if that fails.
+	dialer := relaydial.NewDialer(ha, relays)
+	path, err := dialer.Connect(context.Background(), *info)
 	if err != nil {
 		log.Fatalln(err)
 	}
-
-	// Decapsulate the /ipfs/<peerID> part from the target
-	// /ip4/<a.b.c.d>/ipfs/<peer> becomes /ip4/<a.b.c.d>
-	targetPeerAddr, _ := ma.NewMultiaddr(fmt.Sprintf("/ipfs/%s", pid))
-	targetAddr := ipfsaddr.Decapsulate(targetPeerAddr)
-
-	// We have a peer ID and a targetAddr so we add it to the peerstore
-	// so LibP2P knows how to contact it
-	ha.Peerstore().AddAddr(peerid, targetAddr, peerstore.PermanentAddrTTL)
+	log.Printf("connected to %s: %s\n", peerid.Pretty(), path)
 
 	log.Println("opening stream")
 	// make a new stream from host B to host A
@@ -164,6 +266,17 @@ func main() {
 	log.Printf("read reply: %q\n", out)
 }
 
+// handleEcho is the handler of /echo/1.0.0 streams
+func handleEcho(s network.Stream) {
+	log.Println("Got a new stream!")
+	if err := doEcho(s); err != nil {
+		log.Println(err)
+		s.Reset()
+	} else {
+		s.Close()
+	}
+}
+
 // doEcho reads a line of data a stream and writes it back
 func doEcho(s network.Stream) error {
 	buf := bufio.NewReader(s)
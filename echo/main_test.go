@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-examples/harness"
+)
+
+func TestEcho(t *testing.T) {
+	for name, opts := range map[string][]harness.Option{
+		"loopback": nil,
+		"mocknet":  {harness.Mocknet()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			nw, err := harness.New(ctx, 2, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer nw.Close()
+			if err := nw.Introduce(1, 0); err != nil {
+				t.Fatal(err)
+			}
+			nw.Host(0).SetStreamHandler("/echo/1.0.0", handleEcho)
+
+			s, err := nw.Host(1).NewStream(ctx, nw.Host(0).ID(), "/echo/1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Write([]byte("Hello, world!\n")); err != nil {
+				t.Fatal(err)
+			}
+			out, err := ioutil.ReadAll(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != "Hello, world!\n" {
+				t.Fatalf("read reply %q", out)
+			}
+		})
+	}
+}
+
+func TestBenchConfigValidate(t *testing.T) {
+	good := benchConfig{transport: "tcp", muxer: "yamux", streams: 4, size: 1024, duration: time.Second}
+	if err := good.validate(); err != nil {
+		t.Fatalf("default settings rejected: %s", err)
+	}
+	for name, mutate := range map[string]func(*benchConfig){
+		"negative streams":  func(c *benchConfig) { c.streams = -1 },
+		"no streams":        func(c *benchConfig) { c.streams = 0 },
+		"negative size":     func(c *benchConfig) { c.size = -1 },
+		"empty payload":     func(c *benchConfig) { c.size = 0 },
+		"no duration":       func(c *benchConfig) { c.duration = 0 },
+		"negative duration": func(c *benchConfig) { c.duration = -time.Second },
+	} {
+		cfg := good
+		mutate(&cfg)
+		if err := cfg.validate(); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
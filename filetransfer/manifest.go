@@ -0,0 +1,164 @@
+// Package filetransfer moves files between libp2p hosts.
+//
+// A Sender shares files. For every shared file it computes a manifest: the
+// file size and the SHA-256 of the file and of each of its fixed size chunks.
+// A receiver first fetches the manifest and then pulls the chunks it is
+// missing over several concurrent streams, verifying every chunk against the
+// manifest before writing it. Progress is kept next to the partial file, so
+// an interrupted transfer picks up where it stopped, whether the connection
+// dropped or the receiving process was restarted.
+package filetransfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p-core/protocol"
+
+	pb "github.com/libp2p/go-libp2p-examples/filetransfer/pb"
+)
+
+// ManifestProtocol is used to ask a sender for the manifest of a shared file.
+const ManifestProtocol protocol.ID = "/filetransfer/manifest/1.0.0"
+
+// ChunkProtocol is used to fetch file chunks. A stream can carry any number
+// of chunk requests, one after the other.
+const ChunkProtocol protocol.ID = "/filetransfer/chunk/1.0.0"
+
+// DefaultChunkSize is the chunk size used when none is given.
+const DefaultChunkSize = 256 << 10
+
+// MaxChunkSize is the largest chunk size peers accept.
+const MaxChunkSize = 4 << 20
+
+// maxMessageSize bounds the size of a single protobuf message on the wire.
+const maxMessageSize = MaxChunkSize + 4096
+
+// Manifest describes a shared file.
+type Manifest struct {
+	ID          string   // hex encoded SHA-256 of the whole file
+	Name        string   // base name of the file
+	Size        int64    // size of the file in bytes
+	ChunkSize   int      // size of every chunk but the last one
+	ChunkHashes [][]byte // SHA-256 of each chunk
+}
+
+// NewManifest reads the file at path and computes its manifest.
+func NewManifest(path string, chunkSize int) (*Manifest, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("chunk size %d is larger than %d", chunkSize, MaxChunkSize)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &Manifest{Name: filepath.Base(path), ChunkSize: chunkSize}
+	fileHash := sha256.New()
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			sum := sha256.Sum256(buf[:n])
+			m.ChunkHashes = append(m.ChunkHashes, sum[:])
+			fileHash.Write(buf[:n])
+			m.Size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.ID = hex.EncodeToString(fileHash.Sum(nil))
+	return m, nil
+}
+
+// NumChunks returns the number of chunks in the file.
+func (m *Manifest) NumChunks() int {
+	return len(m.ChunkHashes)
+}
+
+// ChunkLen returns the length of the chunk at index i.
+func (m *Manifest) ChunkLen(i int) int {
+	if i == m.NumChunks()-1 {
+		return int(m.Size - int64(i)*int64(m.ChunkSize))
+	}
+	return m.ChunkSize
+}
+
+// VerifyChunk checks data against the hash of chunk i.
+func (m *Manifest) VerifyChunk(i int, data []byte) error {
+	if i < 0 || i >= m.NumChunks() {
+		return fmt.Errorf("chunk %d out of range", i)
+	}
+	if len(data) != m.ChunkLen(i) {
+		return fmt.Errorf("chunk %d has %d bytes, expected %d", i, len(data), m.ChunkLen(i))
+	}
+	sum := sha256.Sum256(data)
+	if string(sum[:]) != string(m.ChunkHashes[i]) {
+		return fmt.Errorf("chunk %d failed hash verification", i)
+	}
+	return nil
+}
+
+// validate checks that a manifest received from a peer is self-consistent.
+func (m *Manifest) validate() error {
+	if _, err := hex.DecodeString(m.ID); err != nil || len(m.ID) != 2*sha256.Size {
+		return errors.New("invalid file id")
+	}
+	if m.Name == "" || m.Name != filepath.Base(m.Name) || m.Name == "." || m.Name == ".." {
+		return fmt.Errorf("invalid file name %q", m.Name)
+	}
+	if m.ChunkSize <= 0 || m.ChunkSize > MaxChunkSize {
+		return fmt.Errorf("invalid chunk size %d", m.ChunkSize)
+	}
+	chunks := (m.Size + int64(m.ChunkSize) - 1) / int64(m.ChunkSize)
+	if m.Size < 0 || int64(m.NumChunks()) != chunks {
+		return fmt.Errorf("manifest lists %d chunks for %d bytes", m.NumChunks(), m.Size)
+	}
+	for i, h := range m.ChunkHashes {
+		if len(h) != sha256.Size {
+			return fmt.Errorf("invalid hash for chunk %d", i)
+		}
+	}
+	return nil
+}
+
+func (m *Manifest) toProto() *pb.Manifest {
+	return &pb.Manifest{
+		FileId:      m.ID,
+		Name:        m.Name,
+		Size_:       uint64(m.Size),
+		ChunkSize:   uint32(m.ChunkSize),
+		ChunkHashes: m.ChunkHashes,
+	}
+}
+
+func manifestFromProto(p *pb.Manifest) (*Manifest, error) {
+	if p == nil {
+		return nil, errors.New("empty manifest")
+	}
+	m := &Manifest{
+		ID:          p.FileId,
+		Name:        p.Name,
+		Size:        int64(p.Size_),
+		ChunkSize:   int(p.ChunkSize),
+		ChunkHashes: p.ChunkHashes,
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
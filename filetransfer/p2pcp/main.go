@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	"github.com/libp2p/go-libp2p-examples/filetransfer"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+const help = `
+p2pcp copies files between two libp2p hosts.
+
+Usage: Share one or more files with:  ./p2pcp send [-l <port>] [-id <key file>] [-config <file>] <file>...
+       Then fetch one of them with:   ./p2pcp receive -d <sender multiaddress> [-f <file id>] [-o <dir>] [-config <file>]
+
+Interrupted downloads are resumed when running the same receive command again.
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Print(help)
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch flag.Arg(0) {
+	case "send":
+		send(flag.Args()[1:])
+	case "receive":
+		receive(flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// makeHost starts a host from the configuration file configFile, or else
+// one listening on port. keyFile holds the host key when the configuration
+// names none. Use the same key across runs to let receivers resume
+// downloads after the sender restarted.
+func makeHost(port int, keyFile, configFile string) host.Host {
+	cfg := &hostconfig.Config{}
+	if configFile != "" {
+		var err error
+		if cfg, err = hostconfig.Load(configFile); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if len(cfg.ListenAddrs) == 0 {
+		cfg.ListenAddrs = []string{fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port)}
+	}
+	if cfg.Identity.File == "" {
+		cfg.Identity.File = keyFile
+	}
+
+	h, err := hostconfig.New(context.Background(), cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return h
+}
+
+func send(args []string) {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	port := fs.Int("l", 0, "libp2p listen port, 0 picks a free one")
+	keyFile := fs.String("id", "", "load the host key from this file, creating it if needed")
+	chunkSize := fs.Int("chunk", filetransfer.DefaultChunkSize, "chunk size in bytes")
+	configFile := fs.String("config", "", "build the host from this YAML or JSON configuration file, -l and -id fill in what it leaves out")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		log.Fatalln("send: no files given")
+	}
+
+	h := makeHost(*port, *keyFile, *configFile)
+	sender := filetransfer.NewSender(h)
+
+	var addr ma.Multiaddr
+	for _, a := range h.Addrs() {
+		if v, err := a.ValueForProtocol(ma.P_IP4); err == nil && v == "127.0.0.1" {
+			addr = a
+		}
+	}
+	if addr == nil {
+		addr = h.Addrs()[0]
+	}
+	p2pAddr := fmt.Sprintf("%s/p2p/%s", addr, h.ID().Pretty())
+
+	for _, path := range fs.Args() {
+		m, err := sender.Share(path, *chunkSize)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Printf("Sharing %s (%d bytes, %d chunks)\n", m.Name, m.Size, m.NumChunks())
+		fmt.Printf("Run './p2pcp receive -d %s -f %s' on another console.\n\n", p2pAddr, m.ID)
+	}
+
+	fmt.Println("Other addresses of this host:")
+	for _, a := range h.Addrs() {
+		fmt.Printf(" - %s/p2p/%s\n", a, h.ID().Pretty())
+	}
+
+	select {} // hang forever
+}
+
+func receive(args []string) {
+	fs := flag.NewFlagSet("receive", flag.ExitOnError)
+	dest := fs.String("d", "", "multiaddress of the sender")
+	fileID := fs.String("f", "", "id of the file to fetch, may be omitted if the sender shares a single file")
+	dir := fs.String("o", ".", "directory to write the file to")
+	concurrency := fs.Int("c", 4, "number of concurrent chunk streams")
+	configFile := fs.String("config", "", "build the host from this YAML or JSON configuration file")
+	fs.Parse(args)
+
+	if *dest == "" {
+		log.Fatalln("receive: the sender multiaddress is required (-d)")
+	}
+
+	maddr, err := ma.NewMultiaddr(*dest)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	info, err := peer.AddrInfoFromP2pAddr(maddr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	h := makeHost(0, "", *configFile)
+	h.Peerstore().AddAddrs(info.ID, info.Addrs, peerstore.PermanentAddrTTL)
+
+	start := time.Now()
+	path, err := filetransfer.Receive(context.Background(), h, info.ID, *fileID, filetransfer.ReceiveOptions{
+		Dir:         *dir,
+		Concurrency: *concurrency,
+		Progress:    printProgress,
+	})
+	fmt.Println()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("Received %s in %s, SHA-256 verified\n", path, time.Since(start).Round(time.Millisecond))
+}
+
+func printProgress(p filetransfer.Progress) {
+	pct := 100.0
+	if p.Manifest.Size > 0 {
+		pct = float64(p.Bytes) * 100 / float64(p.Manifest.Size)
+	}
+	fmt.Printf("\r%s: %d/%d chunks, %d/%d bytes (%.1f%%)", p.Manifest.Name,
+		p.Chunks, p.Manifest.NumChunks(), p.Bytes, p.Manifest.Size, pct)
+}
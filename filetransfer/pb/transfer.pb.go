@@ -0,0 +1,309 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: transfer.proto
+
+package filetransfer_pb
+
+import (
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// describes a shared file. sent by the sender in response to a ManifestRequest
+type Manifest struct {
+	FileId               string   `protobuf:"bytes,1,opt,name=fileId,proto3" json:"fileId,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Size_                uint64   `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	ChunkSize            uint32   `protobuf:"varint,4,opt,name=chunkSize,proto3" json:"chunkSize,omitempty"`
+	ChunkHashes          [][]byte `protobuf:"bytes,5,rep,name=chunkHashes,proto3" json:"chunkHashes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Manifest) Reset()         { *m = Manifest{} }
+func (m *Manifest) String() string { return proto.CompactTextString(m) }
+func (*Manifest) ProtoMessage()    {}
+func (*Manifest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_96c3e6bcafb460d3, []int{0}
+}
+func (m *Manifest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Manifest.Unmarshal(m, b)
+}
+func (m *Manifest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Manifest.Marshal(b, m, deterministic)
+}
+func (m *Manifest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Manifest.Merge(m, src)
+}
+func (m *Manifest) XXX_Size() int {
+	return xxx_messageInfo_Manifest.Size(m)
+}
+func (m *Manifest) XXX_DiscardUnknown() {
+	xxx_messageInfo_Manifest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Manifest proto.InternalMessageInfo
+
+func (m *Manifest) GetFileId() string {
+	if m != nil {
+		return m.FileId
+	}
+	return ""
+}
+
+func (m *Manifest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Manifest) GetSize_() uint64 {
+	if m != nil {
+		return m.Size_
+	}
+	return 0
+}
+
+func (m *Manifest) GetChunkSize() uint32 {
+	if m != nil {
+		return m.ChunkSize
+	}
+	return 0
+}
+
+func (m *Manifest) GetChunkHashes() [][]byte {
+	if m != nil {
+		return m.ChunkHashes
+	}
+	return nil
+}
+
+// asks for the manifest of a shared file. an empty fileId asks for the only shared file
+type ManifestRequest struct {
+	FileId               string   `protobuf:"bytes,1,opt,name=fileId,proto3" json:"fileId,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ManifestRequest) Reset()         { *m = ManifestRequest{} }
+func (m *ManifestRequest) String() string { return proto.CompactTextString(m) }
+func (*ManifestRequest) ProtoMessage()    {}
+func (*ManifestRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_96c3e6bcafb460d3, []int{1}
+}
+func (m *ManifestRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ManifestRequest.Unmarshal(m, b)
+}
+func (m *ManifestRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ManifestRequest.Marshal(b, m, deterministic)
+}
+func (m *ManifestRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ManifestRequest.Merge(m, src)
+}
+func (m *ManifestRequest) XXX_Size() int {
+	return xxx_messageInfo_ManifestRequest.Size(m)
+}
+func (m *ManifestRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ManifestRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ManifestRequest proto.InternalMessageInfo
+
+func (m *ManifestRequest) GetFileId() string {
+	if m != nil {
+		return m.FileId
+	}
+	return ""
+}
+
+type ManifestResponse struct {
+	Manifest             *Manifest `protobuf:"bytes,1,opt,name=manifest,proto3" json:"manifest,omitempty"`
+	Error                string    `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *ManifestResponse) Reset()         { *m = ManifestResponse{} }
+func (m *ManifestResponse) String() string { return proto.CompactTextString(m) }
+func (*ManifestResponse) ProtoMessage()    {}
+func (*ManifestResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_96c3e6bcafb460d3, []int{2}
+}
+func (m *ManifestResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ManifestResponse.Unmarshal(m, b)
+}
+func (m *ManifestResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ManifestResponse.Marshal(b, m, deterministic)
+}
+func (m *ManifestResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ManifestResponse.Merge(m, src)
+}
+func (m *ManifestResponse) XXX_Size() int {
+	return xxx_messageInfo_ManifestResponse.Size(m)
+}
+func (m *ManifestResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ManifestResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ManifestResponse proto.InternalMessageInfo
+
+func (m *ManifestResponse) GetManifest() *Manifest {
+	if m != nil {
+		return m.Manifest
+	}
+	return nil
+}
+
+func (m *ManifestResponse) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+// asks for one chunk of a file. several requests can be sent over the same stream
+type ChunkRequest struct {
+	FileId               string   `protobuf:"bytes,1,opt,name=fileId,proto3" json:"fileId,omitempty"`
+	Index                uint32   `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChunkRequest) Reset()         { *m = ChunkRequest{} }
+func (m *ChunkRequest) String() string { return proto.CompactTextString(m) }
+func (*ChunkRequest) ProtoMessage()    {}
+func (*ChunkRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_96c3e6bcafb460d3, []int{3}
+}
+func (m *ChunkRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChunkRequest.Unmarshal(m, b)
+}
+func (m *ChunkRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChunkRequest.Marshal(b, m, deterministic)
+}
+func (m *ChunkRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChunkRequest.Merge(m, src)
+}
+func (m *ChunkRequest) XXX_Size() int {
+	return xxx_messageInfo_ChunkRequest.Size(m)
+}
+func (m *ChunkRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChunkRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChunkRequest proto.InternalMessageInfo
+
+func (m *ChunkRequest) GetFileId() string {
+	if m != nil {
+		return m.FileId
+	}
+	return ""
+}
+
+func (m *ChunkRequest) GetIndex() uint32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+type ChunkResponse struct {
+	Index                uint32   `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Error                string   `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChunkResponse) Reset()         { *m = ChunkResponse{} }
+func (m *ChunkResponse) String() string { return proto.CompactTextString(m) }
+func (*ChunkResponse) ProtoMessage()    {}
+func (*ChunkResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_96c3e6bcafb460d3, []int{4}
+}
+func (m *ChunkResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChunkResponse.Unmarshal(m, b)
+}
+func (m *ChunkResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChunkResponse.Marshal(b, m, deterministic)
+}
+func (m *ChunkResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChunkResponse.Merge(m, src)
+}
+func (m *ChunkResponse) XXX_Size() int {
+	return xxx_messageInfo_ChunkResponse.Size(m)
+}
+func (m *ChunkResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChunkResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChunkResponse proto.InternalMessageInfo
+
+func (m *ChunkResponse) GetIndex() uint32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *ChunkResponse) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *ChunkResponse) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Manifest)(nil), "filetransfer.pb.Manifest")
+	proto.RegisterType((*ManifestRequest)(nil), "filetransfer.pb.ManifestRequest")
+	proto.RegisterType((*ManifestResponse)(nil), "filetransfer.pb.ManifestResponse")
+	proto.RegisterType((*ChunkRequest)(nil), "filetransfer.pb.ChunkRequest")
+	proto.RegisterType((*ChunkResponse)(nil), "filetransfer.pb.ChunkResponse")
+}
+
+func init() { proto.RegisterFile("transfer.proto", fileDescriptor_96c3e6bcafb460d3) }
+
+var fileDescriptor_96c3e6bcafb460d3 = []byte{
+	// 258 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x91, 0x41, 0x4b, 0xc4, 0x30,
+	0x10, 0x85, 0x89, 0xed, 0x2e, 0xbb, 0xb3, 0xad, 0x2b, 0x41, 0xa4, 0x82, 0x87, 0x90, 0x53, 0xbd,
+	0xf4, 0xa0, 0x78, 0xf3, 0xe6, 0x45, 0x0f, 0x22, 0xc4, 0x1f, 0x20, 0x59, 0x3b, 0x65, 0x8b, 0x6e,
+	0xba, 0x26, 0x5d, 0x10, 0x7f, 0x82, 0xbf, 0x5a, 0x32, 0x1b, 0x9b, 0xe2, 0xc1, 0xbd, 0xcd, 0x7b,
+	0xf3, 0x1e, 0x7c, 0x93, 0xc0, 0x71, 0x6f, 0xb5, 0x71, 0x0d, 0xda, 0x6a, 0x6b, 0xbb, 0xbe, 0xe3,
+	0xcb, 0xa6, 0x7d, 0xc7, 0xe8, 0xad, 0xe4, 0x37, 0x83, 0xd9, 0xa3, 0x36, 0x6d, 0x83, 0xae, 0xe7,
+	0x67, 0x30, 0xf5, 0xfb, 0x87, 0xba, 0x60, 0x82, 0x95, 0x73, 0x15, 0x14, 0xe7, 0x90, 0x1a, 0xbd,
+	0xc1, 0xe2, 0x88, 0x5c, 0x9a, 0xbd, 0xe7, 0xda, 0x2f, 0x2c, 0x12, 0xc1, 0xca, 0x54, 0xd1, 0xcc,
+	0x2f, 0x60, 0xfe, 0xba, 0xde, 0x99, 0xb7, 0x67, 0xbf, 0x48, 0x05, 0x2b, 0x73, 0x15, 0x0d, 0x2e,
+	0x60, 0x41, 0xe2, 0x5e, 0xbb, 0x35, 0xba, 0x62, 0x22, 0x92, 0x32, 0x53, 0x63, 0x4b, 0x5e, 0xc2,
+	0xf2, 0x97, 0x45, 0xe1, 0xc7, 0xee, 0x1f, 0x24, 0xf9, 0x02, 0x27, 0x31, 0xea, 0xb6, 0x9d, 0x71,
+	0xc8, 0x6f, 0x60, 0xb6, 0x09, 0x1e, 0xa5, 0x17, 0x57, 0xe7, 0xd5, 0x9f, 0x7b, 0xab, 0xa1, 0x34,
+	0x44, 0xf9, 0x29, 0x4c, 0xd0, 0xda, 0xce, 0x86, 0xf3, 0xf6, 0x42, 0xde, 0x42, 0x76, 0xe7, 0xd1,
+	0x0e, 0x80, 0xf8, 0x76, 0x6b, 0x6a, 0xfc, 0xa4, 0x76, 0xae, 0xf6, 0x42, 0x3e, 0x41, 0x1e, 0xda,
+	0x81, 0x6d, 0x88, 0xb1, 0x51, 0xcc, 0x3f, 0x62, 0xad, 0x7b, 0x4d, 0xdd, 0x4c, 0xd1, 0x1c, 0x71,
+	0x92, 0x11, 0xce, 0x6a, 0x4a, 0xff, 0x77, 0xfd, 0x33, 0x00, 0x14, 0xf7, 0x68, 0x88, 0xd1, 0x01,
+	0x00, 0x00,
+}
@@ -0,0 +1,444 @@
+package filetransfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	ggio "github.com/gogo/protobuf/io"
+	pb "github.com/libp2p/go-libp2p-examples/filetransfer/pb"
+)
+
+// Progress describes how far a download got.
+type Progress struct {
+	Manifest *Manifest
+	Chunks   int   // verified chunks written to disk
+	Bytes    int64 // verified bytes written to disk
+}
+
+// ReceiveOptions configures a download. The zero value is usable.
+type ReceiveOptions struct {
+	// Dir is the directory the file is written to. Defaults to the current
+	// directory.
+	Dir string
+	// Concurrency is the number of chunk streams used in parallel.
+	// Defaults to 4.
+	Concurrency int
+	// Retries is how many times in a row the download is resumed after
+	// failing without making any progress. Defaults to 5.
+	Retries int
+	// RetryDelay is the delay before the first retry. It doubles on every
+	// retry that follows. Defaults to one second.
+	RetryDelay time.Duration
+	// Progress, if set, is called every time a chunk has been verified and
+	// written, and once when the download starts.
+	Progress func(Progress)
+}
+
+// errPermanent marks errors retrying can't fix.
+type errPermanent struct{ error }
+
+// FetchManifest asks peer p for the manifest of the file with the given id.
+// An empty id asks for the only file shared by the peer.
+func FetchManifest(ctx context.Context, h host.Host, p peer.ID, fileID string) (*Manifest, error) {
+	s, err := h.NewStream(ctx, p, ManifestProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if err := ggio.NewDelimitedWriter(s).WriteMsg(&pb.ManifestRequest{FileId: fileID}); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	resp := &pb.ManifestResponse{}
+	if err := ggio.NewDelimitedReader(s, maxMessageSize).ReadMsg(resp); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errPermanent{fmt.Errorf("%s: %s", p.Pretty(), resp.Error)}
+	}
+
+	m, err := manifestFromProto(resp.Manifest)
+	if err != nil {
+		return nil, errPermanent{fmt.Errorf("bad manifest from %s: %w", p.Pretty(), err)}
+	}
+	if fileID != "" && m.ID != fileID {
+		return nil, errPermanent{fmt.Errorf("%s sent the manifest of file %s, asked for %s", p.Pretty(), m.ID, fileID)}
+	}
+	return m, nil
+}
+
+// Receive downloads the file with the given id from peer p and returns the
+// path it was written to. An empty id downloads the only file shared by p.
+//
+// While downloading, the data is kept in "<name>.part" and the list of
+// verified chunks in "<name>.part.json". Calling Receive again after an
+// interrupted download only fetches the missing chunks.
+func Receive(ctx context.Context, h host.Host, p peer.ID, fileID string, opts ReceiveOptions) (string, error) {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = 5
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = time.Second
+	}
+
+	var (
+		m   *Manifest
+		err error
+	)
+	delay := opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		m, err = FetchManifest(ctx, h, p, fileID)
+		if err == nil {
+			break
+		}
+		if _, ok := err.(errPermanent); ok || attempt >= opts.Retries {
+			return "", err
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return "", err
+		}
+		delay *= 2
+	}
+
+	d, err := openDownload(opts.Dir, m)
+	if err != nil {
+		return "", err
+	}
+	d.progress = opts.Progress
+	d.report()
+
+	err = d.fetch(ctx, h, p, opts)
+	if err == nil {
+		err = d.verify()
+	}
+	// The part file is closed before being moved into place, which some
+	// systems refuse to do with open files.
+	if cerr := d.part.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return d.install()
+}
+
+// fetch fetches the missing chunks, retrying as long as attempts make
+// progress.
+func (d *download) fetch(ctx context.Context, h host.Host, p peer.ID, opts ReceiveOptions) error {
+	delay := opts.RetryDelay
+	for failures := 0; ; {
+		before := d.chunks
+		err := d.fetchMissing(ctx, h, p, opts.Concurrency)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(errPermanent); ok {
+			return err
+		}
+
+		// Only give up after several attempts in a row that did not
+		// get us any further.
+		if d.chunks > before {
+			failures = 0
+			delay = opts.RetryDelay
+		} else if failures++; failures > opts.Retries {
+			return fmt.Errorf("giving up after %d retries: %w", opts.Retries, err)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+		delay *= 2
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// download is the on-disk state of a file being received.
+type download struct {
+	manifest  *Manifest
+	path      string // final path
+	part      *os.File
+	statePath string
+	progress  func(Progress)
+
+	mu        sync.Mutex
+	done      []bool
+	chunks    int
+	bytes     int64
+	lastSaved time.Time
+}
+
+// downloadState is persisted next to the partial file.
+type downloadState struct {
+	Manifest *Manifest
+	Done     []bool
+}
+
+func openDownload(dir string, m *Manifest) (*download, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	d := &download{
+		manifest:  m,
+		path:      filepath.Join(dir, m.Name),
+		statePath: filepath.Join(dir, m.Name+".part.json"),
+		done:      make([]bool, m.NumChunks()),
+	}
+
+	// Resume from a previous attempt at the same file, if any.
+	resume := false
+	if data, err := ioutil.ReadFile(d.statePath); err == nil {
+		var st downloadState
+		if json.Unmarshal(data, &st) == nil && st.Manifest != nil && st.Manifest.ID == m.ID && len(st.Done) == len(d.done) {
+			copy(d.done, st.Done)
+			resume = true
+		}
+	}
+
+	flags := os.O_RDWR | os.O_CREATE
+	if !resume {
+		flags |= os.O_TRUNC
+	}
+	part, err := os.OpenFile(filepath.Join(dir, m.Name+".part"), flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	d.part = part
+
+	for i, ok := range d.done {
+		if ok {
+			d.chunks++
+			d.bytes += int64(m.ChunkLen(i))
+		}
+	}
+	return d, nil
+}
+
+// missing returns the indexes of the chunks still to be fetched.
+func (d *download) missing() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var res []int
+	for i, ok := range d.done {
+		if !ok {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// fetchMissing fetches all missing chunks over up to n concurrent streams.
+// It returns the first error hit by any of the streams.
+func (d *download) fetchMissing(ctx context.Context, h host.Host, p peer.ID, n int) error {
+	missing := d.missing()
+	if len(missing) == 0 {
+		return nil
+	}
+	if n > len(missing) {
+		n = len(missing)
+	}
+
+	todo := make(chan int, len(missing))
+	for _, i := range missing {
+		todo <- i
+	}
+	close(todo)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.worker(ctx, h, p, todo); err != nil {
+				errs <- err
+				// A permanent error affects all streams alike.
+				if _, ok := err.(errPermanent); ok {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	d.mu.Lock()
+	d.save()
+	d.mu.Unlock()
+
+	var first error
+	for err := range errs {
+		if _, ok := err.(errPermanent); ok {
+			return err
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	if first != nil {
+		return first
+	}
+	if len(d.missing()) > 0 {
+		return errors.New("chunks missing after transfer")
+	}
+	return nil
+}
+
+// worker fetches chunks from todo over a single stream.
+func (d *download) worker(ctx context.Context, h host.Host, p peer.ID, todo <-chan int) error {
+	s, err := h.NewStream(ctx, p, ChunkProtocol)
+	if err != nil {
+		return err
+	}
+
+	reader := ggio.NewDelimitedReader(s, maxMessageSize)
+	writer := ggio.NewDelimitedWriter(s)
+	for i := range todo {
+		// Another stream hit a permanent error, leave the rest of the
+		// chunks alone.
+		if err := ctx.Err(); err != nil {
+			s.Reset()
+			return err
+		}
+		if err := writer.WriteMsg(&pb.ChunkRequest{FileId: d.manifest.ID, Index: uint32(i)}); err != nil {
+			s.Reset()
+			return err
+		}
+
+		resp := &pb.ChunkResponse{}
+		if err := reader.ReadMsg(resp); err != nil {
+			s.Reset()
+			return err
+		}
+		if resp.Error != "" {
+			s.Reset()
+			return errPermanent{fmt.Errorf("chunk %d: %s", i, resp.Error)}
+		}
+		if int(resp.Index) != i {
+			s.Reset()
+			return fmt.Errorf("asked for chunk %d, got chunk %d", i, resp.Index)
+		}
+		if err := d.manifest.VerifyChunk(i, resp.Data); err != nil {
+			// A corrupted chunk is fetched again on the next attempt.
+			s.Reset()
+			return err
+		}
+		if err := d.write(i, resp.Data); err != nil {
+			s.Reset()
+			return errPermanent{err}
+		}
+	}
+	return s.Close()
+}
+
+// write stores a verified chunk and records it as done.
+func (d *download) write(i int, data []byte) error {
+	if _, err := d.part.WriteAt(data, int64(i)*int64(d.manifest.ChunkSize)); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.done[i] {
+		d.done[i] = true
+		d.chunks++
+		d.bytes += int64(len(data))
+	}
+
+	// Saving after every chunk would make large transfers quadratic,
+	// losing up to a second of progress on a crash is fine.
+	if time.Since(d.lastSaved) > time.Second {
+		d.save()
+	}
+	d.report()
+	return nil
+}
+
+// save persists the list of done chunks. d.mu must be held.
+func (d *download) save() {
+	data, err := json.Marshal(downloadState{Manifest: d.manifest, Done: d.done})
+	if err != nil {
+		return
+	}
+	// Make sure the chunks are on disk before recording them as done.
+	if d.part.Sync() != nil {
+		return
+	}
+	if ioutil.WriteFile(d.statePath, data, 0644) == nil {
+		d.lastSaved = time.Now()
+	}
+}
+
+// report calls the progress callback. d.mu must be held, or no stream running.
+func (d *download) report() {
+	if d.progress != nil {
+		d.progress(Progress{Manifest: d.manifest, Chunks: d.chunks, Bytes: d.bytes})
+	}
+}
+
+// verify checks the hash of the complete file.
+func (d *download) verify() error {
+	if err := d.part.Truncate(d.manifest.Size); err != nil {
+		return err
+	}
+	if _, err := d.part.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, d.part); err != nil {
+		return err
+	}
+	if hex.EncodeToString(sum.Sum(nil)) != d.manifest.ID {
+		// Start over next time, something on disk is broken.
+		os.Remove(d.statePath)
+		return errors.New("file failed hash verification")
+	}
+	return nil
+}
+
+// install moves the verified file into place, once the part file is closed.
+func (d *download) install() (string, error) {
+	if err := os.Rename(d.part.Name(), d.path); err != nil {
+		return "", err
+	}
+	os.Remove(d.statePath)
+	return d.path, nil
+}
@@ -0,0 +1,166 @@
+package filetransfer
+
+import (
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+
+	ggio "github.com/gogo/protobuf/io"
+	pb "github.com/libp2p/go-libp2p-examples/filetransfer/pb"
+)
+
+// Sender serves shared files to receivers.
+type Sender struct {
+	host host.Host
+
+	mu    sync.RWMutex
+	files map[string]*sharedFile // by file id
+}
+
+type sharedFile struct {
+	path     string
+	manifest *Manifest
+}
+
+// NewSender attaches a file sender to the given host.
+func NewSender(h host.Host) *Sender {
+	s := &Sender{host: h, files: make(map[string]*sharedFile)}
+	h.SetStreamHandler(ManifestProtocol, s.handleManifest)
+	h.SetStreamHandler(ChunkProtocol, s.handleChunks)
+	return s
+}
+
+// Share makes the file at path available to receivers and returns its
+// manifest. The file must not change while it is shared.
+func (s *Sender) Share(path string, chunkSize int) (*Manifest, error) {
+	m, err := NewManifest(path, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.files[m.ID] = &sharedFile{path: path, manifest: m}
+	s.mu.Unlock()
+	return m, nil
+}
+
+// Unshare stops serving the file with the given id.
+func (s *Sender) Unshare(id string) {
+	s.mu.Lock()
+	delete(s.files, id)
+	s.mu.Unlock()
+}
+
+// Close removes the stream handlers from the host.
+func (s *Sender) Close() error {
+	s.host.RemoveStreamHandler(ManifestProtocol)
+	s.host.RemoveStreamHandler(ChunkProtocol)
+	return nil
+}
+
+// lookup finds a shared file. An empty id matches the only shared file.
+func (s *Sender) lookup(id string) *sharedFile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id == "" && len(s.files) == 1 {
+		for _, f := range s.files {
+			return f
+		}
+	}
+	return s.files[id]
+}
+
+func (s *Sender) handleManifest(stream network.Stream) {
+	defer stream.Close()
+
+	req := &pb.ManifestRequest{}
+	if err := ggio.NewDelimitedReader(stream, maxMessageSize).ReadMsg(req); err != nil {
+		log.Println(err)
+		stream.Reset()
+		return
+	}
+
+	resp := &pb.ManifestResponse{}
+	if f := s.lookup(req.FileId); f != nil {
+		resp.Manifest = f.manifest.toProto()
+	} else {
+		resp.Error = "file not found"
+	}
+
+	if err := ggio.NewDelimitedWriter(stream).WriteMsg(resp); err != nil {
+		log.Println(err)
+		stream.Reset()
+	}
+}
+
+// handleChunks answers chunk requests until the receiver closes the stream.
+func (s *Sender) handleChunks(stream network.Stream) {
+	reader := ggio.NewDelimitedReader(stream, maxMessageSize)
+	writer := ggio.NewDelimitedWriter(stream)
+
+	// Keep the last file open, a receiver usually asks for many
+	// chunks of the same file.
+	var (
+		open   *sharedFile
+		handle *os.File
+	)
+	defer func() {
+		if handle != nil {
+			handle.Close()
+		}
+	}()
+
+	for {
+		req := &pb.ChunkRequest{}
+		if err := reader.ReadMsg(req); err != nil {
+			if err == io.EOF {
+				stream.Close()
+			} else {
+				stream.Reset()
+			}
+			return
+		}
+
+		resp := &pb.ChunkResponse{Index: req.Index}
+		f := s.lookup(req.FileId)
+		switch {
+		case f == nil:
+			resp.Error = "file not found"
+		case int(req.Index) >= f.manifest.NumChunks():
+			resp.Error = "chunk out of range"
+		default:
+			if f != open {
+				if handle != nil {
+					handle.Close()
+					handle = nil
+				}
+				open = nil
+				h, err := os.Open(f.path)
+				if err != nil {
+					resp.Error = err.Error()
+					break
+				}
+				open, handle = f, h
+			}
+
+			i := int(req.Index)
+			data := make([]byte, f.manifest.ChunkLen(i))
+			if _, err := handle.ReadAt(data, int64(i)*int64(f.manifest.ChunkSize)); err != nil {
+				resp.Error = err.Error()
+				break
+			}
+			resp.Data = data
+		}
+
+		if err := writer.WriteMsg(resp); err != nil {
+			log.Println(err)
+			stream.Reset()
+			return
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package filetransfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+
+	"github.com/libp2p/go-libp2p-examples/harness"
+)
+
+const (
+	testChunkSize = 4 << 10
+	testChunks    = 32
+)
+
+// newTransfer shares a random file of testChunks chunks from host 0 of a
+// two host mocknet, and returns the network, the manifest, the file's
+// content, the directory to receive it in, and the number of chunk streams
+// the sender accepted so far.
+func newTransfer(t *testing.T, opts ...harness.Option) (*harness.Network, *Sender, *Manifest, []byte, string, *int32) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	nw, err := harness.New(ctx, 2, append([]harness.Option{harness.Mocknet()}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nw.Close() })
+	if err := nw.Introduce(1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	data := make([]byte, testChunks*testChunkSize-100) // a short last chunk
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "data.bin")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSender(nw.Host(0))
+	t.Cleanup(func() { s.Close() })
+	m, err := s.Share(path, testChunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := new(int32)
+	nw.Host(0).SetStreamHandler(ChunkProtocol, func(st network.Stream) {
+		atomic.AddInt32(streams, 1)
+		s.handleChunks(st)
+	})
+
+	recvDir := filepath.Join(dir, "received")
+	return nw, s, m, data, recvDir, streams
+}
+
+func TestReceive(t *testing.T) {
+	nw, _, m, data, dir, streams := newTransfer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	path, err := Receive(ctx, nw.Host(1), nw.Host(0).ID(), m.ID, ReceiveOptions{Dir: dir, Concurrency: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("received file differs from the original")
+	}
+	if n := atomic.LoadInt32(streams); n != 4 {
+		t.Errorf("chunks fetched over %d streams, want 4", n)
+	}
+	for _, leftover := range []string{path + ".part", path + ".part.json"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("%s left behind", leftover)
+		}
+	}
+}
+
+func TestReceiveResume(t *testing.T) {
+	// Latency keeps the transfer slow enough to be cut half way.
+	nw, _, m, data, dir, _ := newTransfer(t, harness.LinkOptions(mocknet.LinkOptions{Latency: 5 * time.Millisecond}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// Cut the connection, for good, once a few chunks arrived.
+	var cut sync.Once
+	opts := ReceiveOptions{
+		Dir:         dir,
+		Concurrency: 1,
+		Retries:     1,
+		RetryDelay:  10 * time.Millisecond,
+		Progress: func(p Progress) {
+			if p.Chunks == 4 {
+				cut.Do(func() { go nw.Disconnect(1, 0) })
+			}
+		},
+	}
+	if _, err := Receive(ctx, nw.Host(1), nw.Host(0).ID(), m.ID, opts); err == nil {
+		t.Fatal("download survived the disconnection")
+	}
+	if _, err := os.Stat(filepath.Join(dir, m.Name+".part.json")); err != nil {
+		t.Fatalf("no download state to resume from: %s", err)
+	}
+
+	// Reconnect, and count the chunks the second attempt fetches.
+	if err := nw.Introduce(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	var reports []Progress
+	opts.Progress = func(p Progress) { reports = append(reports, p) }
+	path, err := Receive(ctx, nw.Host(1), nw.Host(0).ID(), m.ID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("resumed file differs from the original")
+	}
+
+	// The first report is the state the download resumed from, every
+	// other one a chunk fetched.
+	resumed := reports[0].Chunks
+	if resumed < 4 || resumed >= testChunks {
+		t.Fatalf("resumed with %d chunks of %d", resumed, testChunks)
+	}
+	if fetched := len(reports) - 1; fetched != testChunks-resumed {
+		t.Errorf("fetched %d chunks after resuming with %d of %d, want only the %d missing", fetched, resumed, testChunks, testChunks-resumed)
+	}
+}
+
+func TestReceiveCorruptChunk(t *testing.T) {
+	nw, s, m, data, dir, _ := newTransfer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Change chunk 5 on disk after the manifest was computed, so the
+	// sender serves data that doesn't match its hash.
+	const bad = 5
+	f, err := os.OpenFile(s.lookup(m.ID).path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := append([]byte(nil), data[bad*testChunkSize:(bad+1)*testChunkSize]...)
+	corrupt[0] ^= 0xff
+	if _, err := f.WriteAt(corrupt, bad*testChunkSize); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	opts := ReceiveOptions{Dir: dir, Concurrency: 2, Retries: 2, RetryDelay: 10 * time.Millisecond}
+	_, err = Receive(ctx, nw.Host(1), nw.Host(0).ID(), m.ID, opts)
+	if err == nil || !strings.Contains(err.Error(), "chunk 5 failed hash verification") {
+		t.Fatalf("got %v, want chunk %d to fail verification", err, bad)
+	}
+	if _, err := os.Stat(filepath.Join(dir, m.Name)); !os.IsNotExist(err) {
+		t.Fatal("a file with a corrupted chunk was installed")
+	}
+
+	// The other chunks were kept: once the sender serves the right data,
+	// only the rejected chunk is fetched.
+	f, err = os.OpenFile(s.lookup(m.ID).path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(data[bad*testChunkSize:(bad+1)*testChunkSize], bad*testChunkSize); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	var reports []Progress
+	opts.Progress = func(p Progress) { reports = append(reports, p) }
+	if _, err := Receive(ctx, nw.Host(1), nw.Host(0).ID(), m.ID, opts); err != nil {
+		t.Fatal(err)
+	}
+	if reports[0].Chunks != testChunks-1 || len(reports) != 2 {
+		t.Errorf("resumed with %d chunks and fetched %d, want %d and 1", reports[0].Chunks, len(reports)-1, testChunks-1)
+	}
+}
@@ -0,0 +1,244 @@
+// Package harness runs several libp2p hosts inside one process, so that the
+// protocols of the examples can be exercised from `go test` and checked with
+// assertions rather than by reading what they print.
+//
+// Hosts either listen on real loopback sockets, which exercises the full
+// transport, security and muxer stack and supports options such as relays, or
+// live in a mocknet, where only peers that share a link can dial each other.
+package harness
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Network is a set of hosts running in the current process.
+type Network struct {
+	// Hosts holds the hosts in creation order. Topologies refer to hosts
+	// by their index in this slice.
+	Hosts []host.Host
+
+	mn mocknet.Mocknet // nil for loopback networks
+}
+
+type config struct {
+	mocknet     bool
+	linkOptions mocknet.LinkOptions
+	seed        int64
+	hostOptions []libp2p.Option
+	perHost     func(i int) []libp2p.Option
+}
+
+// Option configures a Network.
+type Option func(*config)
+
+// Mocknet puts the hosts in a mocknet instead of on loopback sockets.
+func Mocknet() Option {
+	return func(c *config) {
+		c.mocknet = true
+	}
+}
+
+// LinkOptions sets the default latency and bandwidth of mocknet links.
+func LinkOptions(opts mocknet.LinkOptions) Option {
+	return func(c *config) {
+		c.linkOptions = opts
+	}
+}
+
+// Seed makes host identities deterministic: the same seed always yields the
+// same peer IDs, in the same order. A seed of 0 is the default, and leaves
+// identities random.
+func Seed(seed int64) Option {
+	return func(c *config) {
+		c.seed = seed
+	}
+}
+
+// HostOptions adds libp2p options to every loopback host. They are applied
+// after the harness' own options, so they may override the listen addresses.
+// Mocknet hosts aren't built by libp2p.New, New fails if both are given.
+func HostOptions(opts ...libp2p.Option) Option {
+	return func(c *config) {
+		c.hostOptions = append(c.hostOptions, opts...)
+	}
+}
+
+// PerHostOptions adds libp2p options to individual loopback hosts, for
+// instance to turn a single host into a relay. Like HostOptions, it can't be
+// combined with Mocknet.
+func PerHostOptions(f func(i int) []libp2p.Option) Option {
+	return func(c *config) {
+		c.perHost = f
+	}
+}
+
+// New starts n hosts. The hosts are not connected to each other yet, see
+// Network.Connect.
+func New(ctx context.Context, n int, opts ...Option) (*Network, error) {
+	var cfg config
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.mocknet && (len(cfg.hostOptions) > 0 || cfg.perHost != nil) {
+		return nil, errors.New("harness: host options only apply to loopback hosts, not to a mocknet")
+	}
+
+	var r io.Reader = rand.Reader
+	if cfg.seed != 0 {
+		r = mrand.New(mrand.NewSource(cfg.seed))
+	}
+
+	nw := &Network{}
+	if cfg.mocknet {
+		nw.mn = mocknet.New(ctx)
+		nw.mn.SetLinkDefaults(cfg.linkOptions)
+	}
+
+	for i := 0; i < n; i++ {
+		priv, _, err := crypto.GenerateEd25519Key(r)
+		if err != nil {
+			nw.Close()
+			return nil, err
+		}
+
+		h, err := nw.newHost(ctx, i, priv, &cfg)
+		if err != nil {
+			nw.Close()
+			return nil, fmt.Errorf("host %d: %w", i, err)
+		}
+		nw.Hosts = append(nw.Hosts, h)
+	}
+	return nw, nil
+}
+
+func (nw *Network) newHost(ctx context.Context, i int, priv crypto.PrivKey, cfg *config) (host.Host, error) {
+	if nw.mn != nil {
+		// Mocknet addresses are never dialed, they only need to be unique.
+		addr, err := ma.NewMultiaddr(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", 10000+i))
+		if err != nil {
+			return nil, err
+		}
+		return nw.mn.AddPeer(priv, addr)
+	}
+
+	opts := []libp2p.Option{
+		libp2p.Identity(priv),
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+	}
+	opts = append(opts, cfg.hostOptions...)
+	if cfg.perHost != nil {
+		opts = append(opts, cfg.perHost(i)...)
+	}
+	return libp2p.New(ctx, opts...)
+}
+
+// Host returns the i-th host.
+func (nw *Network) Host(i int) host.Host {
+	return nw.Hosts[i]
+}
+
+// AddrInfo returns the peer ID and addresses of the i-th host.
+func (nw *Network) AddrInfo(i int) peer.AddrInfo {
+	h := nw.Hosts[i]
+	return peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}
+}
+
+// P2pAddr returns the first address of the i-th host, including its peer ID,
+// as the examples expect on their command lines.
+func (nw *Network) P2pAddr(i int) ma.Multiaddr {
+	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: nw.Hosts[i].ID(), Addrs: nw.Hosts[i].Addrs()[:1]})
+	if err != nil {
+		panic(err)
+	}
+	return addrs[0]
+}
+
+// Introduce tells host i the addresses of host j, without connecting them.
+// In a mocknet it also links the two hosts, so that i is able to dial j.
+func (nw *Network) Introduce(i, j int) error {
+	a, b := nw.Hosts[i], nw.Hosts[j]
+	if nw.mn != nil && len(nw.mn.LinksBetweenPeers(a.ID(), b.ID())) == 0 {
+		if _, err := nw.mn.LinkPeers(a.ID(), b.ID()); err != nil {
+			return err
+		}
+	}
+	a.Peerstore().AddAddrs(b.ID(), b.Addrs(), peerstore.PermanentAddrTTL)
+	return nil
+}
+
+// ConnectPair connects host i to host j.
+func (nw *Network) ConnectPair(ctx context.Context, i, j int) error {
+	if err := nw.Introduce(i, j); err != nil {
+		return err
+	}
+	if err := nw.Hosts[i].Connect(ctx, nw.AddrInfo(j)); err != nil {
+		return fmt.Errorf("connecting host %d to host %d: %w", i, j, err)
+	}
+	return nil
+}
+
+// Connect connects the hosts along the links of the topology.
+func (nw *Network) Connect(ctx context.Context, t Topology) error {
+	for _, l := range t(len(nw.Hosts)) {
+		if err := nw.ConnectPair(ctx, l.From, l.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Disconnect closes all connections between host i and host j. In a
+// mocknet the hosts are unlinked too, so they can't reconnect.
+func (nw *Network) Disconnect(i, j int) error {
+	a, b := nw.Hosts[i], nw.Hosts[j]
+	if nw.mn != nil {
+		// Unlink first, or a dial racing the disconnection reconnects them.
+		if err := nw.mn.UnlinkPeers(a.ID(), b.ID()); err != nil {
+			return err
+		}
+		return nw.mn.DisconnectPeers(a.ID(), b.ID())
+	}
+	return a.Network().ClosePeer(b.ID())
+}
+
+// Close shuts all hosts down.
+func (nw *Network) Close() error {
+	var firstErr error
+	for _, h := range nw.Hosts {
+		if err := h.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Eventually calls cond until it returns true, for at most timeout. It is
+// meant for conditions that become true asynchronously, like a connection
+// showing up on the remote side.
+func Eventually(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if cond() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
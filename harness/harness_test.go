@@ -0,0 +1,153 @@
+package harness
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/network"
+)
+
+const testProtocol = "/harness-test/1.0.0"
+
+// modes are the two kinds of networks every test runs on.
+var modes = []struct {
+	name string
+	opts []Option
+}{
+	{"loopback", nil},
+	{"mocknet", []Option{Mocknet()}},
+}
+
+func newNetwork(t *testing.T, n int, opts ...Option) *Network {
+	t.Helper()
+	nw, err := New(context.Background(), n, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nw.Close() })
+	return nw
+}
+
+func TestInbox(t *testing.T) {
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			nw := newNetwork(t, 3, mode.opts...)
+			if err := nw.Connect(ctx, Star(0)); err != nil {
+				t.Fatal(err)
+			}
+
+			inbox := NewInbox(nw.Host(0), testProtocol)
+			for i := 1; i < 3; i++ {
+				if err := Send(ctx, nw.Host(i), nw.Host(0).ID(), testProtocol, "hello", "bye"); err != nil {
+					t.Fatalf("host %d: %s", i, err)
+				}
+				for _, text := range []string{"hello", "bye"} {
+					if err := inbox.Expect(5*time.Second, nw.Host(i).ID(), text); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConnect(t *testing.T) {
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			nw := newNetwork(t, 3, mode.opts...)
+			if err := nw.Connect(ctx, Line); err != nil {
+				t.Fatal(err)
+			}
+
+			connected := func(i, j int) bool {
+				return nw.Host(i).Network().Connectedness(nw.Host(j).ID()) == network.Connected
+			}
+			if !connected(0, 1) || !connected(1, 2) {
+				t.Fatal("hosts of the line are not connected")
+			}
+			if connected(0, 2) {
+				t.Fatal("the ends of the line are connected")
+			}
+
+			if err := nw.Disconnect(0, 1); err != nil {
+				t.Fatal(err)
+			}
+			if !Eventually(5*time.Second, func() bool { return !connected(0, 1) && !connected(1, 0) }) {
+				t.Fatal("hosts still connected after Disconnect")
+			}
+		})
+	}
+}
+
+func TestDisconnectUnlinksMocknet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	nw := newNetwork(t, 2, Mocknet())
+	if err := nw.ConnectPair(ctx, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	NewInbox(nw.Host(1), testProtocol)
+	if err := nw.Disconnect(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Send(ctx, nw.Host(0), nw.Host(1).ID(), testProtocol, "hello"); err == nil {
+		t.Fatal("sent a message to an unlinked host")
+	}
+}
+
+func TestSeed(t *testing.T) {
+	ids := func(opts ...Option) []string {
+		nw := newNetwork(t, 2, append(opts, Mocknet())...)
+		return []string{nw.Host(0).ID().Pretty(), nw.Host(1).ID().Pretty()}
+	}
+
+	if a, b := ids(Seed(1)), ids(Seed(1)); !reflect.DeepEqual(a, b) {
+		t.Errorf("the same seed gave peer IDs %v and %v", a, b)
+	}
+	if a, b := ids(Seed(1)), ids(Seed(2)); reflect.DeepEqual(a, b) {
+		t.Errorf("seeds 1 and 2 both gave peer IDs %v", a)
+	}
+	if a, b := ids(Seed(0)), ids(Seed(0)); reflect.DeepEqual(a, b) {
+		t.Errorf("seed 0 gave peer IDs %v twice", a)
+	}
+}
+
+func TestHostOptionsWithMocknet(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"HostOptions":    HostOptions(libp2p.NoListenAddrs),
+		"PerHostOptions": PerHostOptions(func(int) []libp2p.Option { return nil }),
+	} {
+		if nw, err := New(context.Background(), 1, Mocknet(), opt); err == nil {
+			nw.Close()
+			t.Errorf("%s accepted with Mocknet", name)
+		}
+	}
+}
+
+func TestTopologies(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		t    Topology
+		want []Link
+	}{
+		{"line", Line, []Link{{0, 1}, {1, 2}, {2, 3}}},
+		{"ring", Ring, []Link{{0, 1}, {1, 2}, {2, 3}, {3, 0}}},
+		{"full mesh", FullMesh, []Link{{0, 1}, {0, 2}, {0, 3}, {1, 2}, {1, 3}, {2, 3}}},
+		{"star", Star(1), []Link{{0, 1}, {2, 1}, {3, 1}}},
+		{"links", Links(Link{3, 0}), []Link{{3, 0}}},
+	} {
+		if got := tc.t(4); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
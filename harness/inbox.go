@@ -0,0 +1,92 @@
+package harness
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+)
+
+// Message is a line received by an Inbox.
+type Message struct {
+	From peer.ID
+	Text string
+}
+
+// Inbox records the newline terminated messages a host receives on a
+// protocol, so a test can wait for them instead of scraping stdout.
+type Inbox struct {
+	msgs chan Message
+}
+
+// NewInbox registers a stream handler for pid on h that records every line
+// read from incoming streams. Streams are read until the remote side closes
+// them.
+func NewInbox(h host.Host, pid protocol.ID) *Inbox {
+	in := &Inbox{msgs: make(chan Message, 1024)}
+	h.SetStreamHandler(pid, in.handle)
+	return in
+}
+
+func (in *Inbox) handle(s network.Stream) {
+	from := s.Conn().RemotePeer()
+	r := bufio.NewReader(s)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			in.msgs <- Message{From: from, Text: strings.TrimSuffix(line, "\n")}
+		}
+		if err != nil {
+			s.Close()
+			return
+		}
+	}
+}
+
+// Next returns the next message, waiting until one arrives or ctx is done.
+func (in *Inbox) Next(ctx context.Context) (Message, error) {
+	select {
+	case m := <-in.msgs:
+		return m, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+// Expect waits up to timeout for the next message and checks that it is text,
+// sent by from. An empty from matches any sender.
+func (in *Inbox) Expect(timeout time.Duration, from peer.ID, text string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	m, err := in.Next(ctx)
+	if err != nil {
+		return fmt.Errorf("waiting for %q: %w", text, err)
+	}
+	if m.Text != text || (from != "" && m.From != from) {
+		return fmt.Errorf("expected %q from %s, got %q from %s", text, from, m.Text, m.From)
+	}
+	return nil
+}
+
+// Send opens a stream from h to p on pid, writes the lines and closes the
+// stream.
+func Send(ctx context.Context, h host.Host, p peer.ID, pid protocol.ID, lines ...string) error {
+	s, err := h.NewStream(ctx, p, pid)
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if _, err := s.Write([]byte(l + "\n")); err != nil {
+			s.Reset()
+			return err
+		}
+	}
+	return s.Close()
+}
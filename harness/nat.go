@@ -0,0 +1,68 @@
+package harness
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/control"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/libp2p/go-libp2p-examples/relaydial"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+// NATGate makes a host behave as if it were behind a NAT, as a connection
+// gater: incoming connections are refused, unless they are relayed or come
+// from an address the host dialed recently. Such a dial opened a hole in
+// the NAT, as it would on a real one (a port restricted cone NAT).
+//
+// The TCP transport dials from the port it listens on, so a peer's dials
+// come from the address we dial it at. That is also what a NAT preserving
+// ports does, and what makes hole punching possible.
+type NATGate struct {
+	lifetime time.Duration
+
+	mu    sync.Mutex
+	holes map[string]time.Time // remote ip:port -> when we last dialed it
+}
+
+// NewNATGate returns a gate whose holes stay open for lifetime after the
+// last dial through them. Pass it to a host with libp2p.ConnectionGater.
+func NewNATGate(lifetime time.Duration) *NATGate {
+	return &NATGate{lifetime: lifetime, holes: make(map[string]time.Time)}
+}
+
+func (g *NATGate) InterceptPeerDial(peer.ID) bool { return true }
+
+func (g *NATGate) InterceptAddrDial(_ peer.ID, a ma.Multiaddr) bool {
+	if na, err := manet.ToNetAddr(a); err == nil {
+		g.mu.Lock()
+		g.holes[na.String()] = time.Now()
+		g.mu.Unlock()
+	}
+	return true
+}
+
+func (g *NATGate) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	if _, relayed := relaydial.RelayOf(addrs.RemoteMultiaddr()); relayed {
+		// It comes over our own connection to the relay.
+		return true
+	}
+	na, err := manet.ToNetAddr(addrs.RemoteMultiaddr())
+	if err != nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return time.Since(g.holes[na.String()]) < g.lifetime
+}
+
+func (g *NATGate) InterceptSecured(network.Direction, peer.ID, network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (g *NATGate) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
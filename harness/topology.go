@@ -0,0 +1,59 @@
+package harness
+
+// Link is a connection from host From to host To, both given as indexes into
+// Network.Hosts.
+type Link struct {
+	From, To int
+}
+
+// Topology returns the links to create between n hosts.
+type Topology func(n int) []Link
+
+// Line connects every host to the next one: 0-1, 1-2, ...
+func Line(n int) []Link {
+	var links []Link
+	for i := 0; i+1 < n; i++ {
+		links = append(links, Link{i, i + 1})
+	}
+	return links
+}
+
+// Ring is a Line where the last host is connected back to the first one.
+func Ring(n int) []Link {
+	links := Line(n)
+	if n > 2 {
+		links = append(links, Link{n - 1, 0})
+	}
+	return links
+}
+
+// FullMesh connects every host to every other host.
+func FullMesh(n int) []Link {
+	var links []Link
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			links = append(links, Link{i, j})
+		}
+	}
+	return links
+}
+
+// Star connects every host to the given center host.
+func Star(center int) Topology {
+	return func(n int) []Link {
+		var links []Link
+		for i := 0; i < n; i++ {
+			if i != center {
+				links = append(links, Link{i, center})
+			}
+		}
+		return links
+	}
+}
+
+// Links is a fixed topology made of the given links.
+func Links(links ...Link) Topology {
+	return func(int) []Link {
+		return links
+	}
+}
@@ -0,0 +1,493 @@
+// Package holepunch upgrades relayed connections to direct ones.
+//
+// Two peers behind NATs can't dial each other, but they can be connected
+// through a relay. Over that connection they tell each other their public
+// addresses, then dial each other at the same time: the outgoing dials open
+// holes in both NATs, through which the dials of the other side get in.
+// This is the direct connection upgrade through relay (DCUtR) of libp2p.
+package holepunch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	ggio "github.com/gogo/protobuf/io"
+	pb "github.com/libp2p/go-libp2p-examples/holepunch/pb"
+	"github.com/libp2p/go-libp2p-examples/relaydial"
+	identify "github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Protocol is the ID of the hole punching protocol. The messages are those
+// of DCUtR, but go-libp2p v0.13 can't resolve a TCP simultaneous open, so
+// the relayed connection is closed before the dials: the protocols differ.
+const Protocol = "/libp2p-examples/holepunch/1.0.0"
+
+// maxMessageSize bounds the protocol messages.
+const maxMessageSize = 4096
+
+// handshakeTimeout bounds the exchange of messages over the relay.
+const handshakeTimeout = 10 * time.Second
+
+// quietPeriod is how long a relayed connection must carry no stream before
+// it is upgraded. Closing it resets the streams it carries, and peers
+// usually open theirs right after connecting.
+const quietPeriod = time.Second
+
+// retryDelay is how long a peer whose upgrade failed stays relayed, before
+// its connections are upgraded automatically again.
+const retryDelay = 5 * time.Minute
+
+// ErrStillRelayed is the error of an upgrade where both peers dialed, but
+// neither got through: the peer that dialed through the relay reconnected
+// through it.
+var ErrStillRelayed = errors.New("holepunch: direct dials failed, the connection is relayed again")
+
+// ErrInUse is the error of an upgrade given up because a stream was opened
+// over the relayed connection during the exchange. Closing the connection
+// would have reset it.
+var ErrInUse = errors.New("holepunch: the relayed connection is in use, not upgrading it")
+
+// Options configures a Service. The zero value is usable.
+type Options struct {
+	// IdleTimeout is how long an upgrade waits for the relayed connection
+	// to carry no stream, before giving up. Defaults to a minute.
+	IdleTimeout time.Duration
+	// DialTimeout bounds the direct dials. Defaults to 10 seconds.
+	DialTimeout time.Duration
+	// Manual disables the upgrade of incoming relayed connections, they
+	// are only upgraded by calling Upgrade.
+	Manual bool
+	// OnResult, if set, is called after every upgrade, on both sides.
+	OnResult func(Result)
+}
+
+// Result is the outcome of an upgrade.
+type Result struct {
+	Peer peer.ID
+	// Initiator is true on the side that started the upgrade, the side
+	// the relayed connection was dialed to.
+	Initiator bool
+	// Path is how the peers are connected afterwards.
+	Path relaydial.Path
+	// RTT is the round trip time through the relay, measured by the
+	// initiator.
+	RTT time.Duration
+	// Err is nil if the peers are connected directly.
+	Err error
+}
+
+// Service upgrades the relayed connections of a host.
+type Service struct {
+	host   host.Host
+	opts   Options
+	ctx    context.Context
+	cancel context.CancelFunc
+	notif  *network.NotifyBundle
+
+	mu      sync.Mutex
+	running map[peer.ID]bool      // peers with an upgrade going on
+	failed  map[peer.ID]time.Time // when the last upgrade of a peer failed
+}
+
+// New attaches a hole punching service to the given host. Both peers of a
+// relayed connection need one.
+func New(h host.Host, opts Options) *Service {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = time.Minute
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		host:    h,
+		opts:    opts,
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[peer.ID]bool),
+		failed:  make(map[peer.ID]time.Time),
+	}
+	h.SetStreamHandler(Protocol, s.handleStream)
+	s.notif = &network.NotifyBundle{ConnectedF: s.connected}
+	h.Network().Notify(s.notif)
+	return s
+}
+
+// Close removes the stream handler from the host, and stops the upgrades.
+func (s *Service) Close() error {
+	s.host.RemoveStreamHandler(Protocol)
+	s.host.Network().StopNotify(s.notif)
+	s.cancel()
+	return nil
+}
+
+// connected starts upgrading incoming relayed connections.
+func (s *Service) connected(_ network.Network, c network.Conn) {
+	if s.opts.Manual || c.Stat().Direction != network.DirInbound || !isRelayed(c) {
+		return
+	}
+	p := c.RemotePeer()
+	s.mu.Lock()
+	recent := time.Since(s.failed[p]) < retryDelay
+	s.mu.Unlock()
+	if recent {
+		return
+	}
+	go s.Upgrade(s.ctx, p)
+}
+
+// Upgrade replaces the relayed connection to p with a direct one. It waits
+// for the relayed connection to be idle, exchanges addresses with p over
+// it, closes it and dials p while p dials us. Its result is also passed to
+// Options.OnResult.
+//
+// The peer that dialed the relayed connection reconnects through the relay
+// if the direct dials fail. Upgrade should therefore be called on the side
+// the relayed connection was dialed to, which is what happens automatically
+// unless Options.Manual is set.
+func (s *Service) Upgrade(ctx context.Context, p peer.ID) (relaydial.Path, error) {
+	if !s.start(p) {
+		return relaydial.Path{}, fmt.Errorf("holepunch: upgrade with %s already going on", p.Pretty())
+	}
+	defer s.done(p)
+
+	res := Result{Peer: p, Initiator: true}
+	res.Path, res.RTT, res.Err = s.initiate(ctx, p)
+	s.report(res)
+	return res.Path, res.Err
+}
+
+func (s *Service) initiate(ctx context.Context, p peer.ID) (relaydial.Path, time.Duration, error) {
+	path, ok := relaydial.PathTo(s.host, p)
+	if !ok {
+		return path, 0, fmt.Errorf("holepunch: not connected to %s", p.Pretty())
+	}
+	if path.Direct() {
+		return path, 0, nil // nothing to upgrade
+	}
+	relayed := relayedConn(s.host, p)
+	if err := s.waitIdle(ctx, relayed); err != nil {
+		return path, 0, err
+	}
+
+	// The relayed connection is the only one, the stream goes over it.
+	st, err := s.host.NewStream(network.WithNoDial(ctx, "hole punch"), p, Protocol)
+	if err != nil {
+		return path, 0, err
+	}
+	st.SetDeadline(time.Now().Add(handshakeTimeout))
+	w := ggio.NewDelimitedWriter(st)
+	r := ggio.NewDelimitedReader(st, maxMessageSize)
+
+	start := time.Now()
+	if err := w.WriteMsg(s.connectMsg()); err != nil {
+		st.Reset()
+		return path, 0, err
+	}
+	var msg pb.HolePunch
+	if err := r.ReadMsg(&msg); err != nil {
+		st.Reset()
+		return path, 0, err
+	}
+	rtt := time.Since(start)
+	if msg.GetType() != pb.HolePunch_CONNECT {
+		st.Reset()
+		return path, rtt, fmt.Errorf("holepunch: expected CONNECT, got %s", msg.GetType())
+	}
+	addrs := parseAddrs(msg.GetObsAddrs())
+	if len(addrs) == 0 {
+		st.Reset()
+		return path, rtt, errors.New("holepunch: the peer has no public address")
+	}
+	if inUse(relayed) {
+		st.Reset()
+		return path, rtt, ErrInUse
+	}
+	if err := w.WriteMsg(&pb.HolePunch{Type: pb.HolePunch_SYNC.Enum()}); err != nil {
+		st.Reset()
+		return path, rtt, err
+	}
+	st.CloseWrite()
+
+	// The peer closes the stream once it got the SYNC, and dials. Closing
+	// the relayed connection any sooner could lose the SYNC. Whether the
+	// dial of the peer or ours comes first, the last one finds a hole.
+	st.Read(make([]byte, 1))
+	st.Close()
+	relayed.Close()
+	path, err = s.dial(ctx, p, addrs, nil)
+	return path, rtt, err
+}
+
+// handleStream answers an upgrade started by the other side of a relayed
+// connection.
+func (s *Service) handleStream(st network.Stream) {
+	relayed := st.Conn()
+	p := relayed.RemotePeer()
+	if !isRelayed(relayed) || !s.start(p) {
+		st.Reset()
+		return
+	}
+	defer s.done(p)
+
+	res := Result{Peer: p}
+	res.Path, res.Err = s.respond(st)
+	if res.Err != nil && res.Path == (relaydial.Path{}) {
+		res.Path, _ = relaydial.PathTo(s.host, p)
+	}
+	s.report(res)
+}
+
+func (s *Service) respond(st network.Stream) (relaydial.Path, error) {
+	relayed := st.Conn()
+	st.SetDeadline(time.Now().Add(handshakeTimeout))
+	w := ggio.NewDelimitedWriter(st)
+	r := ggio.NewDelimitedReader(st, maxMessageSize)
+
+	var msg pb.HolePunch
+	if err := r.ReadMsg(&msg); err != nil {
+		st.Reset()
+		return relaydial.Path{}, err
+	}
+	if msg.GetType() != pb.HolePunch_CONNECT {
+		st.Reset()
+		return relaydial.Path{}, fmt.Errorf("holepunch: expected CONNECT, got %s", msg.GetType())
+	}
+	addrs := parseAddrs(msg.GetObsAddrs())
+	if inUse(relayed) {
+		// Not answering makes the initiator give up too.
+		st.Reset()
+		return relaydial.Path{}, ErrInUse
+	}
+	if err := w.WriteMsg(s.connectMsg()); err != nil {
+		st.Reset()
+		return relaydial.Path{}, err
+	}
+	msg.Reset()
+	if err := r.ReadMsg(&msg); err != nil {
+		st.Reset()
+		return relaydial.Path{}, err
+	}
+	if msg.GetType() != pb.HolePunch_SYNC {
+		st.Reset()
+		return relaydial.Path{}, fmt.Errorf("holepunch: expected SYNC, got %s", msg.GetType())
+	}
+	st.Close()
+
+	// Keep the circuit address: we dialed the relayed connection, we can
+	// dial it again if the direct dials fail.
+	circuit := relayed.RemoteMultiaddr()
+	relayed.Close()
+	return s.dial(s.ctx, relayed.RemotePeer(), addrs, circuit)
+}
+
+// dial connects to p at addrs. If that fails, it waits for the dial of p
+// to get in, and then goes through the circuit address, if given. Relayed
+// connections opened to p in the meantime are closed, so that new streams
+// go over the direct one, but not before the streams they carry are done.
+func (s *Service) dial(ctx context.Context, p peer.ID, addrs []ma.Multiaddr, circuit ma.Multiaddr) (relaydial.Path, error) {
+	d := relaydial.NewDialer(s.host, nil)
+	d.Timeout = s.opts.DialTimeout
+	path, err := d.Connect(ctx, peer.AddrInfo{ID: p, Addrs: addrs})
+	if err == nil && !path.Direct() {
+		err = ErrStillRelayed
+	}
+	if err != nil {
+		path, err = s.waitDirect(ctx, p, err)
+	}
+	if err != nil {
+		if circuit == nil {
+			return path, err
+		}
+		if path, cerr := d.Connect(ctx, peer.AddrInfo{ID: p, Addrs: []ma.Multiaddr{circuit}}); cerr == nil {
+			return path, ErrStillRelayed
+		}
+		return path, err
+	}
+
+	for _, c := range s.host.Network().ConnsToPeer(p) {
+		if isRelayed(c) {
+			s.closeDone(ctx, c)
+		}
+	}
+	return path, nil
+}
+
+// closeDone closes c once the streams it carries are done. The swarm picks
+// the connection with the most streams for new ones, so c is waited for up
+// to the dial timeout, and then left to closeIdle.
+func (s *Service) closeDone(ctx context.Context, c network.Conn) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.DialTimeout)
+	defer cancel()
+	for len(c.GetStreams()) > 0 {
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			go s.closeIdle(c)
+			return
+		}
+	}
+	c.Close()
+}
+
+// closeIdle closes c once it has carried no stream for the quiet period,
+// unless the service is closed first.
+func (s *Service) closeIdle(c network.Conn) {
+	for {
+		err := s.waitIdle(s.ctx, c)
+		if err == nil {
+			c.Close()
+			return
+		}
+		if s.ctx.Err() != nil || !isOpen(s.host, c) {
+			return
+		}
+	}
+}
+
+// waitDirect waits up to the dial timeout for a direct connection to p,
+// and returns err if none shows up.
+func (s *Service) waitDirect(ctx context.Context, p peer.ID, err error) (relaydial.Path, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.DialTimeout)
+	defer cancel()
+	for {
+		if path, ok := relaydial.PathTo(s.host, p); ok && path.Direct() {
+			return path, nil
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return relaydial.Path{}, err
+		}
+	}
+}
+
+// waitIdle waits until c has carried no stream for the quiet period.
+func (s *Service) waitIdle(ctx context.Context, c network.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.IdleTimeout)
+	defer cancel()
+	quietSince := time.Now()
+	for {
+		if !isOpen(s.host, c) {
+			return errors.New("holepunch: the relayed connection is gone")
+		}
+		if len(c.GetStreams()) > 0 {
+			quietSince = time.Now()
+		} else if time.Since(quietSince) >= quietPeriod {
+			return nil
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return errors.New("holepunch: the relayed connection is still in use")
+		}
+	}
+}
+
+// connectMsg returns a CONNECT message with our addresses: those we listen
+// on, and those peers observed, except relayed ones.
+func (s *Service) connectMsg() *pb.HolePunch {
+	addrs := s.host.Addrs()
+	if ids, ok := s.host.(interface{ IDService() *identify.IDService }); ok {
+		addrs = append(addrs, ids.IDService().OwnObservedAddrs()...)
+	}
+	msg := &pb.HolePunch{Type: pb.HolePunch_CONNECT.Enum()}
+	seen := make(map[string]bool)
+	for _, a := range addrs {
+		if _, relayed := relaydial.RelayOf(a); relayed || seen[string(a.Bytes())] {
+			continue
+		}
+		seen[string(a.Bytes())] = true
+		msg.ObsAddrs = append(msg.ObsAddrs, a.Bytes())
+	}
+	return msg
+}
+
+// start marks an upgrade with p as going on, unless one already is.
+func (s *Service) start(p peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[p] {
+		return false
+	}
+	s.running[p] = true
+	return true
+}
+
+func (s *Service) done(p peer.ID) {
+	s.mu.Lock()
+	delete(s.running, p)
+	s.mu.Unlock()
+}
+
+// report records the outcome of an upgrade, and passes it on.
+func (s *Service) report(res Result) {
+	s.mu.Lock()
+	if res.Err != nil {
+		s.failed[res.Peer] = time.Now()
+	} else {
+		delete(s.failed, res.Peer)
+	}
+	s.mu.Unlock()
+	if s.opts.OnResult != nil {
+		s.opts.OnResult(res)
+	}
+}
+
+// relayedConn returns a relayed connection of h to p, or nil if there is
+// none.
+func relayedConn(h host.Host, p peer.ID) network.Conn {
+	for _, c := range h.Network().ConnsToPeer(p) {
+		if isRelayed(c) {
+			return c
+		}
+	}
+	return nil
+}
+
+// isOpen tells whether c is still a connection of h.
+func isOpen(h host.Host, c network.Conn) bool {
+	for _, open := range h.Network().ConnsToPeer(c.RemotePeer()) {
+		if open == c {
+			return true
+		}
+	}
+	return false
+}
+
+// inUse tells whether the relayed connection c carries a stream besides the
+// one of the upgrade. It was idle when the initiator started, but either
+// side may have opened a stream since.
+func inUse(c network.Conn) bool {
+	return len(c.GetStreams()) > 1
+}
+
+func isRelayed(c network.Conn) bool {
+	_, relayed := relaydial.RelayOf(c.RemoteMultiaddr())
+	return relayed
+}
+
+// parseAddrs parses the addresses of a CONNECT message, skipping those
+// that don't parse and relayed ones.
+func parseAddrs(raw [][]byte) []ma.Multiaddr {
+	var addrs []ma.Multiaddr
+	for _, b := range raw {
+		a, err := ma.NewMultiaddrBytes(b)
+		if err != nil {
+			continue
+		}
+		if _, relayed := relaydial.RelayOf(a); !relayed {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,71 @@
+package holepunch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	circuit "github.com/libp2p/go-libp2p-circuit"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/libp2p/go-libp2p-examples/harness"
+	"github.com/libp2p/go-libp2p-examples/relaydial"
+)
+
+// TestUpgrade connects hosts 0 and 2, both behind NATs, through the relay
+// host 1, and checks that their connection ends up direct.
+func TestUpgrade(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	nw, err := harness.New(ctx, 3, harness.PerHostOptions(func(i int) []libp2p.Option {
+		if i == 1 {
+			return []libp2p.Option{libp2p.EnableRelay(circuit.OptHop)}
+		}
+		return []libp2p.Option{libp2p.ConnectionGater(harness.NewNATGate(30 * time.Second)), libp2p.EnableRelay()}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Close()
+	if err := nw.Connect(ctx, harness.Star(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan Result, 1)
+	defer New(nw.Host(0), Options{OnResult: func(r Result) { results <- r }}).Close()
+	defer New(nw.Host(2), Options{}).Close()
+
+	dialer := relaydial.NewDialer(nw.Host(0), []peer.AddrInfo{nw.AddrInfo(1)})
+	path, err := dialer.Connect(ctx, peer.AddrInfo{ID: nw.Host(2).ID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Direct() {
+		t.Fatalf("connected %s, expected a relayed connection", path)
+	}
+
+	select {
+	case r := <-results:
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	case <-ctx.Done():
+		t.Fatal("no upgrade")
+	}
+	for _, ends := range [][2]int{{0, 2}, {2, 0}} {
+		path, ok := relaydial.PathTo(nw.Host(ends[0]), nw.Host(ends[1]).ID())
+		if !ok || !path.Direct() {
+			t.Errorf("host %d to host %d: connected %t, %s, expected a direct connection", ends[0], ends[1], ok, path)
+		}
+	}
+
+	inbox := harness.NewInbox(nw.Host(2), "/cats")
+	if err := harness.Send(ctx, nw.Host(0), nw.Host(2).ID(), "/cats", "meow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := inbox.Expect(5*time.Second, nw.Host(0).ID(), "meow"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,131 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: holepunch.proto
+
+package holepunch_pb
+
+import (
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+type HolePunch_Type int32
+
+const (
+	HolePunch_CONNECT HolePunch_Type = 100
+	HolePunch_SYNC    HolePunch_Type = 300
+)
+
+var HolePunch_Type_name = map[int32]string{
+	100: "CONNECT",
+	300: "SYNC",
+}
+
+var HolePunch_Type_value = map[string]int32{
+	"CONNECT": 100,
+	"SYNC":    300,
+}
+
+func (x HolePunch_Type) Enum() *HolePunch_Type {
+	p := new(HolePunch_Type)
+	*p = x
+	return p
+}
+
+func (x HolePunch_Type) String() string {
+	return proto.EnumName(HolePunch_Type_name, int32(x))
+}
+
+func (x *HolePunch_Type) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(HolePunch_Type_value, data, "HolePunch_Type")
+	if err != nil {
+		return err
+	}
+	*x = HolePunch_Type(value)
+	return nil
+}
+
+func (HolePunch_Type) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_290ddea0f23ef64a, []int{0, 0}
+}
+
+// the messages of the hole punching protocol, the same as those of the
+// direct connection upgrade through relay (DCUtR) spec
+type HolePunch struct {
+	Type *HolePunch_Type `protobuf:"varint,1,req,name=type,enum=holepunch.pb.HolePunch_Type" json:"type,omitempty"`
+	// addresses the sender can be dialed at: its listen addresses, and
+	// those its peers observed
+	ObsAddrs             [][]byte `protobuf:"bytes,2,rep,name=ObsAddrs" json:"ObsAddrs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HolePunch) Reset()         { *m = HolePunch{} }
+func (m *HolePunch) String() string { return proto.CompactTextString(m) }
+func (*HolePunch) ProtoMessage()    {}
+func (*HolePunch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_290ddea0f23ef64a, []int{0}
+}
+func (m *HolePunch) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HolePunch.Unmarshal(m, b)
+}
+func (m *HolePunch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HolePunch.Marshal(b, m, deterministic)
+}
+func (m *HolePunch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HolePunch.Merge(m, src)
+}
+func (m *HolePunch) XXX_Size() int {
+	return xxx_messageInfo_HolePunch.Size(m)
+}
+func (m *HolePunch) XXX_DiscardUnknown() {
+	xxx_messageInfo_HolePunch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HolePunch proto.InternalMessageInfo
+
+func (m *HolePunch) GetType() HolePunch_Type {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return HolePunch_CONNECT
+}
+
+func (m *HolePunch) GetObsAddrs() [][]byte {
+	if m != nil {
+		return m.ObsAddrs
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("holepunch.pb.HolePunch_Type", HolePunch_Type_name, HolePunch_Type_value)
+	proto.RegisterType((*HolePunch)(nil), "holepunch.pb.HolePunch")
+}
+
+func init() { proto.RegisterFile("holepunch.proto", fileDescriptor_290ddea0f23ef64a) }
+
+var fileDescriptor_290ddea0f23ef64a = []byte{
+	// 135 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0xcf, 0xc8, 0xcf, 0x49,
+	0x2d, 0x28, 0xcd, 0x4b, 0xce, 0xd0, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x41, 0x12, 0x48,
+	0x52, 0xaa, 0xe4, 0xe2, 0xf4, 0xc8, 0xcf, 0x49, 0x0d, 0x00, 0xf1, 0x85, 0x0c, 0xb8, 0x58, 0x4a,
+	0x2a, 0x0b, 0x52, 0x25, 0x18, 0x15, 0x98, 0x34, 0xf8, 0x8c, 0x64, 0xf4, 0x90, 0x55, 0xea, 0xc1,
+	0x95, 0xe9, 0x85, 0x54, 0x16, 0xa4, 0x06, 0x81, 0x55, 0x0a, 0x49, 0x71, 0x71, 0xf8, 0x27, 0x15,
+	0x3b, 0xa6, 0xa4, 0x14, 0x15, 0x4b, 0x30, 0x29, 0x30, 0x6b, 0xf0, 0x04, 0xc1, 0xf9, 0x4a, 0x72,
+	0x5c, 0x2c, 0x20, 0x95, 0x42, 0xdc, 0x5c, 0xec, 0xce, 0xfe, 0x7e, 0x7e, 0xae, 0xce, 0x21, 0x02,
+	0x29, 0x42, 0x9c, 0x5c, 0x2c, 0xc1, 0x91, 0x7e, 0xce, 0x02, 0x6b, 0x98, 0x00, 0x03, 0x00, 0x42,
+	0xa8, 0x3b, 0x8b, 0x9a, 0x00, 0x00, 0x00,
+}
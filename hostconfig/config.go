@@ -0,0 +1,333 @@
+// Package hostconfig builds libp2p hosts from a configuration file, so that
+// every command configures its host the same way: the identity, the
+// transports, security and stream multiplexers, the listen addresses, the
+// connection manager, routing, relaying and NAT traversal.
+//
+// Configuration files are YAML, or JSON if their name ends with .json:
+//
+//	identity:
+//	  file: identity.key
+//	  type: ed25519
+//	listen_addrs:
+//	  - /ip4/0.0.0.0/tcp/4001
+//	  - /ip4/0.0.0.0/tcp/4002/ws
+//	transports: [tcp, ws]
+//	security: [noise, tls]
+//	muxers: [yamux, mplex]
+//	conn_manager:
+//	  low_water: 100
+//	  high_water: 400
+//	  grace_period: 1m
+//	routing: dht
+//	relay:
+//	  auto: true
+//	nat:
+//	  port_map: true
+//
+// Everything is optional. What is left out is the libp2p default.
+package hostconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-examples/identity"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"gopkg.in/yaml.v2"
+)
+
+// Config describes a host.
+type Config struct {
+	Identity Identity `json:"identity" yaml:"identity"`
+	// ListenAddrs are the multiaddrs to listen on. The transport of each
+	// must be enabled.
+	ListenAddrs []string `json:"listen_addrs" yaml:"listen_addrs"`
+	// Transports are "tcp", "ws", and "quic" if the hostconfig/quic
+	// package is imported.
+	Transports []string `json:"transports" yaml:"transports"`
+	// Security lists the secure channels, "noise", "tls" and "secio", the
+	// preferred one first.
+	Security []string `json:"security" yaml:"security"`
+	// Muxers lists the stream multiplexers, "yamux" and "mplex", the
+	// preferred one first.
+	Muxers      []string     `json:"muxers" yaml:"muxers"`
+	ConnManager *ConnManager `json:"conn_manager" yaml:"conn_manager"`
+	// Routing is "dht", "dht-client", "dht-server", or empty for none. A
+	// "dht" is a server once AutoNAT found the host reachable.
+	Routing string `json:"routing" yaml:"routing"`
+	Relay   Relay  `json:"relay" yaml:"relay"`
+	NAT     NAT    `json:"nat" yaml:"nat"`
+}
+
+// Identity tells where the key of the host comes from.
+type Identity struct {
+	// File holds the key, and is created on first use, encrypted if
+	// $LIBP2P_IDENTITY_PASSPHRASE is set. Without a file, the host gets a
+	// new peer ID every time. A relative path is relative to the
+	// configuration file.
+	File string `json:"file" yaml:"file"`
+	// Type is the type of a new key, "ed25519" (the default),
+	// "secp256k1" or "rsa".
+	Type string `json:"type" yaml:"type"`
+	// Bits is the size of a new RSA key, 2048 at least.
+	Bits int `json:"bits" yaml:"bits"`
+	// Key, set by commands building a configuration in code, is used
+	// rather than File.
+	Key crypto.PrivKey `json:"-" yaml:"-"`
+}
+
+// ConnManager sets the connection manager watermarks: past HighWater
+// connections, the host closes the least useful ones down to LowWater,
+// sparing those younger than GracePeriod.
+type ConnManager struct {
+	LowWater    int      `json:"low_water" yaml:"low_water"`
+	HighWater   int      `json:"high_water" yaml:"high_water"`
+	GracePeriod Duration `json:"grace_period" yaml:"grace_period"`
+}
+
+// Relay configures circuit relaying.
+type Relay struct {
+	// Disabled turns off the relay transport: the host can neither dial
+	// nor be dialed through relays.
+	Disabled bool `json:"disabled" yaml:"disabled"`
+	// Hop makes the host relay connections for other peers.
+	Hop bool `json:"hop" yaml:"hop"`
+	// Auto makes the host advertise addresses on relays when AutoNAT
+	// finds it private. It needs routing to find relays, or Static ones.
+	Auto bool `json:"auto" yaml:"auto"`
+	// Static are the relays Auto picks from, as multiaddrs ending with
+	// /p2p/<peer ID>.
+	Static []string `json:"static" yaml:"static"`
+}
+
+// NAT configures NAT traversal.
+type NAT struct {
+	// PortMap asks the router to forward the ports of the host, through
+	// UPnP or NAT-PMP.
+	PortMap bool `json:"port_map" yaml:"port_map"`
+	// Service helps other peers find out whether they are behind a NAT,
+	// by dialing them back.
+	Service bool `json:"service" yaml:"service"`
+	// Reachability, "public" or "private", overrides what AutoNAT finds.
+	Reachability string `json:"reachability" yaml:"reachability"`
+}
+
+// Duration is a time.Duration written as in "1m30s".
+type Duration time.Duration
+
+// UnmarshalText parses a duration, for both YAML and JSON.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText writes a duration as time.Duration does.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Load reads and validates a configuration file.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.Identity.File != "" && !filepath.IsAbs(c.Identity.File) {
+		c.Identity.File = filepath.Join(filepath.Dir(path), c.Identity.File)
+	}
+	return c, nil
+}
+
+// Parse parses and validates a configuration, in JSON or in YAML. Unknown
+// fields are an error, as they are most likely typos.
+func Parse(data []byte, isJSON bool) (*Config, error) {
+	var c Config
+	if isJSON {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&c); err != nil {
+			return nil, err
+		}
+	} else if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Error lists what is wrong with a configuration.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid host configuration: " + strings.Join(e.Problems, "; ")
+}
+
+var (
+	securityNames = []string{"noise", "tls", "secio"}
+	muxerNames    = []string{"yamux", "mplex"}
+	routingNames  = []string{"dht", "dht-client", "dht-server"}
+)
+
+// Validate checks the configuration, and returns an *Error listing every
+// problem found.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Identity.Type != "" {
+		if _, err := identity.ParseKeyType(c.Identity.Type); err != nil {
+			add("%s", err)
+		}
+	}
+	if c.Identity.Bits < 0 {
+		add("identity: bits can't be negative")
+	}
+
+	transports := make(map[string]bool)
+	for _, t := range c.Transports {
+		if _, ok := transportOption(t); !ok {
+			add("unknown transport %q, use one of %s", t, strings.Join(transportNames(), ", "))
+		}
+		if transports[t] {
+			add("transport %q listed twice", t)
+		}
+		transports[t] = true
+	}
+	for _, s := range c.ListenAddrs {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			add("listen address %q: %s", s, err)
+			continue
+		}
+		t := transportOf(a)
+		switch {
+		case t == "":
+			add("listen address %q: no transport listens on it", s)
+		case len(c.Transports) == 0 && t != "tcp" && t != "ws":
+			// The default transports are TCP and websocket.
+			add("listen address %q: transport %q is not enabled", s, t)
+		case len(c.Transports) > 0 && !transports[t]:
+			add("listen address %q: transport %q is not enabled", s, t)
+		}
+	}
+	checkNames(add, "security", c.Security, securityNames)
+	checkNames(add, "muxer", c.Muxers, muxerNames)
+
+	if cm := c.ConnManager; cm != nil {
+		if cm.LowWater < 0 || cm.HighWater <= 0 {
+			add("conn_manager: low_water can't be negative, and high_water must be positive")
+		} else if cm.LowWater > cm.HighWater {
+			add("conn_manager: low_water (%d) is above high_water (%d)", cm.LowWater, cm.HighWater)
+		}
+		if cm.GracePeriod < 0 {
+			add("conn_manager: grace_period can't be negative")
+		}
+	}
+
+	if c.Routing != "" && !contains(routingNames, c.Routing) {
+		add("unknown routing %q, use one of %s", c.Routing, strings.Join(routingNames, ", "))
+	}
+
+	if c.Relay.Disabled && (c.Relay.Hop || c.Relay.Auto) {
+		add("relay: hop and auto need the relay transport, which is disabled")
+	}
+	if c.Relay.Auto && c.Routing == "" && len(c.Relay.Static) == 0 {
+		add("relay: auto needs routing to find relays, or static relays")
+	}
+	if len(c.Relay.Static) > 0 && !c.Relay.Auto {
+		add("relay: static relays are only used with auto")
+	}
+	if _, err := parseAddrInfos(c.Relay.Static); err != nil {
+		add("relay: %s", err)
+	}
+
+	switch c.NAT.Reachability {
+	case "", "public", "private":
+	default:
+		add("nat: unknown reachability %q, use public or private", c.NAT.Reachability)
+	}
+
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+// checkNames checks that names are known, and listed once.
+func checkNames(add func(string, ...interface{}), what string, names, known []string) {
+	seen := make(map[string]bool)
+	for _, n := range names {
+		if !contains(known, n) {
+			add("unknown %s %q, use one of %s", what, n, strings.Join(known, ", "))
+		}
+		if seen[n] {
+			add("%s %q listed twice", what, n)
+		}
+		seen[n] = true
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// transportOf returns the name of the transport listening on a.
+func transportOf(a ma.Multiaddr) string {
+	var t string
+	ma.ForEach(a, func(c ma.Component) bool {
+		switch c.Protocol().Code {
+		case ma.P_TCP:
+			t = "tcp"
+		case ma.P_WS:
+			t = "ws"
+		case ma.P_QUIC:
+			t = "quic"
+		}
+		return true
+	})
+	return t
+}
+
+// parseAddrInfos parses multiaddrs ending with /p2p/<peer ID>.
+func parseAddrInfos(addrs []string) ([]peer.AddrInfo, error) {
+	var infos []peer.AddrInfo
+	for _, s := range addrs {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", s, err)
+		}
+		pi, err := peer.AddrInfoFromP2pAddr(a)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", s, err)
+		}
+		infos = append(infos, *pi)
+	}
+	return infos, nil
+}
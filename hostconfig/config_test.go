@@ -0,0 +1,222 @@
+package hostconfig
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const relayAddr = "/ip4/1.2.3.4/tcp/4001/p2p/12D3KooWQ7dytM3jxTKzC9ZCxxWxBVnDswT6Dj5EAFZLE3KQA5bT"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{name: "empty"},
+		{
+			name: "complete",
+			cfg: Config{
+				Identity:    Identity{File: "identity.key", Type: "rsa", Bits: 3072},
+				ListenAddrs: []string{"/ip4/0.0.0.0/tcp/4001", "/ip4/0.0.0.0/tcp/4002/ws"},
+				Transports:  []string{"tcp", "ws"},
+				Security:    []string{"noise", "tls"},
+				Muxers:      []string{"yamux", "mplex"},
+				ConnManager: &ConnManager{LowWater: 100, HighWater: 400, GracePeriod: Duration(time.Minute)},
+				Routing:     "dht",
+				Relay:       Relay{Hop: true, Auto: true, Static: []string{relayAddr}},
+				NAT:         NAT{PortMap: true, Service: true, Reachability: "private"},
+			},
+		},
+		{
+			name: "unknown names",
+			cfg: Config{
+				Transports: []string{"udp"},
+				Security:   []string{"ssl"},
+				Muxers:     []string{"spdy"},
+				Routing:    "gossip",
+				NAT:        NAT{Reachability: "sometimes"},
+			},
+			want: []string{
+				`unknown transport "udp", use one of tcp, ws`,
+				`unknown security "ssl", use one of noise, tls, secio`,
+				`unknown muxer "spdy", use one of yamux, mplex`,
+				`unknown routing "gossip", use one of dht, dht-client, dht-server`,
+				`nat: unknown reachability "sometimes", use public or private`,
+			},
+		},
+		{
+			name: "duplicates",
+			cfg: Config{
+				Transports: []string{"tcp", "ws", "tcp"},
+				Security:   []string{"noise", "noise"},
+				Muxers:     []string{"mplex", "yamux", "mplex"},
+			},
+			want: []string{
+				`transport "tcp" listed twice`,
+				`security "noise" listed twice`,
+				`muxer "mplex" listed twice`,
+			},
+		},
+		{
+			name: "identity",
+			cfg:  Config{Identity: Identity{Type: "dsa", Bits: -1}},
+			want: []string{
+				`identity: unsupported key type "dsa", use one of ed25519, secp256k1, rsa`,
+				"identity: bits can't be negative",
+			},
+		},
+		{
+			name: "low water above high water",
+			cfg:  Config{ConnManager: &ConnManager{LowWater: 50, HighWater: 20}},
+			want: []string{"conn_manager: low_water (50) is above high_water (20)"},
+		},
+		{
+			name: "low water equal to high water",
+			cfg:  Config{ConnManager: &ConnManager{LowWater: 20, HighWater: 20}},
+		},
+		{
+			name: "negative low water",
+			cfg:  Config{ConnManager: &ConnManager{LowWater: -1, HighWater: 20}},
+			want: []string{"conn_manager: low_water can't be negative, and high_water must be positive"},
+		},
+		{
+			name: "zero high water",
+			cfg:  Config{ConnManager: &ConnManager{}},
+			want: []string{"conn_manager: low_water can't be negative, and high_water must be positive"},
+		},
+		{
+			name: "negative grace period",
+			cfg:  Config{ConnManager: &ConnManager{LowWater: 1, HighWater: 2, GracePeriod: Duration(-time.Second)}},
+			want: []string{"conn_manager: grace_period can't be negative"},
+		},
+		{
+			name: "listen address of a default transport",
+			cfg:  Config{ListenAddrs: []string{"/ip4/0.0.0.0/tcp/4001", "/ip6/::/tcp/4002/ws"}},
+		},
+		{
+			name: "listen address of a transport left out",
+			cfg: Config{
+				ListenAddrs: []string{"/ip4/0.0.0.0/tcp/4001", "/ip4/0.0.0.0/tcp/4002/ws"},
+				Transports:  []string{"tcp"},
+			},
+			want: []string{`listen address "/ip4/0.0.0.0/tcp/4002/ws": transport "ws" is not enabled`},
+		},
+		{
+			name: "quic listen address with the default transports",
+			cfg:  Config{ListenAddrs: []string{"/ip4/0.0.0.0/udp/4001/quic"}},
+			want: []string{`listen address "/ip4/0.0.0.0/udp/4001/quic": transport "quic" is not enabled`},
+		},
+		{
+			name: "listen address without a transport",
+			cfg:  Config{ListenAddrs: []string{"/ip4/0.0.0.0/udp/4001"}},
+			want: []string{`listen address "/ip4/0.0.0.0/udp/4001": no transport listens on it`},
+		},
+		{
+			name: "hop with the relay disabled",
+			cfg:  Config{Relay: Relay{Disabled: true, Hop: true}},
+			want: []string{"relay: hop and auto need the relay transport, which is disabled"},
+		},
+		{
+			name: "auto with the relay disabled",
+			cfg:  Config{Routing: "dht", Relay: Relay{Disabled: true, Auto: true}},
+			want: []string{"relay: hop and auto need the relay transport, which is disabled"},
+		},
+		{
+			name: "auto without routing",
+			cfg:  Config{Relay: Relay{Auto: true}},
+			want: []string{"relay: auto needs routing to find relays, or static relays"},
+		},
+		{
+			name: "auto with routing",
+			cfg:  Config{Routing: "dht-client", Relay: Relay{Auto: true}},
+		},
+		{
+			name: "auto with static relays",
+			cfg:  Config{Relay: Relay{Auto: true, Static: []string{relayAddr}}},
+		},
+		{
+			name: "static relays without auto",
+			cfg:  Config{Relay: Relay{Static: []string{relayAddr}}},
+			want: []string{"relay: static relays are only used with auto"},
+		},
+		{
+			name: "static relay without a peer ID",
+			cfg:  Config{Relay: Relay{Auto: true, Static: []string{"/ip4/1.2.3.4/tcp/4001"}}},
+			want: []string{`relay: "/ip4/1.2.3.4/tcp/4001": invalid p2p multiaddr`},
+		},
+		{
+			name: "every problem at once",
+			cfg: Config{
+				ListenAddrs: []string{"/ip4/0.0.0.0/tcp/4002/ws"},
+				Transports:  []string{"tcp", "tcp"},
+				ConnManager: &ConnManager{LowWater: 5, HighWater: 1},
+				Relay:       Relay{Static: []string{relayAddr}},
+			},
+			want: []string{
+				`transport "tcp" listed twice`,
+				`listen address "/ip4/0.0.0.0/tcp/4002/ws": transport "ws" is not enabled`,
+				"conn_manager: low_water (5) is above high_water (1)",
+				"relay: static relays are only used with auto",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			var cerr *Error
+			if !errors.As(err, &cerr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if len(cerr.Problems) != len(tt.want) {
+				t.Fatalf("got problems %q, want %q", cerr.Problems, tt.want)
+			}
+			for i := range tt.want {
+				if cerr.Problems[i] != tt.want[i] {
+					t.Errorf("problem %d: got %q, want %q", i, cerr.Problems[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	yamlCfg := "listen_addrs: [/ip4/0.0.0.0/tcp/4001]\nconn_manager:\n  low_water: 1\n  high_water: 2\n  grace_period: 1m30s\n"
+	c, err := Parse([]byte(yamlCfg), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Duration(c.ConnManager.GracePeriod); got != 90*time.Second {
+		t.Errorf("grace period: got %s, want 1m30s", got)
+	}
+
+	jsonCfg := `{"listen_addrs": ["/ip4/0.0.0.0/tcp/4001"], "routing": "dht"}`
+	if c, err = Parse([]byte(jsonCfg), true); err != nil {
+		t.Fatal(err)
+	}
+	if c.Routing != "dht" {
+		t.Errorf("routing: got %q, want dht", c.Routing)
+	}
+
+	// Misspelled fields are errors, not silently ignored.
+	if _, err := Parse([]byte("listen_adrs: [/ip4/0.0.0.0/tcp/4001]\n"), false); err == nil {
+		t.Error("YAML with an unknown field parsed")
+	}
+	if _, err := Parse([]byte(`{"routnig": "dht"}`), true); err == nil {
+		t.Error("JSON with an unknown field parsed")
+	}
+
+	// Parse validates what it parsed.
+	var cerr *Error
+	if _, err := Parse([]byte("routing: gossip\n"), false); !errors.As(err, &cerr) {
+		t.Errorf("got %v, want an *Error", err)
+	}
+}
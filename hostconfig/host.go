@@ -0,0 +1,210 @@
+package hostconfig
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	connmgr "github.com/libp2p/go-libp2p-connmgr"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/routing"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	mplex "github.com/libp2p/go-libp2p-mplex"
+	noise "github.com/libp2p/go-libp2p-noise"
+	secio "github.com/libp2p/go-libp2p-secio"
+	libp2ptls "github.com/libp2p/go-libp2p-tls"
+	yamux "github.com/libp2p/go-libp2p-yamux"
+	tcp "github.com/libp2p/go-tcp-transport"
+	ws "github.com/libp2p/go-ws-transport"
+
+	circuit "github.com/libp2p/go-libp2p-circuit"
+	"github.com/libp2p/go-libp2p-examples/identity"
+	"github.com/libp2p/go-libp2p-examples/natstatus"
+)
+
+var (
+	transportsMu     sync.Mutex
+	transportOptions = map[string]libp2p.Option{
+		"tcp": libp2p.Transport(tcp.NewTCPTransport),
+		"ws":  libp2p.Transport(ws.New),
+	}
+)
+
+// RegisterTransport makes a transport available to configurations, under
+// the given name. Transports most commands don't need register themselves
+// when their package is imported, as hostconfig/quic does.
+func RegisterTransport(name string, opt libp2p.Option) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	transportOptions[name] = opt
+}
+
+func transportOption(name string) (libp2p.Option, bool) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	opt, ok := transportOptions[name]
+	return opt, ok
+}
+
+func transportNames() []string {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	var names []string
+	for name := range transportOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Host is a host built from a configuration.
+type Host struct {
+	host.Host
+	// DHT is the DHT the host routes with, nil without routing.
+	DHT *dht.IpfsDHT
+	// Monitor reports the reachability of the host.
+	Monitor *natstatus.Monitor
+}
+
+// Close stops the monitor and the DHT, and closes the host.
+func (h *Host) Close() error {
+	h.Monitor.Close()
+	if h.DHT != nil {
+		h.DHT.Close()
+	}
+	return h.Host.Close()
+}
+
+// New builds a host as configured. The extra options are passed on to
+// libp2p.New after those of the configuration.
+func New(ctx context.Context, c *Config, extra ...libp2p.Option) (*Host, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	h := &Host{Monitor: natstatus.NewMonitor()}
+
+	opts, err := c.options(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	lh, err := libp2p.New(ctx, append(opts, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	h.Host = lh
+	if err := h.Monitor.Start(lh); err != nil {
+		lh.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// PrivKey returns the key of the configured identity, loading or creating
+// its file, or generating one if there is no file.
+func (c *Config) PrivKey() (crypto.PrivKey, error) {
+	if c.Identity.Key != nil {
+		return c.Identity.Key, nil
+	}
+	keyType := crypto.Ed25519
+	if c.Identity.Type != "" {
+		var err error
+		if keyType, err = identity.ParseKeyType(c.Identity.Type); err != nil {
+			return nil, err
+		}
+	}
+	if c.Identity.File == "" {
+		return identity.Generate(keyType, c.Identity.Bits)
+	}
+	return identity.LoadOrCreate(c.Identity.File, keyType, identity.Passphrase())
+}
+
+// options returns the libp2p options of the configuration. The DHT and
+// the monitor of h are set up as the host is built, the DHT runs until ctx
+// is done or the host is closed.
+func (c *Config) options(ctx context.Context, h *Host) ([]libp2p.Option, error) {
+	priv, err := c.PrivKey()
+	if err != nil {
+		return nil, err
+	}
+	opts := []libp2p.Option{libp2p.Identity(priv)}
+
+	if len(c.ListenAddrs) > 0 {
+		opts = append(opts, libp2p.ListenAddrStrings(c.ListenAddrs...))
+	}
+	for _, t := range c.Transports {
+		opt, _ := transportOption(t)
+		opts = append(opts, opt)
+	}
+	for _, s := range c.Security {
+		switch s {
+		case "noise":
+			opts = append(opts, libp2p.Security(noise.ID, noise.New))
+		case "tls":
+			opts = append(opts, libp2p.Security(libp2ptls.ID, libp2ptls.New))
+		case "secio":
+			opts = append(opts, libp2p.Security(secio.ID, secio.New))
+		}
+	}
+	for _, m := range c.Muxers {
+		switch m {
+		case "yamux":
+			opts = append(opts, libp2p.Muxer("/yamux/1.0.0", yamux.DefaultTransport))
+		case "mplex":
+			opts = append(opts, libp2p.Muxer("/mplex/6.7.0", mplex.DefaultTransport))
+		}
+	}
+
+	if cm := c.ConnManager; cm != nil {
+		opts = append(opts, libp2p.ConnectionManager(connmgr.NewConnManager(
+			cm.LowWater, cm.HighWater, time.Duration(cm.GracePeriod))))
+	}
+
+	if c.Routing != "" {
+		mode := dht.ModeAuto
+		switch c.Routing {
+		case "dht-client":
+			mode = dht.ModeClient
+		case "dht-server":
+			mode = dht.ModeServer
+		}
+		opts = append(opts, libp2p.Routing(func(lh host.Host) (routing.PeerRouting, error) {
+			var err error
+			h.DHT, err = dht.New(ctx, lh, dht.Mode(mode))
+			return h.DHT, err
+		}))
+	}
+
+	switch {
+	case c.Relay.Disabled:
+		opts = append(opts, libp2p.DisableRelay())
+	case c.Relay.Hop:
+		opts = append(opts, libp2p.EnableRelay(circuit.OptHop))
+	}
+	if c.Relay.Auto {
+		opts = append(opts, libp2p.EnableAutoRelay())
+		if len(c.Relay.Static) > 0 {
+			relays, err := parseAddrInfos(c.Relay.Static)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, libp2p.StaticRelays(relays))
+		}
+	}
+
+	if c.NAT.PortMap {
+		opts = append(opts, h.Monitor.NATPortMap())
+	}
+	if c.NAT.Service {
+		opts = append(opts, libp2p.EnableNATService())
+	}
+	switch c.NAT.Reachability {
+	case "public":
+		opts = append(opts, libp2p.ForceReachabilityPublic())
+	case "private":
+		opts = append(opts, libp2p.ForceReachabilityPrivate())
+	}
+	return opts, nil
+}
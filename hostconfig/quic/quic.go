@@ -0,0 +1,16 @@
+// Package quic registers the QUIC transport with hostconfig, as "quic".
+// Commands that offer QUIC import it for its side effect:
+//
+//	import _ "github.com/libp2p/go-libp2p-examples/hostconfig/quic"
+package quic
+
+import (
+	"github.com/libp2p/go-libp2p"
+	libp2pquic "github.com/libp2p/go-libp2p-quic-transport"
+
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+)
+
+func init() {
+	hostconfig.RegisterTransport("quic", libp2p.Transport(libp2pquic.NewTransport))
+}
@@ -11,11 +11,13 @@ import (
 	"strings"
 
 	// We need to import libp2p's libraries that we use in this project.
-	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+	"github.com/libp2p/go-libp2p-examples/identity"
+	"github.com/libp2p/go-libp2p-examples/relaydial"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr-net"
@@ -27,10 +29,15 @@ import (
 // libp2p handle them to the right handler functions.
 const Protocol = "/proxy-example/0.0.1"
 
-// makeRandomHost creates a libp2p host with a randomly generated identity.
-// This step is described in depth in other tutorials.
-func makeRandomHost(port int) host.Host {
-	host, err := libp2p.New(context.Background(), libp2p.ListenAddrStrings(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)))
+// makeRandomHost creates a libp2p host as cfg says, with a randomly
+// generated identity unless cfg names a key file. This step is described in
+// depth in other tutorials. The host listens on the given port of the
+// loopback interface if cfg lists no address to listen on.
+func makeRandomHost(port int, cfg *hostconfig.Config) host.Host {
+	if len(cfg.ListenAddrs) == 0 {
+		cfg.ListenAddrs = []string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)}
+	}
+	host, err := hostconfig.New(context.Background(), cfg)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -44,6 +51,7 @@ type ProxyService struct {
 	host      host.Host
 	dest      peer.ID
 	proxyAddr ma.Multiaddr
+	dialer    *relaydial.Dialer
 }
 
 // NewProxyService attaches a proxy service to the given libp2p Host.
@@ -56,7 +64,8 @@ type ProxyService struct {
 // perform the proxied http requests it receives from a different peer.
 //
 // The addresses for the dest peer should be part of the host's peerstore.
-func NewProxyService(h host.Host, proxyAddr ma.Multiaddr, dest peer.ID) *ProxyService {
+// When dest can't be dialed at those, it is reached through the relays.
+func NewProxyService(h host.Host, proxyAddr ma.Multiaddr, dest peer.ID, relays []peer.AddrInfo) *ProxyService {
 	// We let our host know that it needs to handle streams tagged with the
 	// protocol id that we have defined, and then handle them to
 	// our own streamHandling function.
@@ -72,6 +81,7 @@ func NewProxyService(h host.Host, proxyAddr ma.Multiaddr, dest peer.ID) *ProxySe
 		host:      h,
 		dest:      dest,
 		proxyAddr: proxyAddr,
+		dialer:    relaydial.NewDialer(h, relays),
 	}
 }
 
@@ -130,6 +140,13 @@ func (p *ProxyService) Serve() {
 	_, serveArgs, _ := manet.DialArgs(p.proxyAddr)
 	fmt.Println("proxy listening on ", serveArgs)
 	if p.dest != "" {
+		// Connecting now tells how dest is reached, requests connect
+		// again if need be.
+		if path, err := p.dialer.Connect(context.Background(), peer.AddrInfo{ID: p.dest}); err != nil {
+			log.Println(err)
+		} else {
+			fmt.Printf("connected to %s: %s\n", p.dest.Pretty(), path)
+		}
 		http.ListenAndServe(serveArgs, p)
 	}
 }
@@ -146,7 +163,13 @@ func (p *ProxyService) Serve() {
 func (p *ProxyService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("proxying request for %s to peer %s\n", r.URL, p.dest.Pretty())
 	// We need to send the request to the remote libp2p peer, so
-	// we open a stream to it
+	// we connect to it, directly or through a relay, and open a stream
+	_, err := p.dialer.Connect(context.Background(), peer.AddrInfo{ID: p.dest})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
 	stream, err := p.host.NewStream(context.Background(), p.dest, Protocol)
 	// If an error happens, we write an error for response.
 	if err != nil {
@@ -195,32 +218,23 @@ func (p *ProxyService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // it to the given host's peerstore, so it knows how to
 // contact it. It returns the peer ID of the remote peer.
 func addAddrToPeerstore(h host.Host, addr string) peer.ID {
-	// The following code extracts target's the peer ID from the
-	// given multiaddress
+	// The following code extracts target's the peer ID, the last
+	// component, from the given multiaddress. What comes before is
+	// the address, which may go through a relay:
+	// /ip4/<a.b.c.d>/tcp/<port>/p2p/<relay>/p2p-circuit/p2p/<peer>
 	ipfsaddr, err := ma.NewMultiaddr(addr)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	pid, err := ipfsaddr.ValueForProtocol(ma.P_IPFS)
+	info, err := peer.AddrInfoFromP2pAddr(ipfsaddr)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	peerid, err := peer.IDB58Decode(pid)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	// Decapsulate the /ipfs/<peerID> part from the target
-	// /ip4/<a.b.c.d>/ipfs/<peer> becomes /ip4/<a.b.c.d>
-	targetPeerAddr, _ := ma.NewMultiaddr(
-		fmt.Sprintf("/ipfs/%s", peer.IDB58Encode(peerid)))
-	targetAddr := ipfsaddr.Decapsulate(targetPeerAddr)
-
-	// We have a peer ID and a targetAddr so we add
+	// We have a peer ID and its address so we add
 	// it to the peerstore so LibP2P knows how to contact it
-	h.Peerstore().AddAddr(peerid, targetAddr, peerstore.PermanentAddrTTL)
-	return peerid
+	h.Peerstore().AddAddrs(info.ID, info.Addrs, peerstore.PermanentAddrTTL)
+	return info.ID
 }
 
 const help = `
@@ -239,7 +253,7 @@ the remote peer, which makes it and sends the response back.
 
 func main() {
 	flag.Usage = func() {
-		fmt.Println(help)
+		fmt.Print(help)
 		flag.PrintDefaults()
 	}
 
@@ -247,13 +261,30 @@ func main() {
 	destPeer := flag.String("d", "", "destination peer address")
 	port := flag.Int("p", 9900, "proxy port")
 	p2pport := flag.Int("l", 12000, "libp2p listen port")
+	keyFile := flag.String("id", "", "load the host key from this file, creating it if needed")
+	keyTypeName := flag.String("key-type", "ed25519", "type of the key created for -id: "+strings.Join(identity.KeyTypes, ", "))
+	relayAddrs := flag.String("relay", "", "comma separated multiaddrs of relays, to be reached through, or to reach the destination peer through when it can't be dialed directly")
+	configFile := flag.String("config", "", "build the host from this configuration file, YAML or JSON, instead of -id and -key-type")
 	flag.Parse()
 
+	relays, err := relaydial.ParseRelays(*relayAddrs)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// The key file keeps the peer ID of the proxy the same across
+	// restarts.
+	cfg := &hostconfig.Config{Identity: hostconfig.Identity{File: *keyFile, Type: *keyTypeName}}
+	if *configFile != "" {
+		if cfg, err = hostconfig.Load(*configFile); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
 	// If we have a destination peer we will start a local server
 	if *destPeer != "" {
 		// We use p2pport+1 in order to not collide if the user
 		// is running the remote peer locally on that port
-		host := makeRandomHost(*p2pport + 1)
+		host := makeRandomHost(*p2pport+1, cfg)
 		// Make sure our host knows how to reach destPeer
 		destPeerID := addAddrToPeerstore(host, *destPeer)
 		proxyAddr, err := ma.NewMultiaddr(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", *port))
@@ -261,14 +292,23 @@ func main() {
 			log.Fatalln(err)
 		}
 		// Create the proxy service and start the http server
-		proxy := NewProxyService(host, proxyAddr, destPeerID)
+		proxy := NewProxyService(host, proxyAddr, destPeerID, relays)
 		proxy.Serve() // serve hangs forever
 	} else {
-		host := makeRandomHost(*p2pport)
+		host := makeRandomHost(*p2pport, cfg)
 		// In this case we only need to make sure our host
 		// knows how to handle incoming proxied requests from
 		// another peer.
-		_ = NewProxyService(host, nil, "")
+		_ = NewProxyService(host, nil, "", nil)
+		// Peers that can't dial us directly reach us through the
+		// relays, at these addresses.
+		relayed, err := relaydial.ConnectRelays(context.Background(), host, relays)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, a := range relayed {
+			fmt.Println(a)
+		}
 		<-make(chan struct{}) // hang forever
 	}
 
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-examples/harness"
+)
+
+// TestProxy sends an HTTP request through the proxy of host 0, which tunnels
+// it to host 1, which makes it.
+func TestProxy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	nw, err := harness.New(ctx, 3, harness.Mocknet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Close()
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.URL.Path)
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprint(w, "hello through libp2p")
+	}))
+	defer site.Close()
+
+	_ = NewProxyService(nw.Host(1), nil, "", nil)
+	if err := nw.Introduce(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(NewProxyService(nw.Host(0), nil, nw.Host(1).ID(), nil))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get(site.URL + "/cats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("status: got %d, want %d", resp.StatusCode, http.StatusTeapot)
+	}
+	if got := resp.Header.Get("X-Path"); got != "/cats" {
+		t.Errorf("X-Path header: got %q, want /cats", got)
+	}
+	if string(body) != "hello through libp2p" {
+		t.Errorf("body: got %q", body)
+	}
+	if len(nw.Host(0).Network().ConnsToPeer(nw.Host(1).ID())) == 0 {
+		t.Error("the request didn't go through host 1")
+	}
+
+	// Host 2 was never introduced to host 0, its proxy can't reach it.
+	unreachable := httptest.NewServer(NewProxyService(nw.Host(2), nil, nw.Host(0).ID(), nil))
+	defer unreachable.Close()
+	if proxyURL, err = url.Parse(unreachable.URL); err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	if resp, err = client.Get(site.URL); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status through an unreachable peer: got %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,288 @@
+// Package identity loads and stores the private keys that give libp2p hosts
+// their peer IDs. Examples that keep their key in a file keep the same peer ID
+// across restarts, which makes them much easier to dial and to reason about.
+//
+// Keys are stored PEM encoded. The body of the PEM block is the protobuf
+// serialized key as produced by crypto.MarshalPrivateKey. When a passphrase is
+// given, the body is sealed with AES-256-GCM using a key derived from the
+// passphrase with scrypt.
+package identity
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	pb "github.com/libp2p/go-libp2p-core/crypto/pb"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv is the environment variable examples read the key file
+// passphrase from. Reading it from the environment keeps it out of the
+// process list and the shell history.
+const PassphraseEnv = "LIBP2P_IDENTITY_PASSPHRASE"
+
+const (
+	plainBlockType     = "LIBP2P PRIVATE KEY"
+	encryptedBlockType = "ENCRYPTED LIBP2P PRIVATE KEY"
+
+	// scrypt parameters recommended for interactive logins.
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltLen      = 16
+)
+
+// ErrPassphraseRequired is returned when an encrypted key file is loaded
+// without a passphrase.
+var ErrPassphraseRequired = errors.New("identity: key file is encrypted, passphrase required")
+
+// ErrBadPassphrase is returned when an encrypted key file cannot be opened
+// with the given passphrase.
+var ErrBadPassphrase = errors.New("identity: wrong passphrase or corrupted key file")
+
+// KeyTypes lists the names accepted by ParseKeyType.
+var KeyTypes = []string{"ed25519", "secp256k1", "rsa"}
+
+// ParseKeyType maps a key type name ("ed25519", "secp256k1" or "rsa") to the
+// matching crypto key type constant.
+func ParseKeyType(name string) (int, error) {
+	switch strings.ToLower(name) {
+	case "ed25519":
+		return crypto.Ed25519, nil
+	case "secp256k1":
+		return crypto.Secp256k1, nil
+	case "rsa":
+		return crypto.RSA, nil
+	}
+	return 0, fmt.Errorf("identity: unsupported key type %q, use one of %s", name, strings.Join(KeyTypes, ", "))
+}
+
+// KeyTypeName returns the name of the given key's type, as accepted by
+// ParseKeyType.
+func KeyTypeName(key crypto.Key) string {
+	return strings.ToLower(pb.KeyType_name[int32(key.Type())])
+}
+
+// Generate creates a new private key of the given type. bits is only used
+// for RSA keys; a value below 2048 selects 2048 bits.
+func Generate(keyType int, bits int) (crypto.PrivKey, error) {
+	if keyType == crypto.RSA && bits < 2048 {
+		bits = 2048
+	}
+	priv, _, err := crypto.GenerateKeyPair(keyType, bits)
+	return priv, err
+}
+
+// Save writes the key to path, encrypting it when passphrase is not empty.
+// The file is created with 0600 permissions and never overwritten.
+func Save(path string, key crypto.PrivKey, passphrase []byte) error {
+	data, err := Encode(key, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads a key written by Save. passphrase is ignored for unencrypted
+// key files.
+func Load(path string, passphrase []byte) (crypto.PrivKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := Decode(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// LoadOrCreate loads the key stored at path. If the file does not exist, a
+// new key of the given type is generated and saved there first.
+func LoadOrCreate(path string, keyType int, passphrase []byte) (crypto.PrivKey, error) {
+	key, err := Load(path, passphrase)
+	if err == nil || !os.IsNotExist(err) {
+		return key, err
+	}
+
+	key, err = Generate(keyType, 2048)
+	if err != nil {
+		return nil, err
+	}
+	if err := Save(path, key, passphrase); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Encode serializes the key into a PEM block, sealing it with the passphrase
+// when one is given.
+func Encode(key crypto.PrivKey, passphrase []byte) ([]byte, error) {
+	raw, err := crypto.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passphrase) == 0 {
+		return pem.EncodeToMemory(&pem.Block{Type: plainBlockType, Bytes: raw}), nil
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	block := &pem.Block{
+		Type: encryptedBlockType,
+		Headers: map[string]string{
+			"Kdf":   fmt.Sprintf("scrypt,%d,%d,%d", scryptN, scryptR, scryptP),
+			"Salt":  base64.StdEncoding.EncodeToString(salt),
+			"Nonce": base64.StdEncoding.EncodeToString(nonce),
+		},
+		Bytes: aead.Seal(nil, nonce, raw, nil),
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// Decode parses a PEM block produced by Encode.
+func Decode(data []byte, passphrase []byte) (crypto.PrivKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("identity: no PEM encoded key found")
+	}
+
+	switch block.Type {
+	case plainBlockType:
+		return crypto.UnmarshalPrivateKey(block.Bytes)
+	case encryptedBlockType:
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+		raw, err := open(block, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.UnmarshalPrivateKey(raw)
+	}
+	return nil, fmt.Errorf("identity: unexpected PEM block type %q", block.Type)
+}
+
+// IsEncrypted reports whether the key file at path is passphrase protected.
+func IsEncrypted(path string) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return false, errors.New("identity: no PEM encoded key found")
+	}
+	return block.Type == encryptedBlockType, nil
+}
+
+// PeerID returns the peer ID derived from the key.
+func PeerID(key crypto.PrivKey) (peer.ID, error) {
+	return peer.IDFromPrivateKey(key)
+}
+
+// Passphrase returns the passphrase stored in the PassphraseEnv environment
+// variable, or nil if it is unset.
+func Passphrase() []byte {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return []byte(p)
+	}
+	return nil
+}
+
+func open(block *pem.Block, passphrase []byte) ([]byte, error) {
+	n, r, p, err := parseKdf(block.Headers["Kdf"])
+	if err != nil {
+		return nil, err
+	}
+	salt, err := base64.StdEncoding.DecodeString(block.Headers["Salt"])
+	if err != nil {
+		return nil, fmt.Errorf("identity: bad salt: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(block.Headers["Nonce"])
+	if err != nil {
+		return nil, fmt.Errorf("identity: bad nonce: %w", err)
+	}
+
+	aead, err := newAEAD(passphrase, salt, n, r, p)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("identity: bad nonce length")
+	}
+	raw, err := aead.Open(nil, nonce, block.Bytes, nil)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return raw, nil
+}
+
+// parseKdf parses the "scrypt,N,r,p" Kdf header of an encrypted key.
+func parseKdf(h string) (n, r, p int, err error) {
+	parts := strings.Split(h, ",")
+	if len(parts) != 4 || parts[0] != "scrypt" {
+		return 0, 0, 0, fmt.Errorf("identity: unsupported key derivation %q", h)
+	}
+	var params [3]int
+	for i, s := range parts[1:] {
+		if params[i], err = strconv.Atoi(s); err != nil {
+			return 0, 0, 0, fmt.Errorf("identity: bad key derivation parameter %q", s)
+		}
+	}
+	return params[0], params[1], params[2], nil
+}
+
+func newAEAD(passphrase, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	k, err := scrypt.Key(passphrase, salt, n, r, p, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	c, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(c)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-examples/identity"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+const help = `
+keytool manages the private key files that give the examples a stable peer ID.
+
+Usage: keytool gen [-type ed25519|secp256k1|rsa] [-bits 2048] [-encrypt] -o <file>
+       keytool inspect <file>
+       keytool export [-format id|pubkey|addr] [-addr <multiaddr>] <file>
+
+Encrypted key files use the passphrase stored in the ` + identity.PassphraseEnv + `
+environment variable.
+`
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = func() {
+		fmt.Print(help)
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "gen":
+		gen(args)
+	case "inspect":
+		inspect(args)
+	case "export":
+		export(args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// gen creates a new key file.
+func gen(args []string) {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	keyType := fs.String("type", "ed25519", "key type: "+strings.Join(identity.KeyTypes, ", "))
+	bits := fs.Int("bits", 2048, "key size in bits, RSA only")
+	encrypt := fs.Bool("encrypt", false, "encrypt the key with the passphrase from $"+identity.PassphraseEnv)
+	out := fs.String("o", "", "output file")
+	fs.Parse(args)
+
+	if *out == "" {
+		log.Fatalln("gen: an output file is required (-o)")
+	}
+
+	typ, err := identity.ParseKeyType(*keyType)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var passphrase []byte
+	if *encrypt {
+		if passphrase = identity.Passphrase(); passphrase == nil {
+			log.Fatalf("gen: -encrypt needs a passphrase in $%s\n", identity.PassphraseEnv)
+		}
+	}
+
+	priv, err := identity.Generate(typ, *bits)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := identity.Save(*out, priv, passphrase); err != nil {
+		log.Fatalln(err)
+	}
+
+	id, err := identity.PeerID(priv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Wrote %s key for peer %s to %s\n", identity.KeyTypeName(priv), id.Pretty(), *out)
+}
+
+// inspect prints everything there is to know about a key file.
+func inspect(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.Parse(args)
+	path := keyFileArg(fs)
+
+	encrypted, err := identity.IsEncrypted(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	priv := loadKey(path)
+	id, err := identity.PeerID(priv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	pub, err := crypto.MarshalPublicKey(priv.GetPublic())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("File:       %s\n", path)
+	fmt.Printf("Encrypted:  %t\n", encrypted)
+	fmt.Printf("Key type:   %s\n", identity.KeyTypeName(priv))
+	fmt.Printf("Peer ID:    %s\n", id.Pretty())
+	fmt.Printf("Public key: %s\n", base64.StdEncoding.EncodeToString(pub))
+}
+
+// export prints a single value derived from the key, ready for use in scripts.
+func export(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "id", "what to print: id, pubkey or addr")
+	addr := fs.String("addr", "/ip4/127.0.0.1/tcp/4001", "transport address used by -format addr")
+	fs.Parse(args)
+	path := keyFileArg(fs)
+
+	priv := loadKey(path)
+	id, err := identity.PeerID(priv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	switch *format {
+	case "id":
+		fmt.Println(id.Pretty())
+	case "pubkey":
+		pub, err := crypto.MarshalPublicKey(priv.GetPublic())
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(pub))
+	case "addr":
+		transport, err := ma.NewMultiaddr(*addr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		p2p, err := ma.NewMultiaddr("/p2p/" + id.Pretty())
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(transport.Encapsulate(p2p))
+	default:
+		log.Fatalf("export: unknown format %q\n", *format)
+	}
+}
+
+func keyFileArg(fs *flag.FlagSet) string {
+	if fs.NArg() != 1 {
+		log.Fatalf("%s: expected exactly one key file\n", fs.Name())
+	}
+	return fs.Arg(0)
+}
+
+func loadKey(path string) crypto.PrivKey {
+	priv, err := identity.Load(path, identity.Passphrase())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return priv
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/routing"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// BootstrapEnv is the environment variable holding the bootstrap peers, as
+// multiaddrs separated by commas.
+const BootstrapEnv = "KV_BOOTSTRAP"
+
+// protocolPrefix keeps the kv DHT apart from the public IPFS DHT, whose
+// peers don't accept kv records.
+const protocolPrefix = "/kv"
+
+const usage = `usage: kv [flags] serve
+       kv [flags] put <name> <value>
+       kv [flags] get [<peer ID>/]<name>
+       kv [flags] watch [<peer ID>/]<name>
+
+serve runs a DHT server, which stores the records of the others.
+put signs value with our key and stores it under name.
+get prints the latest value of an entry, ours unless a peer ID is given.
+watch prints the value of an entry every time it changes.
+
+flags:
+`
+
+func main() {
+	port := flag.Int("l", 0, "libp2p listen port, 0 picks a free one")
+	keyFile := flag.String("id", "", "load the key that owns our entries from this file, creating it if needed (default "+defaultKeyFile()+", a new key for serve)")
+	bootstrap := flag.String("bootstrap", os.Getenv(BootstrapEnv), "comma separated multiaddrs of kv servers, also read from $"+BootstrapEnv)
+	every := flag.Duration("every", 10*time.Second, "how often watch asks the DHT")
+	configFile := flag.String("config", "", "build the host from this configuration file, YAML or JSON; -l and -id apply if it sets no listen addresses or identity")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, args := args[0], args[1:]
+	want := map[string]int{"serve": 0, "put": 2, "get": 1, "watch": 1}
+	if n, ok := want[cmd]; !ok || len(args) != n {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := &hostconfig.Config{}
+	if *configFile != "" {
+		var err error
+		if cfg, err = hostconfig.Load(*configFile); err != nil {
+			log.Fatalln(err)
+		}
+		if cfg.Routing != "" {
+			log.Fatalln(*configFile + ": kv joins its own DHT, remove routing")
+		}
+	}
+	if len(cfg.ListenAddrs) == 0 {
+		cfg.ListenAddrs = []string{
+			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", *port),
+			fmt.Sprintf("/ip6/::/tcp/%d", *port),
+		}
+	}
+	if cfg.Identity.File == "" {
+		if *keyFile == "" && cmd != "serve" {
+			*keyFile = defaultKeyFile()
+		}
+		cfg.Identity.File = *keyFile
+	}
+
+	peers, err := parsePeers(*bootstrap)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(peers) == 0 && cmd != "serve" {
+		log.Fatalln("no kv server to join, give one with -bootstrap or $" + BootstrapEnv)
+	}
+
+	ctx := context.Background()
+	h, kad, err := makeNode(ctx, cfg, cmd == "serve", peers)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer h.Close()
+
+	switch cmd {
+	case "serve":
+		fmt.Println("kv server running, pass one of these addresses to the others with -bootstrap:")
+		for _, a := range h.Addrs() {
+			fmt.Printf(" - %s/p2p/%s\n", a, h.ID().Pretty())
+		}
+		select {} // hang forever
+	case "put":
+		err = put(ctx, h, kad, args[0], []byte(args[1]))
+	case "get":
+		err = get(ctx, h, kad, args[0])
+	case "watch":
+		err = watch(ctx, h, kad, args[0], *every)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// defaultKeyFile is where our key is kept unless -id says otherwise.
+func defaultKeyFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "kv.key"
+	}
+	return filepath.Join(dir, "libp2p-kv", "identity")
+}
+
+// parsePeers parses multiaddrs ending with /p2p/<peer ID>, separated by
+// commas.
+func parsePeers(list string) ([]peer.AddrInfo, error) {
+	var pis []peer.AddrInfo
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		addr, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", s, err)
+		}
+		pi, err := peer.AddrInfoFromP2pAddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", s, err)
+		}
+		pis = append(pis, *pi)
+	}
+	return pis, nil
+}
+
+// makeNode starts a host as cfg says, and joins the kv DHT through the
+// bootstrap peers. A server answers the DHT queries of the others, and
+// stores their records.
+func makeNode(ctx context.Context, cfg *hostconfig.Config, server bool, bootstrap []peer.AddrInfo) (host.Host, *dht.IpfsDHT, error) {
+	h, err := hostconfig.New(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Servers are usually on a private network, where a DHT in the default
+	// mode would stay a client.
+	mode := dht.ModeAuto
+	if server {
+		mode = dht.ModeServer
+	}
+	kad, err := dht.New(ctx, h,
+		dht.ProtocolPrefix(protocolPrefix),
+		dht.NamespacedValidator(Namespace, validator{}),
+		dht.Mode(mode),
+	)
+	if err != nil {
+		h.Close()
+		return nil, nil, err
+	}
+
+	connected := 0
+	for _, pi := range bootstrap {
+		if err := h.Connect(ctx, pi); err != nil {
+			log.Printf("can't reach %s: %s\n", pi.ID.Pretty(), err)
+			continue
+		}
+		connected++
+	}
+	if len(bootstrap) > 0 && connected == 0 {
+		h.Close()
+		return nil, nil, errors.New("none of the bootstrap peers could be reached")
+	}
+
+	// Peers join the routing table once they told us they speak the DHT
+	// protocol, queries made before that fail.
+	wait, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	for connected > 0 && kad.RoutingTable().Size() == 0 {
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-wait.Done():
+			h.Close()
+			return nil, nil, errors.New("none of the bootstrap peers is a kv server")
+		}
+	}
+	return h, kad, nil
+}
+
+// entryKey returns the DHT key for an entry given as [<peer ID>/]<name>.
+func entryKey(self peer.ID, arg string) string {
+	if i := strings.IndexByte(arg, '/'); i > 0 {
+		if owner, err := peer.Decode(arg[:i]); err == nil {
+			return dhtKey(owner, arg[i+1:])
+		}
+	}
+	return dhtKey(self, arg)
+}
+
+// lookup returns the latest entry stored under key, or nil if there is
+// none.
+func lookup(ctx context.Context, kad *dht.IpfsDHT, key string) (*entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	data, err := kad.GetValue(ctx, key)
+	if err == routing.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openEntry(key, data)
+}
+
+// put stores value under our entry name, with the next sequence number.
+func put(ctx context.Context, h host.Host, kad *dht.IpfsDHT, name string, value []byte) error {
+	if len(value) > maxValueSize {
+		return fmt.Errorf("values are limited to %d bytes", maxValueSize)
+	}
+	key := dhtKey(h.ID(), name)
+	if _, _, err := parseKey(key); err != nil {
+		return err
+	}
+	last, err := lookup(ctx, kad, key)
+	if err != nil {
+		return err
+	}
+	var seq uint64
+	if last != nil {
+		seq = last.Seq + 1
+	}
+
+	data, err := sealEntry(name, value, seq, h.Peerstore().PrivKey(h.ID()))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	if err := kad.PutValue(ctx, key, data); err != nil {
+		return err
+	}
+	log.Printf("%s/%s = %q (seq %d)\n", h.ID().Pretty(), name, value, seq)
+	return nil
+}
+
+// get prints the value of an entry.
+func get(ctx context.Context, h host.Host, kad *dht.IpfsDHT, arg string) error {
+	key := entryKey(h.ID(), arg)
+	e, err := lookup(ctx, kad, key)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		return fmt.Errorf("%s: not found", key)
+	}
+	log.Printf("%s (seq %d)\n", key, e.Seq)
+	_, err = fmt.Printf("%s\n", e.Value)
+	return err
+}
+
+// watch prints the value of an entry, and again every time it changes.
+func watch(ctx context.Context, h host.Host, kad *dht.IpfsDHT, arg string, every time.Duration) error {
+	key := entryKey(h.ID(), arg)
+	log.Printf("watching %s\n", key)
+	var last *entry
+	for {
+		e, err := lookup(ctx, kad, key)
+		switch {
+		case err != nil:
+			log.Println(err)
+		case e == nil:
+		case last == nil || e.Seq > last.Seq ||
+			(e.Seq == last.Seq && !bytes.Equal(e.Value, last.Value)):
+			fmt.Printf("%d\t%s\n", e.Seq, e.Value)
+			last = e
+		}
+		time.Sleep(every)
+	}
+}
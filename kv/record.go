@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/record"
+
+	proto "github.com/gogo/protobuf/proto"
+	pb "github.com/libp2p/go-libp2p-examples/kv/pb"
+)
+
+// Namespace is the DHT namespace of kv records. Their keys are
+// /kv/<owner peer ID>/<name>.
+const Namespace = "kv"
+
+// entryDomain separates the signatures of kv entries from those of other
+// signed envelopes made with the same key.
+const entryDomain = "libp2p-examples-kv"
+
+// entryCodec is the payload type of kv entries in signed envelopes.
+var entryCodec = []byte("/libp2p-examples/kv-entry")
+
+// maxValueSize bounds the values we put. DHT peers refuse records of more
+// than about a megabyte anyway, kv is meant for small values.
+const maxValueSize = 64 << 10
+
+func init() {
+	record.RegisterType(&entry{})
+}
+
+// entry is the payload of a kv record, it implements record.Record.
+type entry struct {
+	pb.Entry
+}
+
+func (e *entry) Domain() string { return entryDomain }
+
+func (e *entry) Codec() []byte { return entryCodec }
+
+func (e *entry) MarshalRecord() ([]byte, error) { return proto.Marshal(&e.Entry) }
+
+func (e *entry) UnmarshalRecord(data []byte) error { return proto.Unmarshal(data, &e.Entry) }
+
+// dhtKey returns the DHT key of the entry name owned by owner.
+func dhtKey(owner peer.ID, name string) string {
+	return "/" + Namespace + "/" + owner.Pretty() + "/" + name
+}
+
+// parseKey splits a DHT key into the owner and the name of the entry.
+func parseKey(key string) (peer.ID, string, error) {
+	parts := strings.SplitN(key, "/", 4)
+	if len(parts) != 4 || parts[0] != "" || parts[1] != Namespace || parts[3] == "" {
+		return "", "", fmt.Errorf("kv: invalid key %q", key)
+	}
+	owner, err := peer.Decode(parts[2])
+	if err != nil {
+		return "", "", fmt.Errorf("kv: invalid key %q: %w", key, err)
+	}
+	return owner, parts[3], nil
+}
+
+// sealEntry signs an entry with the owner's key.
+func sealEntry(name string, value []byte, seq uint64, priv crypto.PrivKey) ([]byte, error) {
+	env, err := record.Seal(&entry{pb.Entry{Name: name, Value: value, Seq: seq}}, priv)
+	if err != nil {
+		return nil, err
+	}
+	return env.Marshal()
+}
+
+// openEntry checks that data is an entry signed by the owner of key, and
+// returns it.
+func openEntry(key string, data []byte) (*entry, error) {
+	owner, name, err := parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	env, rec, err := record.ConsumeEnvelope(data, entryDomain)
+	if err != nil {
+		return nil, fmt.Errorf("kv: invalid record: %w", err)
+	}
+	e, ok := rec.(*entry)
+	if !ok {
+		return nil, errors.New("kv: invalid record: not a kv entry")
+	}
+	if !owner.MatchesPublicKey(env.PublicKey) {
+		return nil, errors.New("kv: invalid record: not signed by the owner of the key")
+	}
+	// Without this, anything the owner ever signed could be replayed
+	// under any of its names.
+	if e.Name != name {
+		return nil, fmt.Errorf("kv: invalid record: signed for %q, not %q", e.Name, name)
+	}
+	return e, nil
+}
+
+// validator validates the records of the kv namespace, it implements
+// record.Validator. Only the owner of a key can sign its records, and the
+// record with the highest sequence number wins.
+type validator struct{}
+
+func (validator) Validate(key string, value []byte) error {
+	_, err := openEntry(key, value)
+	return err
+}
+
+func (validator) Select(key string, values [][]byte) (int, error) {
+	best := -1
+	var bestEntry *entry
+	for i, v := range values {
+		e, err := openEntry(key, v)
+		if err != nil {
+			continue
+		}
+		// Two records with the same sequence number should not exist, but
+		// a buggy or careless owner could make them. Break the tie on the
+		// bytes, so that every peer picks the same one.
+		if best == -1 || e.Seq > bestEntry.Seq ||
+			(e.Seq == bestEntry.Seq && bytes.Compare(v, values[best]) > 0) {
+			best, bestEntry = i, e
+		}
+	}
+	if best == -1 {
+		return 0, errors.New("kv: no valid record")
+	}
+	return best, nil
+}
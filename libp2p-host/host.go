@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"time"
 
@@ -9,6 +10,10 @@ import (
 	connmgr "github.com/libp2p/go-libp2p-connmgr"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+	_ "github.com/libp2p/go-libp2p-examples/hostconfig/quic"
+	"github.com/libp2p/go-libp2p-examples/natstatus"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	libp2pquic "github.com/libp2p/go-libp2p-quic-transport"
 	routing "github.com/libp2p/go-libp2p-routing"
@@ -17,6 +22,10 @@ import (
 )
 
 func main() {
+	configFile := flag.String("config", "", "Build the second host from this configuration file, YAML or JSON")
+	watch := flag.Duration("watch", 0, "Connect the second host to the bootstrap peers, and report its reachability for this long")
+	flag.Parse()
+
 	// The context governs the lifetime of the libp2p node.
 	// Cancelling it will stop the the host.
 	ctx, cancel := context.WithCancel(context.Background())
@@ -34,18 +43,90 @@ func main() {
 	// that is fully configured to best support your p2p application.
 	// Let's create a second host setting some more options.
 
+	// The same can be written down in a configuration file, see
+	// host.yaml: -config builds the second host from one instead.
+	var h2 host.Host
+	var monitor *natstatus.Monitor
+	if *configFile != "" {
+		cfg, err := hostconfig.Load(*configFile)
+		if err != nil {
+			panic(err)
+		}
+		ch, err := hostconfig.New(ctx, cfg)
+		if err != nil {
+			panic(err)
+		}
+		h2, monitor = ch, ch.Monitor
+	} else {
+		// The monitor reports what the host finds out about the network it
+		// is in, and why it can or can't be dialed.
+		monitor = natstatus.NewMonitor()
+		h2, err = newHost(ctx, monitor)
+		if err != nil {
+			panic(err)
+		}
+		if err := monitor.Start(h2); err != nil {
+			panic(err)
+		}
+	}
+	defer monitor.Close()
+
+	fmt.Printf("Hello World, my second hosts ID is %s\n", h2.ID())
+	printStatus(monitor.Status())
+
+	// The last step to get fully up and running would be to connect to
+	// bootstrap peers (or any other peers). We only do so with -watch, as
+	// this is an example and the peer will die as soon as it finishes, so
+	// it is unnecessary to put strain on the network otherwise.
+	if *watch <= 0 {
+		return
+	}
+	sub, err := h2.EventBus().Subscribe(new(natstatus.EvtStatusChanged))
+	if err != nil {
+		panic(err)
+	}
+	defer sub.Close()
+
+	// This connects to public bootstrappers
+	for _, addr := range dht.DefaultBootstrapPeers {
+		pi, _ := peer.AddrInfoFromP2pAddr(addr)
+		// We ignore errors as some bootstrap peers may be down
+		// and that is fine.
+		h2.Connect(ctx, *pi)
+	}
+
+	// AutoNAT needs a few peers to dial us back before it knows whether
+	// we are reachable, and AutoRelay only looks for relays once we are
+	// found not to be.
+	timeout := time.After(*watch)
+	for {
+		select {
+		case e := <-sub.Out():
+			for _, change := range e.(natstatus.EvtStatusChanged).Changes {
+				fmt.Printf("%s %s\n", time.Now().Format("15:04:05"), change)
+			}
+		case <-timeout:
+			printStatus(monitor.Status())
+			return
+		}
+	}
+}
+
+// newHost creates a host setting some more options. The monitor sees
+// the port mappings.
+func newHost(ctx context.Context, monitor *natstatus.Monitor) (host.Host, error) {
 	// Set your own keypair
 	priv, _, err := crypto.GenerateKeyPair(
 		crypto.Ed25519, // Select your key type. Ed25519 are nice short
 		-1,             // Select key length when possible (i.e. RSA).
 	)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	var idht *dht.IpfsDHT
 
-	h2, err := libp2p.New(ctx,
+	return libp2p.New(ctx,
 		// Use the keypair we generated
 		libp2p.Identity(priv),
 		// Multiple listen addresses
@@ -68,8 +149,9 @@ func main() {
 			400,         // HighWater,
 			time.Minute, // GracePeriod
 		)),
-		// Attempt to open ports using uPNP for NATed hosts.
-		libp2p.NATPortMap(),
+		// Attempt to open ports using uPNP for NATed hosts. This is
+		// libp2p.NATPortMap(), letting the monitor see the mappings.
+		monitor.NATPortMap(),
 		// Let this host use the DHT to find other hosts
 		libp2p.Routing(func(h host.Host) (routing.PeerRouting, error) {
 			idht, err = dht.New(ctx, h)
@@ -87,23 +169,25 @@ func main() {
 		// performance issues.
 		libp2p.EnableNATService(),
 	)
-	if err != nil {
-		panic(err)
-	}
+}
 
-	// The last step to get fully up and running would be to connect to
-	// bootstrap peers (or any other peers). We leave this commented as
-	// this is an example and the peer will die as soon as it finishes, so
-	// it is unnecessary to put strain on the network.
-
-	/*
-		// This connects to public bootstrappers
-		for _, addr := range dht.DefaultBootstrapPeers {
-			pi, _ := peer.AddrInfoFromP2pAddr(addr)
-			// We ignore errors as some bootstrap peers may be down
-			// and that is fine.
-			h2.Connect(ctx, *pi)
-		}
-	*/
-	fmt.Printf("Hello World, my second hosts ID is %s\n", h2.ID())
+// printStatus prints what the host knows about its reachability.
+func printStatus(s natstatus.Status) {
+	fmt.Printf("Reachability: %s, since %s\n", s.Reachability, s.Since.Format("15:04:05"))
+	fmt.Println("Observed addresses:")
+	for _, a := range s.ObservedAddrs {
+		fmt.Println(" -", a)
+	}
+	fmt.Println("Port mapping:", s.PortMapping)
+	for _, m := range s.Mappings {
+		fmt.Println(" -", m)
+	}
+	fmt.Println("Relays:")
+	for _, r := range s.Relays {
+		fmt.Println(" -", r.Pretty())
+	}
+	fmt.Println("Advertised addresses:")
+	for _, a := range s.Addrs {
+		fmt.Println(" -", a)
+	}
 }
@@ -0,0 +1,143 @@
+package mailbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	ggio "github.com/gogo/protobuf/io"
+	pb "github.com/libp2p/go-libp2p-examples/mailbox/pb"
+)
+
+// PublicKey finds the public key of p, needed to seal letters for it. Keys
+// are known for peers we have been connected to, and can be extracted from
+// the peer ID for Ed25519 peers.
+func PublicKey(h host.Host, p peer.ID) (crypto.PubKey, error) {
+	if pub := h.Peerstore().PubKey(p); pub != nil {
+		return pub, nil
+	}
+	pub, err := p.ExtractPublicKey()
+	if err != nil || pub == nil {
+		return nil, fmt.Errorf("public key of %s unknown, connect to it once first", p.Pretty())
+	}
+	return pub, nil
+}
+
+// Deposit hands an envelope for recipient to the mailbox peer mb. The mailbox
+// keeps it for ttl, or for as long as it is willing to if ttl is zero.
+func Deposit(ctx context.Context, h host.Host, mb, recipient peer.ID, envelope []byte, ttl time.Duration) error {
+	s, err := h.NewStream(ctx, mb, DepositProtocol)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	req := &pb.DepositRequest{
+		Recipient: []byte(recipient),
+		Envelope:  envelope,
+		Ttl:       int64(ttl / time.Second),
+	}
+	if err := ggio.NewDelimitedWriter(s).WriteMsg(req); err != nil {
+		s.Reset()
+		return err
+	}
+
+	resp := &pb.DepositResponse{}
+	if err := ggio.NewDelimitedReader(s, maxMessageSize).ReadMsg(resp); err != nil {
+		s.Reset()
+		return err
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("mailbox %s: %s", mb.Pretty(), resp.Error)
+	}
+	return nil
+}
+
+// Send seals body for recipient and deposits it with every mailbox, so the
+// letter survives some of them going down. It returns the number of mailboxes
+// that stored the letter, and fails only if none did.
+func Send(ctx context.Context, h host.Host, author crypto.PrivKey, recipient peer.ID, mailboxes []peer.ID, body []byte, ttl time.Duration) (int, error) {
+	if len(mailboxes) == 0 {
+		return 0, errors.New("no mailboxes given")
+	}
+	pub, err := PublicKey(h, recipient)
+	if err != nil {
+		return 0, err
+	}
+	envelope, err := Seal(author, pub, body)
+	if err != nil {
+		return 0, err
+	}
+
+	stored := 0
+	var lastErr error
+	for _, mb := range mailboxes {
+		if err := Deposit(ctx, h, mb, recipient, envelope, ttl); err != nil {
+			lastErr = err
+			continue
+		}
+		stored++
+	}
+	if stored == 0 {
+		return 0, lastErr
+	}
+	return stored, nil
+}
+
+// Collect fetches the letters left for us at the mailbox peer mb and opens
+// them with our private key. Envelopes that can't be opened or carry a bad
+// signature are skipped. The mailbox deletes everything it handed out, so
+// on error the letters already collected are returned along with it.
+func Collect(ctx context.Context, h host.Host, priv crypto.PrivKey, mb peer.ID) ([]*Letter, error) {
+	s, err := h.NewStream(ctx, mb, CollectProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	reader := ggio.NewDelimitedReader(s, maxMessageSize)
+	writer := ggio.NewDelimitedWriter(s)
+	if err := writer.WriteMsg(&pb.CollectRequest{}); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	// The envelopes come in pages, each acknowledged before the next.
+	var letters []*Letter
+	for {
+		resp := &pb.CollectResponse{}
+		if err := reader.ReadMsg(resp); err != nil {
+			s.Reset()
+			return letters, err
+		}
+		if len(resp.Envelopes) == 0 {
+			return letters, nil
+		}
+
+		ack := &pb.CollectAck{}
+		for _, env := range resp.Envelopes {
+			ack.Ids = append(ack.Ids, env.Id)
+
+			l, err := Open(priv, env.Envelope)
+			if err != nil {
+				log.Printf("Dropping envelope %s from mailbox %s: %s\n", env.Id, mb.Pretty(), err)
+				continue
+			}
+			letters = append(letters, l)
+		}
+
+		if err := writer.WriteMsg(ack); err != nil {
+			s.Reset()
+			return letters, err
+		}
+		if !resp.More {
+			return letters, nil
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+	"github.com/libp2p/go-libp2p-examples/mailbox"
+)
+
+func main() {
+	port := flag.Int("l", 4100, "libp2p listen port")
+	keyFile := flag.String("id", "", "load the host key from this file, creating it if needed")
+	ttl := flag.Duration("ttl", 7*24*time.Hour, "longest time to keep a message")
+	maxMessages := flag.Int("max-messages", 100, "messages a single sender may have stored at a time")
+	maxBytes := flag.Int("max-bytes", 1<<20, "bytes a single sender may have stored at a time")
+	maxRecipientBytes := flag.Int("max-recipient-bytes", 4<<20, "bytes stored for a single recipient, over all senders")
+	configFile := flag.String("config", "", "build the host from this configuration file, YAML or JSON, instead of -l and -id")
+	flag.Parse()
+
+	// Clients are configured with the mailbox's peer ID, keeping the key
+	// in a file keeps it the same when the mailbox restarts.
+	cfg := &hostconfig.Config{
+		Identity: hostconfig.Identity{File: *keyFile},
+		ListenAddrs: []string{
+			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", *port),
+			fmt.Sprintf("/ip6/::/tcp/%d", *port),
+		},
+	}
+	if *configFile != "" {
+		var err error
+		if cfg, err = hostconfig.Load(*configFile); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	h, err := hostconfig.New(context.Background(), cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	mailbox.NewServer(h, mailbox.Options{
+		MaxTTL:      *ttl,
+		MaxMessages: *maxMessages,
+		MaxBytes:    *maxBytes,
+
+		MaxRecipientBytes: *maxRecipientBytes,
+	})
+
+	fmt.Println("Mailbox running, pass one of these addresses to the chat with -mailbox:")
+	for _, a := range h.Addrs() {
+		fmt.Printf(" - %s/p2p/%s\n", a, h.ID().Pretty())
+	}
+	if cfg.Identity.File == "" {
+		fmt.Println("\nThe peer ID changes on every start, use -id to keep it.")
+	}
+
+	select {} // hang forever
+}
@@ -0,0 +1,459 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: mailbox.proto
+
+package mailbox_pb
+
+import (
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// the plaintext of a message for an offline peer. only the recipient can read it
+type Letter struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	SenderKey            []byte   `protobuf:"bytes,2,opt,name=senderKey,proto3" json:"senderKey,omitempty"`
+	Recipient            []byte   `protobuf:"bytes,3,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	Timestamp            int64    `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Body                 []byte   `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+	Signature            []byte   `protobuf:"bytes,6,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Letter) Reset()         { *m = Letter{} }
+func (m *Letter) String() string { return proto.CompactTextString(m) }
+func (*Letter) ProtoMessage()    {}
+func (*Letter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_30d27601781ba7fa, []int{0}
+}
+func (m *Letter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Letter.Unmarshal(m, b)
+}
+func (m *Letter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Letter.Marshal(b, m, deterministic)
+}
+func (m *Letter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Letter.Merge(m, src)
+}
+func (m *Letter) XXX_Size() int {
+	return xxx_messageInfo_Letter.Size(m)
+}
+func (m *Letter) XXX_DiscardUnknown() {
+	xxx_messageInfo_Letter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Letter proto.InternalMessageInfo
+
+func (m *Letter) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Letter) GetSenderKey() []byte {
+	if m != nil {
+		return m.SenderKey
+	}
+	return nil
+}
+
+func (m *Letter) GetRecipient() []byte {
+	if m != nil {
+		return m.Recipient
+	}
+	return nil
+}
+
+func (m *Letter) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *Letter) GetBody() []byte {
+	if m != nil {
+		return m.Body
+	}
+	return nil
+}
+
+func (m *Letter) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// a Letter encrypted to the libp2p public key of the recipient
+type Envelope struct {
+	Key                  []byte   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Nonce                []byte   `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Ciphertext           []byte   `protobuf:"bytes,3,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Envelope) Reset()         { *m = Envelope{} }
+func (m *Envelope) String() string { return proto.CompactTextString(m) }
+func (*Envelope) ProtoMessage()    {}
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return fileDescriptor_30d27601781ba7fa, []int{1}
+}
+func (m *Envelope) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Envelope.Unmarshal(m, b)
+}
+func (m *Envelope) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Envelope.Marshal(b, m, deterministic)
+}
+func (m *Envelope) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Envelope.Merge(m, src)
+}
+func (m *Envelope) XXX_Size() int {
+	return xxx_messageInfo_Envelope.Size(m)
+}
+func (m *Envelope) XXX_DiscardUnknown() {
+	xxx_messageInfo_Envelope.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Envelope proto.InternalMessageInfo
+
+func (m *Envelope) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *Envelope) GetNonce() []byte {
+	if m != nil {
+		return m.Nonce
+	}
+	return nil
+}
+
+func (m *Envelope) GetCiphertext() []byte {
+	if m != nil {
+		return m.Ciphertext
+	}
+	return nil
+}
+
+// leaves an envelope for an offline peer. the sender is the peer that opened the stream
+type DepositRequest struct {
+	Recipient            []byte   `protobuf:"bytes,1,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	Envelope             []byte   `protobuf:"bytes,2,opt,name=envelope,proto3" json:"envelope,omitempty"`
+	Ttl                  int64    `protobuf:"varint,3,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DepositRequest) Reset()         { *m = DepositRequest{} }
+func (m *DepositRequest) String() string { return proto.CompactTextString(m) }
+func (*DepositRequest) ProtoMessage()    {}
+func (*DepositRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_30d27601781ba7fa, []int{2}
+}
+func (m *DepositRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DepositRequest.Unmarshal(m, b)
+}
+func (m *DepositRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DepositRequest.Marshal(b, m, deterministic)
+}
+func (m *DepositRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DepositRequest.Merge(m, src)
+}
+func (m *DepositRequest) XXX_Size() int {
+	return xxx_messageInfo_DepositRequest.Size(m)
+}
+func (m *DepositRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DepositRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DepositRequest proto.InternalMessageInfo
+
+func (m *DepositRequest) GetRecipient() []byte {
+	if m != nil {
+		return m.Recipient
+	}
+	return nil
+}
+
+func (m *DepositRequest) GetEnvelope() []byte {
+	if m != nil {
+		return m.Envelope
+	}
+	return nil
+}
+
+func (m *DepositRequest) GetTtl() int64 {
+	if m != nil {
+		return m.Ttl
+	}
+	return 0
+}
+
+type DepositResponse struct {
+	Error                string   `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DepositResponse) Reset()         { *m = DepositResponse{} }
+func (m *DepositResponse) String() string { return proto.CompactTextString(m) }
+func (*DepositResponse) ProtoMessage()    {}
+func (*DepositResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_30d27601781ba7fa, []int{3}
+}
+func (m *DepositResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DepositResponse.Unmarshal(m, b)
+}
+func (m *DepositResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DepositResponse.Marshal(b, m, deterministic)
+}
+func (m *DepositResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DepositResponse.Merge(m, src)
+}
+func (m *DepositResponse) XXX_Size() int {
+	return xxx_messageInfo_DepositResponse.Size(m)
+}
+func (m *DepositResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DepositResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DepositResponse proto.InternalMessageInfo
+
+func (m *DepositResponse) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+// asks for all envelopes left for the peer that opened the stream. they are sent in pages,
+// each CollectResponse followed by a CollectAck, until a response has more unset
+type CollectRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CollectRequest) Reset()         { *m = CollectRequest{} }
+func (m *CollectRequest) String() string { return proto.CompactTextString(m) }
+func (*CollectRequest) ProtoMessage()    {}
+func (*CollectRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_30d27601781ba7fa, []int{4}
+}
+func (m *CollectRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CollectRequest.Unmarshal(m, b)
+}
+func (m *CollectRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CollectRequest.Marshal(b, m, deterministic)
+}
+func (m *CollectRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CollectRequest.Merge(m, src)
+}
+func (m *CollectRequest) XXX_Size() int {
+	return xxx_messageInfo_CollectRequest.Size(m)
+}
+func (m *CollectRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CollectRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CollectRequest proto.InternalMessageInfo
+
+type StoredEnvelope struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Envelope             []byte   `protobuf:"bytes,2,opt,name=envelope,proto3" json:"envelope,omitempty"`
+	Deposited            int64    `protobuf:"varint,3,opt,name=deposited,proto3" json:"deposited,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StoredEnvelope) Reset()         { *m = StoredEnvelope{} }
+func (m *StoredEnvelope) String() string { return proto.CompactTextString(m) }
+func (*StoredEnvelope) ProtoMessage()    {}
+func (*StoredEnvelope) Descriptor() ([]byte, []int) {
+	return fileDescriptor_30d27601781ba7fa, []int{5}
+}
+func (m *StoredEnvelope) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StoredEnvelope.Unmarshal(m, b)
+}
+func (m *StoredEnvelope) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StoredEnvelope.Marshal(b, m, deterministic)
+}
+func (m *StoredEnvelope) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StoredEnvelope.Merge(m, src)
+}
+func (m *StoredEnvelope) XXX_Size() int {
+	return xxx_messageInfo_StoredEnvelope.Size(m)
+}
+func (m *StoredEnvelope) XXX_DiscardUnknown() {
+	xxx_messageInfo_StoredEnvelope.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StoredEnvelope proto.InternalMessageInfo
+
+func (m *StoredEnvelope) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *StoredEnvelope) GetEnvelope() []byte {
+	if m != nil {
+		return m.Envelope
+	}
+	return nil
+}
+
+func (m *StoredEnvelope) GetDeposited() int64 {
+	if m != nil {
+		return m.Deposited
+	}
+	return 0
+}
+
+type CollectResponse struct {
+	Envelopes            []*StoredEnvelope `protobuf:"bytes,1,rep,name=envelopes,proto3" json:"envelopes,omitempty"`
+	More                 bool              `protobuf:"varint,2,opt,name=more,proto3" json:"more,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *CollectResponse) Reset()         { *m = CollectResponse{} }
+func (m *CollectResponse) String() string { return proto.CompactTextString(m) }
+func (*CollectResponse) ProtoMessage()    {}
+func (*CollectResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_30d27601781ba7fa, []int{6}
+}
+func (m *CollectResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CollectResponse.Unmarshal(m, b)
+}
+func (m *CollectResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CollectResponse.Marshal(b, m, deterministic)
+}
+func (m *CollectResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CollectResponse.Merge(m, src)
+}
+func (m *CollectResponse) XXX_Size() int {
+	return xxx_messageInfo_CollectResponse.Size(m)
+}
+func (m *CollectResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CollectResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CollectResponse proto.InternalMessageInfo
+
+func (m *CollectResponse) GetEnvelopes() []*StoredEnvelope {
+	if m != nil {
+		return m.Envelopes
+	}
+	return nil
+}
+
+func (m *CollectResponse) GetMore() bool {
+	if m != nil {
+		return m.More
+	}
+	return false
+}
+
+// sent after a CollectResponse with envelopes, the mailbox deletes the listed envelopes
+type CollectAck struct {
+	Ids                  []string `protobuf:"bytes,1,rep,name=ids,proto3" json:"ids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CollectAck) Reset()         { *m = CollectAck{} }
+func (m *CollectAck) String() string { return proto.CompactTextString(m) }
+func (*CollectAck) ProtoMessage()    {}
+func (*CollectAck) Descriptor() ([]byte, []int) {
+	return fileDescriptor_30d27601781ba7fa, []int{7}
+}
+func (m *CollectAck) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CollectAck.Unmarshal(m, b)
+}
+func (m *CollectAck) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CollectAck.Marshal(b, m, deterministic)
+}
+func (m *CollectAck) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CollectAck.Merge(m, src)
+}
+func (m *CollectAck) XXX_Size() int {
+	return xxx_messageInfo_CollectAck.Size(m)
+}
+func (m *CollectAck) XXX_DiscardUnknown() {
+	xxx_messageInfo_CollectAck.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CollectAck proto.InternalMessageInfo
+
+func (m *CollectAck) GetIds() []string {
+	if m != nil {
+		return m.Ids
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Letter)(nil), "mailbox.pb.Letter")
+	proto.RegisterType((*Envelope)(nil), "mailbox.pb.Envelope")
+	proto.RegisterType((*DepositRequest)(nil), "mailbox.pb.DepositRequest")
+	proto.RegisterType((*DepositResponse)(nil), "mailbox.pb.DepositResponse")
+	proto.RegisterType((*CollectRequest)(nil), "mailbox.pb.CollectRequest")
+	proto.RegisterType((*StoredEnvelope)(nil), "mailbox.pb.StoredEnvelope")
+	proto.RegisterType((*CollectResponse)(nil), "mailbox.pb.CollectResponse")
+	proto.RegisterType((*CollectAck)(nil), "mailbox.pb.CollectAck")
+}
+
+func init() { proto.RegisterFile("mailbox.proto", fileDescriptor_30d27601781ba7fa) }
+
+var fileDescriptor_30d27601781ba7fa = []byte{
+	// 355 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x92, 0x31, 0x4f, 0xeb, 0x30,
+	0x14, 0x85, 0x95, 0xa6, 0xad, 0x92, 0xfb, 0xfa, 0xd2, 0xca, 0x7a, 0x43, 0x54, 0x55, 0x55, 0xe4,
+	0xe5, 0x65, 0xea, 0x00, 0x0b, 0x2b, 0x02, 0x26, 0x98, 0xcc, 0x86, 0x90, 0x50, 0x13, 0x5f, 0x81,
+	0xd5, 0x24, 0x0e, 0x8e, 0x8b, 0xda, 0xff, 0xc3, 0x0f, 0x45, 0x76, 0x9c, 0xa4, 0xed, 0xc0, 0x76,
+	0xee, 0xb9, 0xb6, 0xcf, 0x77, 0x6d, 0xc3, 0xdf, 0x72, 0x2b, 0x8a, 0x4c, 0x1e, 0x36, 0xb5, 0x92,
+	0x5a, 0x12, 0xe8, 0xcb, 0x8c, 0x7e, 0x7b, 0x30, 0x7d, 0x42, 0xad, 0x51, 0x91, 0x08, 0x46, 0x82,
+	0xc7, 0x5e, 0xe2, 0xa5, 0x21, 0x1b, 0x09, 0x4e, 0x56, 0x10, 0x36, 0x58, 0x71, 0x54, 0x8f, 0x78,
+	0x8c, 0x47, 0x89, 0x97, 0xce, 0xd8, 0x60, 0x98, 0xae, 0xc2, 0x5c, 0xd4, 0x02, 0x2b, 0x1d, 0xfb,
+	0x6d, 0xb7, 0x37, 0x4c, 0x57, 0x8b, 0x12, 0x1b, 0xbd, 0x2d, 0xeb, 0x78, 0x9c, 0x78, 0xa9, 0xcf,
+	0x06, 0x83, 0x10, 0x18, 0x67, 0x92, 0x1f, 0xe3, 0x89, 0xdd, 0x66, 0xb5, 0x4d, 0x13, 0xef, 0xd5,
+	0x56, 0xef, 0x15, 0xc6, 0x53, 0x97, 0xd6, 0x19, 0x94, 0x41, 0xf0, 0x50, 0x7d, 0x61, 0x21, 0x6b,
+	0x24, 0x0b, 0xf0, 0x77, 0x78, 0xb4, 0xa0, 0x33, 0x66, 0x24, 0xf9, 0x07, 0x93, 0x4a, 0x56, 0x39,
+	0x3a, 0xca, 0xb6, 0x20, 0x6b, 0x80, 0x5c, 0xd4, 0x1f, 0xa8, 0x34, 0x1e, 0x3a, 0xc4, 0x13, 0x87,
+	0xbe, 0x42, 0x74, 0x8f, 0xb5, 0x6c, 0x84, 0x66, 0xf8, 0xb9, 0xc7, 0x46, 0x9f, 0xcf, 0xe4, 0x5d,
+	0xce, 0xb4, 0x84, 0x00, 0x1d, 0x83, 0x0b, 0x0a, 0xf0, 0x84, 0x49, 0xeb, 0xc2, 0x86, 0xf8, 0xcc,
+	0x48, 0xfa, 0x1f, 0xe6, 0xfd, 0xe9, 0x4d, 0x2d, 0xab, 0x06, 0x0d, 0x26, 0x2a, 0x25, 0x95, 0xbb,
+	0xe3, 0xb6, 0xa0, 0x0b, 0x88, 0xee, 0x64, 0x51, 0x60, 0xde, 0x61, 0xd0, 0x17, 0x88, 0x9e, 0xb5,
+	0x54, 0xc8, 0xfb, 0x91, 0x2f, 0x9f, 0xe6, 0x37, 0x94, 0x15, 0x84, 0xbc, 0x0d, 0x46, 0xee, 0x80,
+	0x06, 0x83, 0xbe, 0xc1, 0xbc, 0x4f, 0x73, 0x58, 0x37, 0x10, 0x76, 0x9b, 0x9b, 0xd8, 0x4b, 0xfc,
+	0xf4, 0xcf, 0xd5, 0x72, 0x33, 0x7c, 0x91, 0xcd, 0x39, 0x0b, 0x1b, 0x16, 0x9b, 0x77, 0x2c, 0xa5,
+	0x6a, 0x11, 0x02, 0x66, 0x35, 0x5d, 0x03, 0xb8, 0x80, 0xdb, 0x7c, 0x67, 0xee, 0x45, 0xf0, 0xf6,
+	0xd4, 0x90, 0x19, 0x99, 0x4d, 0xed, 0x1f, 0xbc, 0xfe, 0x19, 0x00, 0x9a, 0x80, 0x11, 0xfd, 0x94,
+	0x02, 0x00, 0x00,
+}
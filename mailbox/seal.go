@@ -0,0 +1,309 @@
+package mailbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/gogo/protobuf/proto"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+
+	uuid "github.com/google/uuid"
+	pb "github.com/libp2p/go-libp2p-examples/mailbox/pb"
+)
+
+// hkdfInfo binds derived message keys to this protocol.
+const hkdfInfo = "libp2p-mailbox/1.0.0"
+
+// ErrUnsupportedKey is returned for recipients whose key type can't be
+// encrypted to. Ed25519, Secp256k1 and RSA keys are supported.
+var ErrUnsupportedKey = errors.New("mailbox: unsupported key type")
+
+// Letter is a decrypted message.
+type Letter struct {
+	ID   string
+	From peer.ID
+	Time time.Time
+	Body []byte
+}
+
+// Seal signs a message with the author's key and encrypts it to the public
+// key of the recipient. Only the recipient can open the returned envelope,
+// mailboxes just store it.
+func Seal(author crypto.PrivKey, recipient crypto.PubKey, body []byte) ([]byte, error) {
+	to, err := peer.IDFromPublicKey(recipient)
+	if err != nil {
+		return nil, err
+	}
+	senderKey, err := crypto.MarshalPublicKey(author.GetPublic())
+	if err != nil {
+		return nil, err
+	}
+
+	letter := &pb.Letter{
+		Id:        uuid.New().String(),
+		SenderKey: senderKey,
+		Recipient: []byte(to),
+		Timestamp: time.Now().UnixNano(),
+		Body:      body,
+	}
+	unsigned, err := proto.Marshal(letter)
+	if err != nil {
+		return nil, err
+	}
+	if letter.Signature, err = author.Sign(unsigned); err != nil {
+		return nil, err
+	}
+	plaintext, err := proto.Marshal(letter)
+	if err != nil {
+		return nil, err
+	}
+
+	key, secret, err := agree(recipient)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(secret, key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&pb.Envelope{
+		Key:        key,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	})
+}
+
+// Open decrypts an envelope sealed for the owner of priv and checks the
+// author's signature.
+func Open(priv crypto.PrivKey, envelope []byte) (*Letter, error) {
+	env := &pb.Envelope{}
+	if err := proto.Unmarshal(envelope, env); err != nil {
+		return nil, err
+	}
+
+	secret, err := recoverSecret(priv, env.Key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(secret, env.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("mailbox: bad nonce")
+	}
+	plaintext, err := gcm.Open(nil, env.Nonce, env.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("mailbox: envelope was not sealed for us, or was tampered with")
+	}
+
+	letter := &pb.Letter{}
+	if err := proto.Unmarshal(plaintext, letter); err != nil {
+		return nil, err
+	}
+
+	me, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	if peer.ID(letter.Recipient) != me {
+		return nil, errors.New("mailbox: letter is addressed to someone else")
+	}
+
+	senderKey, err := crypto.UnmarshalPublicKey(letter.SenderKey)
+	if err != nil {
+		return nil, err
+	}
+	sig := letter.Signature
+	letter.Signature = nil
+	unsigned, err := proto.Marshal(letter)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := senderKey.Verify(unsigned, sig); err != nil || !ok {
+		return nil, errors.New("mailbox: bad signature on letter")
+	}
+	from, err := peer.IDFromPublicKey(senderKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Letter{
+		ID:   letter.Id,
+		From: from,
+		Time: time.Unix(0, letter.Timestamp),
+		Body: letter.Body,
+	}, nil
+}
+
+// newGCM derives the message key from the shared secret.
+func newGCM(secret, salt []byte) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(hkdfInfo)), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// agree picks a shared secret only the owner of pub can recover. It returns
+// what has to be sent along for that, and the secret.
+//
+// Ed25519 and Secp256k1 recipients get an ephemeral Diffie-Hellman key, for
+// Ed25519 the keys are converted to their X25519 equivalents. For RSA
+// recipients the secret is random and sent encrypted with RSA-OAEP.
+func agree(pub crypto.PubKey) (key, secret []byte, err error) {
+	switch pub.Type() {
+	case crypto.Ed25519:
+		raw, err := pub.Raw()
+		if err != nil {
+			return nil, nil, err
+		}
+		theirs, err := ed25519PublicToX25519(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		ephemeral := make([]byte, curve25519.ScalarSize)
+		if _, err := rand.Read(ephemeral); err != nil {
+			return nil, nil, err
+		}
+		if key, err = curve25519.X25519(ephemeral, curve25519.Basepoint); err != nil {
+			return nil, nil, err
+		}
+		if secret, err = curve25519.X25519(ephemeral, theirs); err != nil {
+			return nil, nil, err
+		}
+		return key, secret, nil
+
+	case crypto.Secp256k1:
+		raw, err := pub.Raw()
+		if err != nil {
+			return nil, nil, err
+		}
+		theirs, err := btcec.ParsePubKey(raw, btcec.S256())
+		if err != nil {
+			return nil, nil, err
+		}
+		ephemeral, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			return nil, nil, err
+		}
+		return ephemeral.PubKey().SerializeCompressed(), btcec.GenerateSharedSecret(ephemeral, theirs), nil
+
+	case crypto.RSA:
+		std, err := crypto.PubKeyToStdKey(pub)
+		if err != nil {
+			return nil, nil, err
+		}
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, err
+		}
+		if key, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, std.(*rsa.PublicKey), secret, []byte(hkdfInfo)); err != nil {
+			return nil, nil, err
+		}
+		return key, secret, nil
+	}
+	return nil, nil, ErrUnsupportedKey
+}
+
+// recoverSecret is the recipient's side of agree.
+func recoverSecret(priv crypto.PrivKey, key []byte) ([]byte, error) {
+	switch priv.Type() {
+	case crypto.Ed25519:
+		raw, err := priv.Raw()
+		if err != nil {
+			return nil, err
+		}
+		return curve25519.X25519(ed25519PrivateToX25519(raw), key)
+
+	case crypto.Secp256k1:
+		raw, err := priv.Raw()
+		if err != nil {
+			return nil, err
+		}
+		ours, _ := btcec.PrivKeyFromBytes(btcec.S256(), raw)
+		theirs, err := btcec.ParsePubKey(key, btcec.S256())
+		if err != nil {
+			return nil, err
+		}
+		return btcec.GenerateSharedSecret(ours, theirs), nil
+
+	case crypto.RSA:
+		std, err := crypto.PrivKeyToStdKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		return rsa.DecryptOAEP(sha256.New(), rand.Reader, std.(*rsa.PrivateKey), key, []byte(hkdfInfo))
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// curveP is the prime 2^255 - 19 of Curve25519 and Ed25519.
+var curveP = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// ed25519PublicToX25519 maps an Ed25519 public key, a point y on the Edwards
+// curve, to the u coordinate of the same point on the Montgomery curve:
+// u = (1 + y) / (1 - y).
+func ed25519PublicToX25519(pub []byte) ([]byte, error) {
+	if len(pub) != 32 {
+		return nil, fmt.Errorf("mailbox: bad Ed25519 public key length %d", len(pub))
+	}
+
+	// Keys are little endian, with the sign of x in the top bit.
+	le := make([]byte, 32)
+	copy(le, pub)
+	le[31] &= 0x7f
+	y := new(big.Int).SetBytes(reverse(le))
+
+	one := big.NewInt(1)
+	num := new(big.Int).Add(one, y)
+	den := new(big.Int).Sub(one, y)
+	den.Mod(den, curveP)
+	if den.Sign() == 0 {
+		return nil, errors.New("mailbox: invalid Ed25519 public key")
+	}
+	u := num.Mul(num, den.ModInverse(den, curveP))
+	u.Mod(u, curveP)
+
+	out := make([]byte, 32)
+	b := u.Bytes()
+	copy(out[32-len(b):], b)
+	return reverse(out), nil
+}
+
+// ed25519PrivateToX25519 derives the X25519 private key matching an Ed25519
+// private key: the hashed seed Ed25519 itself uses as scalar. X25519 clamps
+// it.
+func ed25519PrivateToX25519(priv []byte) []byte {
+	h := sha512.Sum512(priv[:32])
+	return h[:32]
+}
+
+func reverse(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
@@ -0,0 +1,106 @@
+package mailbox
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	pb "github.com/libp2p/go-libp2p-examples/mailbox/pb"
+)
+
+// keyTypes are the recipient keys Seal supports, one per code path of
+// agree and recoverSecret.
+var keyTypes = map[string]int{
+	"Ed25519":   crypto.Ed25519,
+	"Secp256k1": crypto.Secp256k1,
+	"RSA":       crypto.RSA,
+}
+
+func generateKey(t *testing.T, typ int) crypto.PrivKey {
+	t.Helper()
+	priv, _, err := crypto.GenerateKeyPairWithReader(typ, 2048, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func TestSealOpen(t *testing.T) {
+	author := generateKey(t, crypto.Ed25519)
+	authorID, err := peer.IDFromPrivateKey(author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte("meet me at the relay")
+
+	for name, typ := range keyTypes {
+		t.Run(name, func(t *testing.T) {
+			recipient := generateKey(t, typ)
+			envelope, err := Seal(author, recipient.GetPublic(), body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l, err := Open(recipient, envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(l.Body, body) {
+				t.Errorf("body: got %q, want %q", l.Body, body)
+			}
+			if l.From != authorID {
+				t.Errorf("from: got %s, want %s", l.From, authorID)
+			}
+
+			// Another key of the same type can't recover the secret.
+			if _, err := Open(generateKey(t, typ), envelope); err == nil {
+				t.Error("opened a letter sealed for another recipient")
+			}
+		})
+	}
+}
+
+func TestOpenTampered(t *testing.T) {
+	author := generateKey(t, crypto.Ed25519)
+
+	for name, typ := range keyTypes {
+		t.Run(name, func(t *testing.T) {
+			recipient := generateKey(t, typ)
+			envelope, err := Seal(author, recipient.GetPublic(), []byte("pay 10 coins"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for field, tamper := range map[string]func(*pb.Envelope){
+				"ciphertext": func(e *pb.Envelope) { e.Ciphertext[len(e.Ciphertext)/2] ^= 1 },
+				"nonce":      func(e *pb.Envelope) { e.Nonce[0] ^= 1 },
+				"key":        func(e *pb.Envelope) { e.Key[len(e.Key)-1] ^= 1 },
+			} {
+				env := &pb.Envelope{}
+				if err := proto.Unmarshal(envelope, env); err != nil {
+					t.Fatal(err)
+				}
+				tamper(env)
+				tampered, err := proto.Marshal(env)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := Open(recipient, tampered); err == nil {
+					t.Errorf("opened a letter with a tampered %s", field)
+				}
+			}
+		})
+	}
+}
+
+func TestSealUnsupportedKey(t *testing.T) {
+	author := generateKey(t, crypto.Ed25519)
+	recipient := generateKey(t, crypto.ECDSA)
+	if _, err := Seal(author, recipient.GetPublic(), []byte("hi")); !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("got %v, want ErrUnsupportedKey", err)
+	}
+}
@@ -0,0 +1,326 @@
+// Package mailbox lets peers leave end-to-end encrypted messages for peers
+// that are offline. Messages are encrypted to the recipient's libp2p public
+// key and handed to always-on mailbox peers, which keep them until the
+// recipient collects them or they expire.
+package mailbox
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	ggio "github.com/gogo/protobuf/io"
+	uuid "github.com/google/uuid"
+	pb "github.com/libp2p/go-libp2p-examples/mailbox/pb"
+)
+
+// Protocol IDs of the mailbox.
+const (
+	DepositProtocol = "/mailbox/deposit/1.0.0"
+	CollectProtocol = "/mailbox/collect/1.0.0"
+)
+
+// MaxEnvelopeSize is the largest envelope a mailbox accepts.
+const MaxEnvelopeSize = 64 << 10
+
+// maxPageSize bounds the envelopes sent in a single collect response. The
+// rest follow in further responses.
+const maxPageSize = 1 << 20
+
+// maxMessageSize bounds the protocol messages, the largest being a page of
+// envelopes.
+const maxMessageSize = maxPageSize + 64<<10
+
+// Options configures a mailbox. The zero value is usable.
+type Options struct {
+	// MaxTTL is the longest time an envelope is kept. Senders may ask
+	// for less. Defaults to a week.
+	MaxTTL time.Duration
+	// MaxMessages is the number of envelopes a single sender may have
+	// stored at a time, over all recipients. Defaults to 100.
+	MaxMessages int
+	// MaxBytes is the total size of the envelopes a single sender may
+	// have stored at a time. Defaults to 1 MiB.
+	MaxBytes int
+	// MaxRecipientBytes is the total size of the envelopes stored for a
+	// single recipient, over all senders. Peer IDs cost nothing, so the
+	// sender quotas alone don't keep anyone from filling a mailbox.
+	// Defaults to 4 MiB.
+	MaxRecipientBytes int
+}
+
+// Server stores envelopes for offline peers.
+type Server struct {
+	host host.Host
+	opts Options
+	done chan struct{}
+
+	mu     sync.Mutex
+	boxes  map[peer.ID][]*stored // by recipient
+	sizes  map[peer.ID]int       // bytes stored, by recipient
+	usage  map[peer.ID]*usage    // by sender
+	closed bool
+}
+
+type stored struct {
+	id        string
+	sender    peer.ID
+	envelope  []byte
+	deposited time.Time
+	expires   time.Time
+}
+
+type usage struct {
+	messages int
+	bytes    int
+}
+
+// NewServer attaches a mailbox to the given host.
+func NewServer(h host.Host, opts Options) *Server {
+	if opts.MaxTTL <= 0 {
+		opts.MaxTTL = 7 * 24 * time.Hour
+	}
+	if opts.MaxMessages <= 0 {
+		opts.MaxMessages = 100
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 1 << 20
+	}
+	if opts.MaxRecipientBytes <= 0 {
+		opts.MaxRecipientBytes = 4 << 20
+	}
+
+	s := &Server{
+		host:  h,
+		opts:  opts,
+		done:  make(chan struct{}),
+		boxes: make(map[peer.ID][]*stored),
+		sizes: make(map[peer.ID]int),
+		usage: make(map[peer.ID]*usage),
+	}
+	h.SetStreamHandler(DepositProtocol, s.handleDeposit)
+	h.SetStreamHandler(CollectProtocol, s.handleCollect)
+	go s.expireLoop()
+	return s
+}
+
+// Close removes the stream handlers from the host.
+func (s *Server) Close() error {
+	s.host.RemoveStreamHandler(DepositProtocol)
+	s.host.RemoveStreamHandler(CollectProtocol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	return nil
+}
+
+// Stats returns the number of stored envelopes and recipients.
+func (s *Server) Stats() (envelopes, recipients int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, box := range s.boxes {
+		envelopes += len(box)
+	}
+	return envelopes, len(s.boxes)
+}
+
+func (s *Server) handleDeposit(stream network.Stream) {
+	defer stream.Close()
+
+	req := &pb.DepositRequest{}
+	if err := ggio.NewDelimitedReader(stream, maxMessageSize).ReadMsg(req); err != nil {
+		stream.Reset()
+		return
+	}
+
+	// The sender is whoever opened the stream, the connection is
+	// authenticated. Quotas are charged to it.
+	sender := stream.Conn().RemotePeer()
+	resp := &pb.DepositResponse{}
+	if err := s.deposit(sender, req); err != nil {
+		log.Printf("Refused envelope from %s: %s\n", sender.Pretty(), err)
+		resp.Error = err.Error()
+	}
+	if err := ggio.NewDelimitedWriter(stream).WriteMsg(resp); err != nil {
+		stream.Reset()
+	}
+}
+
+func (s *Server) deposit(sender peer.ID, req *pb.DepositRequest) error {
+	recipient, err := peer.IDFromBytes(req.Recipient)
+	if err != nil {
+		return fmt.Errorf("bad recipient: %w", err)
+	}
+	if len(req.Envelope) == 0 || len(req.Envelope) > MaxEnvelopeSize {
+		return fmt.Errorf("envelope must be between 1 and %d bytes", MaxEnvelopeSize)
+	}
+
+	ttl := time.Duration(req.Ttl) * time.Second
+	if ttl <= 0 || ttl > s.opts.MaxTTL {
+		ttl = s.opts.MaxTTL
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := s.usage[sender]
+	if u == nil {
+		u = &usage{}
+		s.usage[sender] = u
+	}
+	if u.messages+1 > s.opts.MaxMessages {
+		return fmt.Errorf("quota exceeded: at most %d stored messages per sender", s.opts.MaxMessages)
+	}
+	if u.bytes+len(req.Envelope) > s.opts.MaxBytes {
+		return fmt.Errorf("quota exceeded: at most %d stored bytes per sender", s.opts.MaxBytes)
+	}
+	if s.sizes[recipient]+len(req.Envelope) > s.opts.MaxRecipientBytes {
+		return fmt.Errorf("mailbox of %s is full", recipient.Pretty())
+	}
+	u.messages++
+	u.bytes += len(req.Envelope)
+	s.sizes[recipient] += len(req.Envelope)
+
+	now := time.Now()
+	s.boxes[recipient] = append(s.boxes[recipient], &stored{
+		id:        uuid.New().String(),
+		sender:    sender,
+		envelope:  req.Envelope,
+		deposited: now,
+		expires:   now.Add(ttl),
+	})
+	log.Printf("Stored %d byte envelope from %s for %s, expires in %s\n", len(req.Envelope), sender.Pretty(), recipient.Pretty(), ttl)
+	return nil
+}
+
+func (s *Server) handleCollect(stream network.Stream) {
+	defer stream.Close()
+
+	reader := ggio.NewDelimitedReader(stream, maxMessageSize)
+	writer := ggio.NewDelimitedWriter(stream)
+
+	if err := reader.ReadMsg(&pb.CollectRequest{}); err != nil {
+		stream.Reset()
+		return
+	}
+
+	// Only the recipient itself can collect its envelopes.
+	recipient := stream.Conn().RemotePeer()
+	sent := make(map[string]bool)
+	collected := 0
+	for {
+		resp := s.page(recipient, sent)
+		if err := writer.WriteMsg(resp); err != nil {
+			stream.Reset()
+			return
+		}
+		if len(resp.Envelopes) == 0 {
+			break
+		}
+
+		// Envelopes are only deleted once the recipient confirms it got
+		// them, a broken connection must not lose messages.
+		ack := &pb.CollectAck{}
+		if err := reader.ReadMsg(ack); err != nil {
+			stream.Reset()
+			return
+		}
+		done := make(map[string]bool, len(ack.Ids))
+		for _, id := range ack.Ids {
+			done[id] = true
+		}
+
+		s.mu.Lock()
+		s.remove(recipient, func(st *stored) bool { return done[st.id] })
+		s.mu.Unlock()
+		collected += len(done)
+
+		if !resp.More {
+			break
+		}
+	}
+	if collected > 0 {
+		log.Printf("%s collected %d envelopes\n", recipient.Pretty(), collected)
+	}
+}
+
+// page returns the next page of envelopes for recipient, at most
+// maxPageSize bytes of those not sent yet, and adds them to sent.
+func (s *Server) page(recipient peer.ID, sent map[string]bool) *pb.CollectResponse {
+	resp := &pb.CollectResponse{}
+	size := 0
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, st := range s.boxes[recipient] {
+		if sent[st.id] || !now.Before(st.expires) {
+			continue
+		}
+		if size+len(st.envelope) > maxPageSize {
+			resp.More = true
+			break
+		}
+		size += len(st.envelope)
+		sent[st.id] = true
+		resp.Envelopes = append(resp.Envelopes, &pb.StoredEnvelope{
+			Id:        st.id,
+			Envelope:  st.envelope,
+			Deposited: st.deposited.Unix(),
+		})
+	}
+	return resp
+}
+
+// remove deletes the envelopes of a recipient matching drop, and releases
+// their quota. s.mu must be held.
+func (s *Server) remove(recipient peer.ID, drop func(*stored) bool) {
+	box := s.boxes[recipient][:0]
+	for _, st := range s.boxes[recipient] {
+		if !drop(st) {
+			box = append(box, st)
+			continue
+		}
+		s.sizes[recipient] -= len(st.envelope)
+		if u := s.usage[st.sender]; u != nil {
+			u.messages--
+			u.bytes -= len(st.envelope)
+			if u.messages == 0 {
+				delete(s.usage, st.sender)
+			}
+		}
+	}
+	if len(box) == 0 {
+		delete(s.boxes, recipient)
+		delete(s.sizes, recipient)
+	} else {
+		s.boxes[recipient] = box
+	}
+}
+
+// expireLoop drops expired envelopes every minute.
+func (s *Server) expireLoop() {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			s.mu.Lock()
+			for recipient := range s.boxes {
+				s.remove(recipient, func(st *stored) bool { return !now.Before(st.expires) })
+			}
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
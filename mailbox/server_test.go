@@ -0,0 +1,110 @@
+package mailbox
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/libp2p/go-libp2p-examples/harness"
+)
+
+// newMailbox starts a mailbox on host 0 and n other hosts, all connected to
+// it.
+func newMailbox(t *testing.T, n int, opts Options) (*harness.Network, *Server) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	nw, err := harness.New(ctx, n+1, harness.Mocknet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nw.Close() })
+	if err := nw.Connect(ctx, harness.Star(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(nw.Host(0), opts)
+	t.Cleanup(func() { s.Close() })
+	return nw, s
+}
+
+// send leaves a letter from host i for host j with the mailbox of host 0.
+func send(nw *harness.Network, i, j int, body []byte) error {
+	h := nw.Host(i)
+	_, err := Send(context.Background(), h, h.Peerstore().PrivKey(h.ID()), nw.Host(j).ID(), []peer.ID{nw.Host(0).ID()}, body, 0)
+	return err
+}
+
+func TestCollectPages(t *testing.T) {
+	nw, s := newMailbox(t, 3, Options{})
+
+	// Two senders leave more than a page worth of letters for host 3.
+	body := bytes.Repeat([]byte("x"), 50<<10)
+	sent := 0
+	for i := 1; i <= 2; i++ {
+		for n := 0; n < 15; n++ {
+			if err := send(nw, i, 3, body); err != nil {
+				t.Fatal(err)
+			}
+			sent++
+		}
+	}
+	if sent*len(body) <= maxPageSize {
+		t.Fatalf("%d letters fit in a single page", sent)
+	}
+
+	h := nw.Host(3)
+	letters, err := Collect(context.Background(), h, h.Peerstore().PrivKey(h.ID()), nw.Host(0).ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != sent {
+		t.Fatalf("collected %d letters, %d were sent", len(letters), sent)
+	}
+	// Collect doesn't wait for the server to handle the last ack.
+	if !harness.Eventually(5*time.Second, func() bool {
+		envelopes, _ := s.Stats()
+		return envelopes == 0
+	}) {
+		envelopes, _ := s.Stats()
+		t.Fatalf("%d envelopes left after collecting", envelopes)
+	}
+
+	letters, err = Collect(context.Background(), h, h.Peerstore().PrivKey(h.ID()), nw.Host(0).ID())
+	if err != nil || len(letters) != 0 {
+		t.Fatalf("collected %d letters again, error %v", len(letters), err)
+	}
+}
+
+func TestRecipientQuota(t *testing.T) {
+	nw, _ := newMailbox(t, 3, Options{MaxRecipientBytes: 100 << 10})
+
+	body := bytes.Repeat([]byte("x"), 40<<10)
+	for i := 1; i <= 2; i++ {
+		if err := send(nw, i, 3, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each sender is within its own quota, but the mailbox of host 3 is
+	// full.
+	if err := send(nw, 1, 3, body); err == nil || !strings.Contains(err.Error(), "full") {
+		t.Fatalf("third letter: got error %v, expected a full mailbox", err)
+	}
+	// Other recipients aren't affected.
+	if err := send(nw, 1, 2, body); err != nil {
+		t.Fatal(err)
+	}
+
+	// Collecting makes room again.
+	h := nw.Host(3)
+	if _, err := Collect(context.Background(), h, h.Peerstore().PrivKey(h.ID()), nw.Host(0).ID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := send(nw, 1, 3, body); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -13,9 +13,10 @@ import (
 	pb "github.com/libp2p/go-libp2p-examples/multipro/pb"
 )
 
-// pattern: /protocol-name/request-or-response-message/version
-const echoRequest = "/echo/echoreq/0.0.1"
-const echoResponse = "/echo/echoresp/0.0.1"
+// pattern: /protocol-name/request-or-response-message
+// the version is appended when opening streams, e.g. /echo/echoreq/0.0.1. see version.go
+const echoRequest = "/echo/echoreq"
+const echoResponse = "/echo/echoresp"
 
 type EchoProtocol struct {
 	node     *Node                      // local host
@@ -25,8 +26,8 @@ type EchoProtocol struct {
 
 func NewEchoProtocol(node *Node, done chan bool) *EchoProtocol {
 	e := EchoProtocol{node: node, requests: make(map[string]*pb.EchoRequest), done: done}
-	node.SetStreamHandler(echoRequest, e.onEchoRequest)
-	node.SetStreamHandler(echoResponse, e.onEchoResponse)
+	node.setStreamHandler(echoRequest, e.onEchoRequest)
+	node.setStreamHandler(echoResponse, e.onEchoResponse)
 
 	// design note: to implement fire-and-forget style messages you may just skip specifying a response callback.
 	// a fire-and-forget message will just include a request and not specify a response object
@@ -63,6 +64,11 @@ func (e *EchoProtocol) onEchoRequest(s network.Stream) {
 		return
 	}
 
+	// reject messages from clients we can't talk to
+	if !e.node.acceptClientVersion(s, data.MessageData) {
+		return
+	}
+
 	log.Printf("%s: Sending echo response to %s. Message id: %s...", s.Conn().LocalPeer(), s.Conn().RemotePeer(), data.MessageData.Id)
 
 	// send response to the request using the message string he provided
@@ -81,7 +87,7 @@ func (e *EchoProtocol) onEchoRequest(s network.Stream) {
 	// add the signature to the message
 	resp.MessageData.Sign = signature
 
-	ok := e.node.sendProtoMessage(s.Conn().RemotePeer(), echoResponse, resp)
+	ok := e.node.sendProtoMessage(s.Conn().RemotePeer(), e.node.responseIDs(s, echoResponse), resp)
 
 	if ok {
 		log.Printf("%s: Echo response to %s sent.", s.Conn().LocalPeer().String(), s.Conn().RemotePeer().String())
@@ -115,6 +121,11 @@ func (e *EchoProtocol) onEchoResponse(s network.Stream) {
 		return
 	}
 
+	// reject messages from clients we can't talk to
+	if !e.node.acceptClientVersion(s, data.MessageData) {
+		return
+	}
+
 	// locate request data and remove it if found
 	req, ok := e.requests[data.MessageData.Id]
 	if ok {
@@ -150,14 +161,16 @@ func (e *EchoProtocol) Echo(host host.Host) bool {
 	// add the signature to the message
 	req.MessageData.Sign = signature
 
-	ok := e.node.sendProtoMessage(host.ID(), echoRequest, req)
+	// store request so response handler has access to it, the response may arrive before sendProtoMessage returns
+	e.requests[req.MessageData.Id] = req
+
+	ok := e.node.sendProtoMessage(host.ID(), e.node.protocolIDs(echoRequest), req)
 
 	if !ok {
+		delete(e.requests, req.MessageData.Id)
 		return false
 	}
 
-	// store request so response handler has access to it
-	e.requests[req.MessageData.Id] = req
 	log.Printf("%s: Echo to: %s was sent. Message Id: %s, Message: %s", e.node.ID(), host.ID(), req.MessageData.Id, req.Message)
 	return true
 }
@@ -2,33 +2,60 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
+	"path/filepath"
 
-	"github.com/libp2p/go-libp2p"
-	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peerstore"
-
-	ma "github.com/multiformats/go-multiaddr"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
 )
 
 // helper method - create a lib-p2p host to listen on a port
-func makeRandomNode(port int, done chan bool) *Node {
-	// Ignoring most errors for brevity
-	// See echo example for more details and better implementation
-	priv, _, _ := crypto.GenerateKeyPair(crypto.Secp256k1, 256)
-	listen, _ := ma.NewMultiaddr(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port))
-	host, _ := libp2p.New(
-		context.Background(),
-		libp2p.ListenAddrs(listen),
-		libp2p.Identity(priv),
-	)
-
-	return NewNode(host, done)
+// cfg is shared by both nodes, but each one has its own identity and port
+// keyFile is optional: when set, the node keeps its identity in that file
+// version is the node client version, which selects the protocol versions it speaks
+func makeRandomNode(port int, done chan bool, cfg hostconfig.Config, keyFile string, version string) *Node {
+	cfg.Identity = hostconfig.Identity{File: keyFile, Type: "secp256k1"}
+	cfg.ListenAddrs = []string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)}
+	host, err := hostconfig.New(context.Background(), &cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	node, err := NewNode(host, version, done)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return node
+}
+
+// keyPath returns the key file for a node, or "" if keys are not persisted
+func keyPath(dir, name string) string {
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, name)
 }
 
 func main() {
+	keyDir := flag.String("keys", "", "directory to keep the node keys in, so peer IDs survive restarts")
+	// by default an old node talks to a newer one, so they have to agree on the protocol versions to use
+	version1 := flag.String("v1", "1.0.0", "client version of the first node")
+	version2 := flag.String("v2", "1.1.0", "client version of the second node")
+	configFile := flag.String("config", "", "build both nodes from this configuration file, YAML or JSON, except for their identity and listen address")
+	flag.Parse()
+
+	var cfg hostconfig.Config
+	if *configFile != "" {
+		c, err := hostconfig.Load(*configFile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		cfg = *c
+	}
+
 	// Choose random ports between 10000-10100
 	rand.Seed(666)
 	port1 := rand.Intn(100) + 10000
@@ -37,21 +64,23 @@ func main() {
 	done := make(chan bool, 1)
 
 	// Make 2 hosts
-	h1 := makeRandomNode(port1, done)
-	h2 := makeRandomNode(port2, done)
+	h1 := makeRandomNode(port1, done, cfg, keyPath(*keyDir, "node1.key"), *version1)
+	h2 := makeRandomNode(port2, done, cfg, keyPath(*keyDir, "node2.key"), *version2)
 	h1.Peerstore().AddAddrs(h2.ID(), h2.Addrs(), peerstore.PermanentAddrTTL)
 	h2.Peerstore().AddAddrs(h1.ID(), h1.Addrs(), peerstore.PermanentAddrTTL)
 
-	log.Printf("This is a conversation between %s and %s\n", h1.ID(), h2.ID())
+	log.Printf("This is a conversation between %s (v%s) and %s (v%s)\n", h1.ID(), h1.version, h2.ID(), h2.version)
 
-	// send messages using the protocols
-	h1.Ping(h2.Host)
-	h2.Ping(h1.Host)
-	h1.Echo(h2.Host)
-	h2.Echo(h1.Host)
+	// send messages using the protocols. nodes with incompatible versions fail to agree on a protocol
+	sent := 0
+	for _, ok := range []bool{h1.Ping(h2.Host), h2.Ping(h1.Host), h1.Echo(h2.Host), h2.Echo(h1.Host)} {
+		if ok {
+			sent++
+		}
+	}
 
 	// block until all responses have been processed
-	for i := 0; i < 4; i++ {
+	for i := 0; i < sent; i++ {
 		<-done
 	}
 }
@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/protocol"
 
@@ -15,23 +17,68 @@ import (
 	p2p "github.com/libp2p/go-libp2p-examples/multipro/pb"
 )
 
-// node client version
-const clientVersion = "go-p2p-node/0.0.1"
-
 // Node type - a p2p host implementing one or more p2p protocols
 type Node struct {
 	host.Host     // lib-p2p host
 	*PingProtocol // ping protocol impl
 	*EchoProtocol // echo protocol impl
 	// add other protocols here...
+
+	version  version   // node client version
+	versions []version // protocol versions spoken by this node, newest first
 }
 
 // Create a new node with its implemented protocols
-func NewNode(host host.Host, done chan bool) *Node {
-	node := &Node{Host: host}
+// nodeVersion: the node client version (major.minor.patch), which also selects the protocol versions the node speaks
+func NewNode(host host.Host, nodeVersion string, done chan bool) (*Node, error) {
+	v, err := parseVersion(nodeVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := supportedVersions(v)
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no known protocol version is compatible with node version %s", v)
+	}
+
+	node := &Node{Host: host, version: v, versions: versions}
 	node.PingProtocol = NewPingProtocol(node, done)
 	node.EchoProtocol = NewEchoProtocol(node, done)
-	return node
+	return node, nil
+}
+
+// helper method - register a handler for all supported versions of a protocol
+// the handler is registered for the newest version and accepts older compatible versions through semver matching
+func (n *Node) setStreamHandler(base string, handler network.StreamHandler) {
+	pid := protocolID(base, n.versions[0])
+	match, err := semverMatcher(pid)
+	if err != nil {
+		panic(err)
+	}
+	n.SetStreamHandlerMatch(pid, match, handler)
+}
+
+// helper method - all versioned ids of a protocol spoken by this node, newest first
+// passing all of them to NewStream lets multistream pick the newest version both peers support
+func (n *Node) protocolIDs(base string) []protocol.ID {
+	ids := make([]protocol.ID, len(n.versions))
+	for i, v := range n.versions {
+		ids[i] = protocolID(base, v)
+	}
+	return ids
+}
+
+// helper method - the protocol ids to use for a response to a request received on stream s
+// a response is sent in the protocol version the request was sent in, so older peers can understand it
+func (n *Node) responseIDs(s network.Stream, base string) []protocol.ID {
+	_, v, err := splitProtocolID(s.Protocol())
+	if err != nil {
+		return n.protocolIDs(base)
+	}
+	if v.less(n.versions[0]) {
+		log.Printf("%s: Responding to %s with %s version %s", n.ID(), s.Conn().RemotePeer(), base, v)
+	}
+	return []protocol.ID{protocolID(base, v)}
 }
 
 // Authenticate incoming p2p message
@@ -127,7 +174,7 @@ func (n *Node) NewMessageData(messageId string, gossip bool) *p2p.MessageData {
 		panic("Failed to get public key for sender from local peer store.")
 	}
 
-	return &p2p.MessageData{ClientVersion: clientVersion,
+	return &p2p.MessageData{ClientVersion: clientName + "/" + n.version.String(),
 		NodeId:     peer.IDB58Encode(n.ID()),
 		NodePubKey: nodePubKey,
 		Timestamp:  time.Now().Unix(),
@@ -136,16 +183,19 @@ func (n *Node) NewMessageData(messageId string, gossip bool) *p2p.MessageData {
 }
 
 // helper method - writes a protobuf go data object to a network stream
+// id: peer to send the message to
+// pids: versioned protocol ids to offer, newest first. the first one supported by the remote peer is used
 // data: reference of protobuf go data object to send (not the object itself)
-// s: network stream to write the data to
-func (n *Node) sendProtoMessage(id peer.ID, p protocol.ID, data proto.Message) bool {
-	s, err := n.NewStream(context.Background(), id, p)
+func (n *Node) sendProtoMessage(id peer.ID, pids []protocol.ID, data proto.Message) bool {
+	s, err := n.NewStream(context.Background(), id, pids...)
 	if err != nil {
 		log.Println(err)
 		return false
 	}
 	defer s.Close()
 
+	log.Printf("%s: Negotiated %s with %s", n.ID(), s.Protocol(), id)
+
 	writer := ggio.NewFullWriter(s)
 	err = writer.WriteMsg(data)
 	if err != nil {
@@ -13,9 +13,10 @@ import (
 	p2p "github.com/libp2p/go-libp2p-examples/multipro/pb"
 )
 
-// pattern: /protocol-name/request-or-response-message/version
-const pingRequest = "/ping/pingreq/0.0.1"
-const pingResponse = "/ping/pingresp/0.0.1"
+// pattern: /protocol-name/request-or-response-message
+// the version is appended when opening streams, e.g. /ping/pingreq/0.0.1. see version.go
+const pingRequest = "/ping/pingreq"
+const pingResponse = "/ping/pingresp"
 
 // PingProtocol type
 type PingProtocol struct {
@@ -26,8 +27,8 @@ type PingProtocol struct {
 
 func NewPingProtocol(node *Node, done chan bool) *PingProtocol {
 	p := &PingProtocol{node: node, requests: make(map[string]*p2p.PingRequest), done: done}
-	node.SetStreamHandler(pingRequest, p.onPingRequest)
-	node.SetStreamHandler(pingResponse, p.onPingResponse)
+	node.setStreamHandler(pingRequest, p.onPingRequest)
+	node.setStreamHandler(pingResponse, p.onPingResponse)
 	return p
 }
 
@@ -60,6 +61,11 @@ func (p *PingProtocol) onPingRequest(s network.Stream) {
 		return
 	}
 
+	// reject messages from clients we can't talk to
+	if !p.node.acceptClientVersion(s, data.MessageData) {
+		return
+	}
+
 	// generate response message
 	log.Printf("%s: Sending ping response to %s. Message id: %s...", s.Conn().LocalPeer(), s.Conn().RemotePeer(), data.MessageData.Id)
 
@@ -77,7 +83,7 @@ func (p *PingProtocol) onPingRequest(s network.Stream) {
 	resp.MessageData.Sign = signature
 
 	// send the response
-	ok := p.node.sendProtoMessage(s.Conn().RemotePeer(), pingResponse, resp)
+	ok := p.node.sendProtoMessage(s.Conn().RemotePeer(), p.node.responseIDs(s, pingResponse), resp)
 
 	if ok {
 		log.Printf("%s: Ping response to %s sent.", s.Conn().LocalPeer().String(), s.Conn().RemotePeer().String())
@@ -109,6 +115,11 @@ func (p *PingProtocol) onPingResponse(s network.Stream) {
 		return
 	}
 
+	// reject messages from clients we can't talk to
+	if !p.node.acceptClientVersion(s, data.MessageData) {
+		return
+	}
+
 	// locate request data and remove it if found
 	_, ok := p.requests[data.MessageData.Id]
 	if ok {
@@ -140,13 +151,15 @@ func (p *PingProtocol) Ping(host host.Host) bool {
 	// add the signature to the message
 	req.MessageData.Sign = signature
 
-	ok := p.node.sendProtoMessage(host.ID(), pingRequest, req)
+	// store ref request so response handler has access to it, the response may arrive before sendProtoMessage returns
+	p.requests[req.MessageData.Id] = req
+
+	ok := p.node.sendProtoMessage(host.ID(), p.node.protocolIDs(pingRequest), req)
 	if !ok {
+		delete(p.requests, req.MessageData.Id)
 		return false
 	}
 
-	// store ref request so response handler has access to it
-	p.requests[req.MessageData.Id] = req
 	log.Printf("%s: Ping to: %s was sent. Message Id: %s, Message: %s", p.node.ID(), host.ID(), req.MessageData.Id, req.Message)
 	return true
 }
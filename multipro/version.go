@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/protocol"
+
+	p2p "github.com/libp2p/go-libp2p-examples/multipro/pb"
+)
+
+// name reported in MessageData.ClientVersion, followed by the node version
+const clientName = "go-p2p-node"
+
+// all protocol versions known to this code base, newest first.
+// a node speaks every version up to and including its own version that is compatible with it
+var protocolVersions = []string{"1.1.0", "1.0.0", "0.1.0", "0.0.1"}
+
+// a parsed major.minor.patch version
+type version struct {
+	major, minor, patch int
+}
+
+func parseVersion(s string) (version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version{}, fmt.Errorf("invalid version %q", s)
+	}
+
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return version{}, fmt.Errorf("invalid version %q", s)
+		}
+		nums[i] = n
+	}
+	return version{nums[0], nums[1], nums[2]}, nil
+}
+
+func (v version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+// less reports whether v is an older version than o
+func (v version) less(o version) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	if v.minor != o.minor {
+		return v.minor < o.minor
+	}
+	return v.patch < o.patch
+}
+
+// compatible reports whether messages from a peer at version o can be handled by a node at version v.
+// versions are compatible when they share the same major version. as in semver, major version 0 is for
+// initial development where anything may change, so 0.x versions must share the minor version as well
+func (v version) compatible(o version) bool {
+	if v.major == 0 || o.major == 0 {
+		return v.major == o.major && v.minor == o.minor
+	}
+	return v.major == o.major
+}
+
+// supportedVersions returns the protocol versions a node at version v speaks, newest first
+func supportedVersions(v version) []version {
+	var res []version
+	for _, s := range protocolVersions {
+		pv, err := parseVersion(s)
+		if err != nil {
+			panic(err)
+		}
+		if v.compatible(pv) && !v.less(pv) {
+			res = append(res, pv)
+		}
+	}
+	return res
+}
+
+// protocolID builds a versioned protocol id, e.g. /echo/echoreq + 0.0.1 = /echo/echoreq/0.0.1
+func protocolID(base string, v version) protocol.ID {
+	return protocol.ID(base + "/" + v.String())
+}
+
+// splitProtocolID splits a versioned protocol id into its base and version
+func splitProtocolID(id protocol.ID) (string, version, error) {
+	i := strings.LastIndex(string(id), "/")
+	if i < 0 {
+		return "", version{}, fmt.Errorf("protocol id %s has no version", id)
+	}
+	v, err := parseVersion(string(id)[i+1:])
+	return string(id)[:i], v, err
+}
+
+// semverMatcher returns a multistream match function for the given versioned protocol id.
+// a proposed protocol matches if it has the same base and a compatible version not newer than ours.
+// unlike go-libp2p-core's MultistreamSemverMatcher, 0.x versions only match the same minor version
+func semverMatcher(id protocol.ID) (func(string) bool, error) {
+	base, v, err := splitProtocolID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(check string) bool {
+		cbase, cv, err := splitProtocolID(protocol.ID(check))
+		if err != nil || cbase != base {
+			return false
+		}
+		return v.compatible(cv) && !v.less(cv)
+	}, nil
+}
+
+// parseClientVersion extracts the node version from a MessageData.ClientVersion string (name/version)
+func parseClientVersion(clientVersion string) (string, version, error) {
+	i := strings.LastIndex(clientVersion, "/")
+	if i < 0 {
+		return "", version{}, fmt.Errorf("invalid client version %q", clientVersion)
+	}
+	v, err := parseVersion(clientVersion[i+1:])
+	return clientVersion[:i], v, err
+}
+
+// checkClientVersion returns an error if the message author runs a client this node can't talk to
+func (n *Node) checkClientVersion(data *p2p.MessageData) error {
+	name, v, err := parseClientVersion(data.ClientVersion)
+	if err != nil {
+		return err
+	}
+	if name != clientName {
+		return fmt.Errorf("unknown client %q", name)
+	}
+	if !n.version.compatible(v) {
+		return fmt.Errorf("incompatible client version %s, this node runs %s", v, n.version)
+	}
+	return nil
+}
+
+// acceptClientVersion reports whether a message received on s comes from a client this node can talk to.
+// other messages are logged and their stream reset
+func (n *Node) acceptClientVersion(s network.Stream, data *p2p.MessageData) bool {
+	if err := n.checkClientVersion(data); err != nil {
+		log.Printf("%s: Rejecting message from %s: %s", s.Conn().LocalPeer(), s.Conn().RemotePeer(), err)
+		s.Reset()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/protocol"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestCompatible(t *testing.T) {
+	for _, tc := range []struct {
+		v, o string
+		want bool
+	}{
+		{"1.0.0", "1.0.0", true},
+		{"1.1.0", "1.0.0", true},
+		{"1.0.0", "1.1.0", true},
+		{"1.0.0", "2.0.0", false},
+		{"1.0.0", "0.0.1", false},
+		{"0.0.1", "0.0.2", true},
+		{"0.0.1", "0.1.0", false},
+		{"0.1.0", "0.2.0", false},
+	} {
+		v, o := mustParseVersion(t, tc.v), mustParseVersion(t, tc.o)
+		if got := v.compatible(o); got != tc.want {
+			t.Errorf("%s compatible with %s = %v, want %v", v, o, got, tc.want)
+		}
+	}
+}
+
+func TestSemverMatcher(t *testing.T) {
+	for _, tc := range []struct {
+		id, check string
+		want      bool
+	}{
+		{"/echo/echoreq/1.1.0", "/echo/echoreq/1.1.0", true},
+		{"/echo/echoreq/1.1.0", "/echo/echoreq/1.0.0", true},
+		{"/echo/echoreq/1.0.0", "/echo/echoreq/1.1.0", false},
+		{"/echo/echoreq/1.1.0", "/echo/echoreq/0.0.1", false},
+		{"/echo/echoreq/0.1.0", "/echo/echoreq/0.0.1", false},
+		{"/echo/echoreq/1.1.0", "/ping/pingreq/1.1.0", false},
+	} {
+		match, err := semverMatcher(protocol.ID(tc.id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := match(tc.check); got != tc.want {
+			t.Errorf("%s matching %s = %v, want %v", tc.id, tc.check, got, tc.want)
+		}
+	}
+}
+
+func TestVersions(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		v1, v2 string
+		talk   bool
+	}{
+		{"same version", "1.1.0", "1.1.0", true},
+		{"older minor", "1.0.0", "1.1.0", true},
+		{"other major", "0.0.1", "1.1.0", false},
+		{"same 0.x minor", "0.1.0", "0.1.0", true},
+		{"other 0.x minor", "0.0.1", "0.1.0", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			n1, n2, done := mockNodes(t, tc.v1, tc.v2)
+
+			if ok := n1.Ping(n2.Host); ok != tc.talk {
+				t.Fatalf("ping from v%s to v%s sent = %v, want %v", tc.v1, tc.v2, ok, tc.talk)
+			}
+			if ok := n2.Echo(n1.Host); ok != tc.talk {
+				t.Fatalf("echo from v%s to v%s sent = %v, want %v", tc.v2, tc.v1, ok, tc.talk)
+			}
+			if !tc.talk {
+				// Negotiation failed without breaking the connection
+				// or reaching a handler.
+				if len(n1.Network().ConnsToPeer(n2.ID())) == 0 {
+					t.Fatal("failed negotiation closed the connection")
+				}
+				select {
+				case <-done:
+					t.Fatal("a handler ran although no protocol was agreed on")
+				case <-time.After(200 * time.Millisecond):
+				}
+				return
+			}
+			for i := 0; i < 2; i++ {
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("got %d responses out of 2", i)
+				}
+			}
+		})
+	}
+}
+
+func TestRejectIncompatibleClient(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		v1, v2 string
+		claim  string // the version n2 puts in its messages
+	}{
+		{"other major", "1.0.0", "1.1.0", "2.0.0"},
+		{"other 0.x minor", "0.1.0", "0.1.0", "0.0.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			n1, n2, done := mockNodes(t, tc.v1, tc.v2)
+
+			// n2 claims an incompatible version in its messages, although it negotiates protocols n1 speaks
+			n2.version = mustParseVersion(t, tc.claim)
+			if !n2.Ping(n1.Host) {
+				t.Fatal("ping not sent")
+			}
+			select {
+			case <-done:
+				t.Fatalf("ping from a v%s client was answered by v%s", tc.claim, tc.v1)
+			case <-time.After(500 * time.Millisecond):
+			}
+		})
+	}
+}
+
+// mockNodes returns two connected nodes on a mocknet, and the channel their responses are reported on
+func mockNodes(t *testing.T, v1, v2 string) (*Node, *Node, chan bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	mn := mocknet.New(ctx)
+
+	done := make(chan bool, 4)
+	var nodes []*Node
+	for i, v := range []string{v1, v2} {
+		// mocknet's GenPeer keys can't sign, and nodes sign their messages
+		priv, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := mn.AddPeer(priv, ma.StringCast(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", 4001+i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := NewNode(h, v, done)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, n)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatal(err)
+	}
+	return nodes[0], nodes[1], done
+}
+
+func mustParseVersion(t *testing.T, s string) version {
+	v, err := parseVersion(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
@@ -0,0 +1,369 @@
+// Package natstatus tells how reachable a host is, and why.
+//
+// libp2p finds out a lot about the network a host is in, but mostly keeps
+// it to itself: whether AutoNAT found the host reachable from the outside,
+// the addresses other peers see it at, the ports the router mapped, and
+// the relays AutoRelay advertises. A Monitor gathers all of it, and emits
+// an EvtStatusChanged on the event bus of the host whenever it changes.
+//
+// It only sees what go-libp2p v0.13 lets out. AutoNAT keeps its probes to
+// itself, and only announces the reachability it settles on: the results
+// of single probes, and the peers that ran them, are not reported. Circuit
+// relay v1 has no reservations either, the relays reported are those the
+// host advertises circuit addresses through.
+package natstatus
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/event"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/libp2p/go-libp2p-examples/relaydial"
+	basichost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	identify "github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+// DefaultInterval is how often a Monitor looks at what isn't announced on
+// the event bus: observed addresses and port mappings.
+const DefaultInterval = 10 * time.Second
+
+// PortMapping is the state of the port mapping of a host, through UPnP or
+// NAT-PMP.
+type PortMapping int
+
+const (
+	// PortMappingDisabled means the host doesn't map ports, or wasn't
+	// built with Monitor.NATPortMap.
+	PortMappingDisabled PortMapping = iota
+	// PortMappingSearching means the host is looking for a router that
+	// maps ports.
+	PortMappingSearching
+	// PortMappingNoDevice means no router offered to map ports.
+	PortMappingNoDevice
+	// PortMappingActive means a router maps ports, see Status.Mappings.
+	PortMappingActive
+)
+
+func (pm PortMapping) String() string {
+	switch pm {
+	case PortMappingDisabled:
+		return "disabled"
+	case PortMappingSearching:
+		return "searching for a router"
+	case PortMappingNoDevice:
+		return "no router found"
+	case PortMappingActive:
+		return "active"
+	default:
+		return fmt.Sprintf("PortMapping(%d)", int(pm))
+	}
+}
+
+// Mapping is a port the router was asked to forward to the host.
+type Mapping struct {
+	Protocol     string // "tcp" or "udp"
+	InternalPort int
+	// ExternalAddr is the address the router forwards, nil until it
+	// accepted the mapping.
+	ExternalAddr ma.Multiaddr
+	// Err tells why ExternalAddr is nil.
+	Err string
+}
+
+func (m Mapping) String() string {
+	if m.ExternalAddr == nil {
+		return fmt.Sprintf("%s port %d: %s", m.Protocol, m.InternalPort, m.Err)
+	}
+	return fmt.Sprintf("%s port %d: %s", m.Protocol, m.InternalPort, m.ExternalAddr)
+}
+
+// Status is what a host knows about its reachability.
+type Status struct {
+	// Reachability is what AutoNAT found out, by asking other peers to
+	// dial the host back. It is unknown until enough peers did.
+	Reachability network.Reachability
+	// Since is when the reachability last changed, or when the Monitor
+	// started.
+	Since time.Time
+	// ObservedAddrs are the addresses other peers see the host at, as
+	// told by identify. Behind a NAT, those are the NAT's.
+	ObservedAddrs []ma.Multiaddr
+	PortMapping   PortMapping
+	Mappings      []Mapping
+	// Relays are the relays the host advertises circuit addresses
+	// through, which AutoRelay picks once the host is found private.
+	Relays []peer.ID
+	// Addrs are the addresses the host advertises.
+	Addrs []ma.Multiaddr
+}
+
+// EvtStatusChanged is emitted on the event bus of the host when its
+// status changes.
+type EvtStatusChanged struct {
+	Status Status
+	// Changes describes what changed, one line each.
+	Changes []string
+}
+
+// Monitor watches the status of a host.
+type Monitor struct {
+	// Interval is how often the status is polled, DefaultInterval unless
+	// set before Start.
+	Interval time.Duration
+
+	host    host.Host
+	emitter event.Emitter
+	sub     event.Subscription
+	done    chan struct{}
+	wg      sync.WaitGroup
+	closed  sync.Once
+
+	mu     sync.Mutex
+	natmgr basichost.NATManager
+	status Status
+}
+
+// NewMonitor returns a Monitor, to be started once the host is built.
+func NewMonitor() *Monitor {
+	return &Monitor{Interval: DefaultInterval, done: make(chan struct{})}
+}
+
+// NATPortMap is libp2p.NATPortMap, letting the Monitor see the mappings.
+// Use it instead of libp2p.NATPortMap when building the host.
+func (m *Monitor) NATPortMap() libp2p.Option {
+	return libp2p.NATManager(func(n network.Network) basichost.NATManager {
+		nm := basichost.NewNATManager(n)
+		m.mu.Lock()
+		m.natmgr = nm
+		m.mu.Unlock()
+		return nm
+	})
+}
+
+// Start starts watching h.
+func (m *Monitor) Start(h host.Host) error {
+	emitter, err := h.EventBus().Emitter(new(EvtStatusChanged))
+	if err != nil {
+		return err
+	}
+	sub, err := h.EventBus().Subscribe([]interface{}{
+		new(event.EvtLocalReachabilityChanged),
+		new(event.EvtLocalAddressesUpdated),
+	})
+	if err != nil {
+		emitter.Close()
+		return err
+	}
+	m.host, m.emitter, m.sub = h, emitter, sub
+
+	m.mu.Lock()
+	m.status = m.poll(Status{Since: time.Now()})
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.loop()
+	return nil
+}
+
+// Close stops the Monitor. It may be called more than once, and whether
+// the Monitor was started or not.
+func (m *Monitor) Close() error {
+	var err error
+	m.closed.Do(func() {
+		close(m.done)
+		if m.sub == nil {
+			return
+		}
+		m.sub.Close()
+		m.wg.Wait()
+		err = m.emitter.Close()
+	})
+	return err
+}
+
+// Status returns the current status of the host.
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+func (m *Monitor) loop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-m.sub.Out():
+			if !ok {
+				return
+			}
+			if evt, ok := e.(event.EvtLocalReachabilityChanged); ok {
+				m.update(func(s *Status) {
+					if s.Reachability != evt.Reachability {
+						s.Reachability = evt.Reachability
+						s.Since = time.Now()
+					}
+				})
+			} else {
+				m.update(nil)
+			}
+		case <-ticker.C:
+			m.update(nil)
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// update applies f to the status, polls the rest of it, and emits the
+// changes if any.
+func (m *Monitor) update(f func(*Status)) {
+	m.mu.Lock()
+	old := m.status
+	s := old
+	if f != nil {
+		f(&s)
+	}
+	s = m.poll(s)
+	m.status = s
+	m.mu.Unlock()
+
+	if changes := diff(old, s); len(changes) > 0 {
+		m.emitter.Emit(EvtStatusChanged{Status: s, Changes: changes})
+	}
+}
+
+// poll fills in the parts of s nobody announces. It is called with m.mu
+// held.
+func (m *Monitor) poll(s Status) Status {
+	s.ObservedAddrs = nil
+	if ids, ok := m.host.(interface{ IDService() *identify.IDService }); ok {
+		s.ObservedAddrs = sortAddrs(ids.IDService().OwnObservedAddrs())
+	}
+
+	s.PortMapping, s.Mappings = PortMappingDisabled, nil
+	if m.natmgr != nil {
+		s.PortMapping = PortMappingSearching
+		select {
+		case <-m.natmgr.Ready():
+			s.PortMapping = PortMappingNoDevice
+		default:
+		}
+		if nat := m.natmgr.NAT(); nat != nil {
+			s.PortMapping = PortMappingActive
+			for _, nm := range nat.Mappings() {
+				mapping := Mapping{Protocol: nm.Protocol(), InternalPort: nm.InternalPort()}
+				if addr, err := nm.ExternalAddr(); err != nil {
+					mapping.Err = err.Error()
+				} else if mapping.ExternalAddr, err = manet.FromNetAddr(addr); err != nil {
+					mapping.Err = err.Error()
+				}
+				s.Mappings = append(s.Mappings, mapping)
+			}
+			sort.Slice(s.Mappings, func(i, j int) bool {
+				return s.Mappings[i].String() < s.Mappings[j].String()
+			})
+		}
+	}
+
+	s.Addrs = sortAddrs(m.host.Addrs())
+	s.Relays = nil
+	seen := make(map[peer.ID]bool)
+	for _, a := range s.Addrs {
+		if relay, ok := relaydial.RelayOf(a); ok && relay != "" && !seen[relay] {
+			seen[relay] = true
+			s.Relays = append(s.Relays, relay)
+		}
+	}
+	sort.Slice(s.Relays, func(i, j int) bool { return s.Relays[i] < s.Relays[j] })
+	return s
+}
+
+// diff describes the changes from old to s.
+func diff(old, s Status) []string {
+	var changes []string
+	if old.Reachability != s.Reachability {
+		changes = append(changes, fmt.Sprintf("reachability: %s -> %s", old.Reachability, s.Reachability))
+	}
+	changes = append(changes, diffAddrs("observed address", old.ObservedAddrs, s.ObservedAddrs)...)
+	if old.PortMapping != s.PortMapping {
+		changes = append(changes, fmt.Sprintf("port mapping: %s -> %s", old.PortMapping, s.PortMapping))
+	}
+	changes = append(changes, diffStrings("port mapping", mappingStrings(old.Mappings), mappingStrings(s.Mappings))...)
+	changes = append(changes, diffStrings("relay", peerStrings(old.Relays), peerStrings(s.Relays))...)
+	changes = append(changes, diffAddrs("address", old.Addrs, s.Addrs)...)
+	return changes
+}
+
+func diffAddrs(what string, old, cur []ma.Multiaddr) []string {
+	return diffStrings(what, addrStrings(old), addrStrings(cur))
+}
+
+// diffStrings lists the strings added to and removed from old.
+func diffStrings(what string, old, cur []string) []string {
+	had := make(map[string]bool, len(old))
+	for _, s := range old {
+		had[s] = true
+	}
+	has := make(map[string]bool, len(cur))
+	for _, s := range cur {
+		has[s] = true
+	}
+	var changes []string
+	for _, s := range cur {
+		if !had[s] {
+			changes = append(changes, fmt.Sprintf("%s added: %s", what, s))
+		}
+	}
+	for _, s := range old {
+		if !has[s] {
+			changes = append(changes, fmt.Sprintf("%s removed: %s", what, s))
+		}
+	}
+	return changes
+}
+
+func addrStrings(addrs []ma.Multiaddr) []string {
+	s := make([]string, len(addrs))
+	for i, a := range addrs {
+		s[i] = a.String()
+	}
+	return s
+}
+
+func mappingStrings(mappings []Mapping) []string {
+	s := make([]string, len(mappings))
+	for i, m := range mappings {
+		s[i] = m.String()
+	}
+	return s
+}
+
+func peerStrings(peers []peer.ID) []string {
+	s := make([]string, len(peers))
+	for i, p := range peers {
+		s[i] = p.Pretty()
+	}
+	return s
+}
+
+// sortAddrs sorts a copy of addrs, so that statuses compare regardless of
+// the order addresses come in.
+func sortAddrs(addrs []ma.Multiaddr) []ma.Multiaddr {
+	sorted := append([]ma.Multiaddr(nil), addrs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Bytes(), sorted[j].Bytes()) < 0
+	})
+	return sorted
+}
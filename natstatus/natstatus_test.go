@@ -0,0 +1,59 @@
+package natstatus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"github.com/libp2p/go-libp2p-examples/harness"
+)
+
+func TestCloseTwice(t *testing.T) {
+	if err := NewMonitor().Close(); err != nil {
+		t.Fatalf("closing a monitor that never started: %s", err)
+	}
+
+	nw, err := harness.New(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Close()
+	m := NewMonitor()
+	if err := m.Start(nw.Host(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("closing twice: %s", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+	b := ma.StringCast("/ip4/5.6.7.8/tcp/4001")
+	old := Status{Reachability: network.ReachabilityUnknown, Since: time.Now(), Addrs: []ma.Multiaddr{a}}
+	cur := Status{Reachability: network.ReachabilityPrivate, Since: time.Now(), Addrs: []ma.Multiaddr{b}}
+
+	got := diff(old, cur)
+	want := []string{
+		"reachability: Unknown -> Private",
+		"address added: /ip4/5.6.7.8/tcp/4001",
+		"address removed: /ip4/1.2.3.4/tcp/4001",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+	if changes := diff(cur, cur); len(changes) > 0 {
+		t.Errorf("changes between equal statuses: %q", changes)
+	}
+}
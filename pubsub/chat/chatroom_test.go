@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+
+	"github.com/libp2p/go-libp2p-examples/harness"
+)
+
+// graftCounter counts the peers a host added to its mesh, which it
+// forwards messages to.
+type graftCounter int32
+
+func (c *graftCounter) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() == pb.TraceEvent_GRAFT {
+		atomic.AddInt32((*int32)(c), 1)
+	}
+}
+
+func (c *graftCounter) grafts() int {
+	return int(atomic.LoadInt32((*int32)(c)))
+}
+
+// TestChatRoom joins three hosts connected in a line to a room, and checks
+// that what host 0 publishes reaches host 2 through host 1, but not a host
+// in another room.
+func TestChatRoom(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	nw, err := harness.New(ctx, 4, harness.Mocknet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Close()
+
+	rooms := make([]*ChatRoom, 4)
+	meshes := make([]graftCounter, 4)
+	for i := range rooms {
+		ps, err := pubsub.NewGossipSub(ctx, nw.Host(i), pubsub.WithEventTracer(&meshes[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		room := "cats"
+		if i == 3 {
+			room = "dogs"
+		}
+		if rooms[i], err = JoinChatRoom(ctx, ps, nw.Host(i).ID(), defaultNick(nw.Host(i).ID()), room); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := nw.Connect(ctx, harness.Line); err != nil {
+		t.Fatal(err)
+	}
+	// Messages published before the hosts in the room meshed, on the
+	// first heartbeat, are lost.
+	if !harness.Eventually(10*time.Second, func() bool {
+		return meshes[0].grafts() >= 1 && meshes[1].grafts() >= 2 && meshes[2].grafts() >= 1
+	}) {
+		t.Fatal("the hosts didn't find each other in the room")
+	}
+
+	if err := rooms[0].Publish("meow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rooms[0].PublishOpinion("purr"); err != nil {
+		t.Fatal(err)
+	}
+	nick := defaultNick(nw.Host(0).ID())
+	for _, want := range []ChatMessage{
+		{Message: "meow", SenderID: nw.Host(0).ID().Pretty(), SenderNick: nick},
+		{Message: "purr", Opinion: true, SenderID: nw.Host(0).ID().Pretty(), SenderNick: nick},
+	} {
+		select {
+		case m := <-rooms[2].Messages:
+			if *m != want {
+				t.Errorf("got %+v, want %+v", *m, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q didn't reach host 2", want.Message)
+		}
+	}
+
+	// Hosts don't read their own messages back, and other rooms don't
+	// get them.
+	select {
+	case m := <-rooms[0].Messages:
+		t.Errorf("host 0 read back %q", m.Message)
+	case m := <-rooms[3].Messages:
+		t.Errorf("the other room got %q", m.Message)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
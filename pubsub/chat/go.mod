@@ -6,6 +6,10 @@ require (
 	github.com/gdamore/tcell/v2 v2.1.0
 	github.com/libp2p/go-libp2p v0.13.0
 	github.com/libp2p/go-libp2p-core v0.8.0
+	github.com/libp2p/go-libp2p-examples v0.0.0
 	github.com/libp2p/go-libp2p-pubsub v0.4.1
 	github.com/rivo/tview v0.0.0-20210125085121-dbc1f32bb1d0
 )
+
+// The tests use the harness of the examples around this one.
+replace github.com/libp2p/go-libp2p-examples => ../../
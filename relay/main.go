@@ -3,40 +3,67 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
 
-	circuit "github.com/libp2p/go-libp2p-circuit"
-	swarm "github.com/libp2p/go-libp2p-swarm"
-	ma "github.com/multiformats/go-multiaddr"
+	"github.com/libp2p/go-libp2p-examples/harness"
+	"github.com/libp2p/go-libp2p-examples/holepunch"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+	"github.com/libp2p/go-libp2p-examples/relaydial"
 )
 
-func main() {
-	// Create three libp2p hosts, enable relay client capabilities on all
-	// of them.
+// holeLifetime is how long a NAT keeps a hole open after we dialed out.
+const holeLifetime = 30 * time.Second
 
-	// Tell the host to monitor for relays.
-	h1, err := libp2p.New(context.Background(), libp2p.EnableRelay(circuit.OptDiscovery))
+func main() {
+	// Create three libp2p hosts. Relay client capabilities are on by
+	// default, on all of them.
+	listen := []string{"/ip4/127.0.0.1/tcp/0"}
+
+	// h1 and h3 are behind NATs: they only accept connections from
+	// addresses they dialed in the last holeLifetime, see harness.NATGate.
+	// The gate isn't part of the configuration, it is passed as an extra
+	// option.
+	// (circuit.OptDiscovery, which picked relays on its own, is a no-op
+	// nowadays: h1 is told which relay to use.)
+	h1, err := hostconfig.New(context.Background(),
+		&hostconfig.Config{ListenAddrs: listen},
+		libp2p.ConnectionGater(harness.NewNATGate(holeLifetime)),
+	)
 	if err != nil {
 		panic(err)
 	}
 
 	// Tell the host to relay connections for other peers (The ability to *use*
 	// a relay vs the ability to *be* a relay)
-	h2, err := libp2p.New(context.Background(), libp2p.EnableRelay(circuit.OptHop))
+	h2, err := hostconfig.New(context.Background(), &hostconfig.Config{
+		ListenAddrs: listen,
+		Relay:       hostconfig.Relay{Hop: true},
+	})
 	if err != nil {
 		panic(err)
 	}
 
-	// Zero out the listen addresses for the host, so it can only communicate
-	// via p2p-circuit for our example
-	h3, err := libp2p.New(context.Background(), libp2p.ListenAddrs(), libp2p.EnableRelay())
+	h3, err := hostconfig.New(context.Background(),
+		&hostconfig.Config{ListenAddrs: listen},
+		libp2p.ConnectionGater(harness.NewNATGate(holeLifetime)),
+	)
 	if err != nil {
 		panic(err)
 	}
 
+	// Both ends of a relayed connection run the hole punching service. h3
+	// starts upgrading the relayed connection from h1 as soon as it is
+	// idle, h1 learns how it went here.
+	upgraded := make(chan holepunch.Result, 1)
+	holepunch.New(h1, holepunch.Options{OnResult: func(r holepunch.Result) { upgraded <- r }})
+	holepunch.New(h3, holepunch.Options{})
+
 	h2info := peer.AddrInfo{
 		ID:    h2.ID(),
 		Addrs: h2.Addrs(),
@@ -52,10 +79,12 @@ func main() {
 
 	// Now, to test things, let's set up a protocol handler on h3
 	h3.SetStreamHandler("/cats", func(s network.Stream) {
-		fmt.Println("Meow! It worked!")
+		fmt.Println("Meow! It worked! Over", s.Conn().RemoteMultiaddr())
 		s.Close()
 	})
 
+	// Even knowing h3's address, h1 can't get through h3's NAT.
+	h1.Peerstore().AddAddrs(h3.ID(), h3.Addrs(), peerstore.TempAddrTTL)
 	_, err = h1.NewStream(context.Background(), h3.ID(), "/cats")
 	if err == nil {
 		fmt.Println("Didnt actually expect to get a stream here. What happened?")
@@ -64,32 +93,49 @@ func main() {
 	fmt.Println("Okay, no connection from h1 to h3: ", err)
 	fmt.Println("Just as we suspected")
 
-	// Creates a relay address
-	relayaddr, err := ma.NewMultiaddr("/p2p-circuit/ipfs/" + h3.ID().Pretty())
+	// The dialer tries the addresses h1 knows for h3 first, and then goes
+	// through each relay it is given: h2. Dialing stage by stage, it also
+	// clears the dial backoff our failed attempt left.
+	dialer := relaydial.NewDialer(h1, []peer.AddrInfo{h2info})
+	path, err := dialer.Connect(context.Background(), peer.AddrInfo{ID: h3.ID()})
 	if err != nil {
 		panic(err)
 	}
+	fmt.Println("Connected to h3:", path)
 
-	// Since we just tried and failed to dial, the dialer system will, by default
-	// prevent us from redialing again so quickly. Since we know what we're doing, we
-	// can use this ugly hack (it's on our TODO list to make it a little cleaner)
-	// to tell the dialer "no, its okay, let's try this again"
-	h1.Network().(*swarm.Swarm).Backoff().Clear(h3.ID())
-
-	h3relayInfo := peer.AddrInfo{
-		ID:    h3.ID(),
-		Addrs: []ma.Multiaddr{relayaddr},
+	// Woohoo! we're connected!
+	if err := cats(h1, h3.ID()); err != nil {
+		fmt.Println("huh, this should have worked: ", err)
+		return
 	}
-	if err := h1.Connect(context.Background(), h3relayInfo); err != nil {
-		panic(err)
+
+	// Meanwhile h3 and h1 told each other their addresses over the relay,
+	// hung up and dialed each other. The first dial is refused, but opens
+	// a hole in the NAT of its dialer, which the second dial goes through.
+	select {
+	case r := <-upgraded:
+		if r.Err != nil {
+			fmt.Println("No direct connection:", r.Err)
+			return
+		}
+		fmt.Println("Upgraded the connection to h3:", r.Path)
+	case <-time.After(time.Minute):
+		fmt.Println("The connection to h3 wasn't upgraded")
+		return
 	}
 
-	// Woohoo! we're connected!
-	s, err := h1.NewStream(context.Background(), h3.ID(), "/cats")
-	if err != nil {
+	// New streams go over the direct connection, h2 is out of the loop.
+	if err := cats(h1, h3.ID()); err != nil {
 		fmt.Println("huh, this should have worked: ", err)
-		return
 	}
+}
 
+// cats opens a /cats stream, and waits for the handler to close it.
+func cats(h1 host.Host, h3 peer.ID) error {
+	s, err := h1.NewStream(context.Background(), h3, "/cats")
+	if err != nil {
+		return err
+	}
 	s.Read(make([]byte, 1)) // block until the handler closes the stream
+	return s.Close()
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	circuit "github.com/libp2p/go-libp2p-circuit"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/libp2p/go-libp2p-examples/harness"
+	"github.com/libp2p/go-libp2p-examples/relaydial"
+)
+
+// TestRelay sends a message from host 0 to host 2, both behind NATs, through
+// the relay host 1.
+func TestRelay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	nw, err := harness.New(ctx, 3, harness.PerHostOptions(func(i int) []libp2p.Option {
+		if i == 1 {
+			return []libp2p.Option{libp2p.EnableRelay(circuit.OptHop)}
+		}
+		return []libp2p.Option{libp2p.ConnectionGater(harness.NewNATGate(holeLifetime)), libp2p.EnableRelay()}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Close()
+
+	if err := nw.Connect(ctx, harness.Star(1)); err != nil {
+		t.Fatal(err)
+	}
+	inbox := harness.NewInbox(nw.Host(2), "/cats")
+
+	// Host 0 knows the address of host 2, but can't get through its NAT.
+	if err := nw.Introduce(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	dctx, dcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer dcancel()
+	if err := harness.Send(dctx, nw.Host(0), nw.Host(2).ID(), "/cats", "meow"); err == nil {
+		t.Fatal("host 0 went through the NAT of host 2")
+	}
+
+	dialer := relaydial.NewDialer(nw.Host(0), []peer.AddrInfo{nw.AddrInfo(1)})
+	path, err := dialer.Connect(ctx, peer.AddrInfo{ID: nw.Host(2).ID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Relay != nw.Host(1).ID() {
+		t.Fatalf("connected %s, expected a relayed connection through host 1", path)
+	}
+
+	if err := harness.Send(ctx, nw.Host(0), nw.Host(2).ID(), "/cats", "meow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := inbox.Expect(5*time.Second, nw.Host(0).ID(), "meow"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	ggio "github.com/gogo/protobuf/io"
+	proto "github.com/gogo/protobuf/proto"
+	circuit "github.com/libp2p/go-libp2p-circuit"
+	pb "github.com/libp2p/go-libp2p-circuit/pb"
+	"github.com/libp2p/go-msgio"
+)
+
+// maxMessageSize is the largest handshake message we read, as in
+// go-libp2p-circuit.
+const maxMessageSize = 4096
+
+// copyBufferSize is how much a circuit forwards at a time.
+const copyBufferSize = 16 << 10
+
+// Limits bound what the relay does for its peers. Zero means no limit.
+type Limits struct {
+	// Circuits is the number of circuits open at a time.
+	Circuits int
+	// CircuitsPerPeer is the number of circuits a single peer may be an
+	// end of at a time.
+	CircuitsPerPeer int
+	// CircuitRate is the bandwidth of a circuit, in bytes per second and
+	// per direction.
+	CircuitRate int
+	// Rate is the bandwidth of all circuits together, in bytes per second
+	// and per direction.
+	Rate int
+	// Allow lists the peers that may use the relay. A circuit is accepted
+	// if either end is on the list. An empty list allows everybody.
+	Allow map[peer.ID]bool
+}
+
+// hopRelay is a circuit relay v1 hop: it relays streams between two peers
+// connected to it. It replaces the go-libp2p-circuit handler, which has no
+// per peer limits, allowlist or bandwidth caps.
+type hopRelay struct {
+	host   host.Host
+	limits Limits
+	up     *limiter // src to dst, for all circuits
+	down   *limiter // dst to src, for all circuits
+
+	mu       sync.Mutex
+	circuits map[*relayedCircuit]struct{}
+	perPeer  map[peer.ID]int
+	total    uint64 // circuits relayed since the start
+	refused  uint64 // circuits refused since the start
+}
+
+// relayedCircuit is a circuit the relay forwards.
+type relayedCircuit struct {
+	src, dst peer.ID
+	opened   time.Time
+	up, down int64 // bytes forwarded, updated atomically
+}
+
+func newHopRelay(h host.Host, limits Limits) *hopRelay {
+	r := &hopRelay{
+		host:     h,
+		limits:   limits,
+		up:       newLimiter(limits.Rate),
+		down:     newLimiter(limits.Rate),
+		circuits: make(map[*relayedCircuit]struct{}),
+		perPeer:  make(map[peer.ID]int),
+	}
+	h.SetStreamHandler(circuit.ProtoID, r.handleStream)
+	return r
+}
+
+func (r *hopRelay) handleStream(s network.Stream) {
+	// msgio reads the length byte by byte, it doesn't read past the
+	// message into the data of the circuit.
+	rd := msgio.NewVarintReaderSize(s, maxMessageSize)
+	var msg pb.CircuitRelay
+	if err := readMsg(rd, &msg); err != nil {
+		respond(s, pb.CircuitRelay_MALFORMED_MESSAGE)
+		return
+	}
+
+	switch msg.GetType() {
+	case pb.CircuitRelay_HOP:
+		r.handleHop(s, &msg)
+	case pb.CircuitRelay_CAN_HOP:
+		if r.allowed(s.Conn().RemotePeer()) {
+			respond(s, pb.CircuitRelay_SUCCESS)
+		} else {
+			respond(s, pb.CircuitRelay_HOP_CANT_SPEAK_RELAY)
+		}
+	case pb.CircuitRelay_STOP:
+		// Nobody is reachable through us but our peers.
+		respond(s, pb.CircuitRelay_STOP_RELAY_REFUSED)
+	default:
+		respond(s, pb.CircuitRelay_MALFORMED_MESSAGE)
+	}
+}
+
+func (r *hopRelay) handleHop(s network.Stream, msg *pb.CircuitRelay) {
+	src, err := peer.IDFromBytes(msg.GetSrcPeer().GetId())
+	if err != nil || src != s.Conn().RemotePeer() {
+		respond(s, pb.CircuitRelay_HOP_SRC_MULTIADDR_INVALID)
+		return
+	}
+	dst, err := peer.IDFromBytes(msg.GetDstPeer().GetId())
+	if err != nil {
+		respond(s, pb.CircuitRelay_HOP_DST_MULTIADDR_INVALID)
+		return
+	}
+	if dst == r.host.ID() {
+		respond(s, pb.CircuitRelay_HOP_CANT_RELAY_TO_SELF)
+		return
+	}
+
+	c, reason := r.open(src, dst)
+	if c == nil {
+		log.Printf("refused circuit %s -> %s: %s\n", src.Pretty(), dst.Pretty(), reason)
+		respond(s, pb.CircuitRelay_HOP_CANT_SPEAK_RELAY)
+		return
+	}
+
+	// We only relay to peers connected to us, that is what a relay is for:
+	// reaching peers that can't be dialed.
+	ctx, cancel := context.WithTimeout(context.Background(), circuit.HopConnectTimeout)
+	defer cancel()
+	bs, err := r.host.NewStream(network.WithNoDial(ctx, "relay hop"), dst, circuit.ProtoID)
+	if err != nil {
+		r.close(c)
+		if err == network.ErrNoConn {
+			respond(s, pb.CircuitRelay_HOP_NO_CONN_TO_DST)
+		} else {
+			respond(s, pb.CircuitRelay_HOP_CANT_OPEN_DST_STREAM)
+		}
+		return
+	}
+
+	// The stop handshake: the destination accepts the circuit or not.
+	bs.SetDeadline(time.Now().Add(circuit.StopHandshakeTimeout))
+	msg.Type = pb.CircuitRelay_STOP.Enum()
+	err = ggio.NewDelimitedWriter(bs).WriteMsg(msg)
+	if err == nil {
+		msg.Reset()
+		err = readMsg(msgio.NewVarintReaderSize(bs, maxMessageSize), msg)
+	}
+	if err != nil || msg.GetType() != pb.CircuitRelay_STATUS {
+		bs.Reset()
+		r.close(c)
+		respond(s, pb.CircuitRelay_HOP_CANT_OPEN_DST_STREAM)
+		return
+	}
+	if msg.GetCode() != pb.CircuitRelay_SUCCESS {
+		bs.Reset()
+		r.close(c)
+		respond(s, msg.GetCode())
+		return
+	}
+	bs.SetDeadline(time.Time{})
+
+	if err := writeStatus(s, pb.CircuitRelay_SUCCESS); err != nil {
+		bs.Reset()
+		s.Reset()
+		r.close(c)
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go r.forward(&wg, bs, s, &c.up, r.up)
+	go r.forward(&wg, s, bs, &c.down, r.down)
+	go func() {
+		wg.Wait()
+		s.Close()
+		bs.Close()
+		r.close(c)
+	}()
+}
+
+// forward copies from src to dst at the allowed rate, counting the bytes.
+// Closing src closes dst for writing, so the end of the circuit sees an
+// EOF rather than an error.
+func (r *hopRelay) forward(wg *sync.WaitGroup, dst, src network.Stream, count *int64, shared *limiter) {
+	defer wg.Done()
+	circuitRate := newLimiter(r.limits.CircuitRate)
+	size := copyBufferSize
+	if rate := r.limits.CircuitRate; rate > 0 && rate < 10*size {
+		// Don't read more than a tenth of a second's worth, or the
+		// circuit would stall between reads.
+		size = rate/10 + 1
+	}
+	buf := make([]byte, size)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			circuitRate.wait(n)
+			shared.wait(n)
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				src.Reset()
+				dst.Reset()
+				return
+			}
+			atomic.AddInt64(count, int64(n))
+		}
+		if err == io.EOF {
+			dst.CloseWrite()
+			return
+		}
+		if err != nil {
+			src.Reset()
+			dst.Reset()
+			return
+		}
+	}
+}
+
+// allowed tells whether p is on the allowlist.
+func (r *hopRelay) allowed(p peer.ID) bool {
+	return len(r.limits.Allow) == 0 || r.limits.Allow[p]
+}
+
+// open checks the limits, and records a new circuit if they allow it.
+// Otherwise it returns why not.
+func (r *hopRelay) open(src, dst peer.ID) (*relayedCircuit, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reason := ""
+	switch {
+	case !r.allowed(src) && !r.allowed(dst):
+		reason = "neither peer is allowed"
+	case r.limits.Circuits > 0 && len(r.circuits) >= r.limits.Circuits:
+		reason = "too many circuits"
+	case r.limits.CircuitsPerPeer > 0 && r.perPeer[src] >= r.limits.CircuitsPerPeer:
+		reason = "too many circuits from " + src.Pretty()
+	case r.limits.CircuitsPerPeer > 0 && r.perPeer[dst] >= r.limits.CircuitsPerPeer:
+		reason = "too many circuits to " + dst.Pretty()
+	}
+	if reason != "" {
+		r.refused++
+		return nil, reason
+	}
+
+	c := &relayedCircuit{src: src, dst: dst, opened: time.Now()}
+	r.circuits[c] = struct{}{}
+	r.perPeer[src]++
+	r.perPeer[dst]++
+	r.total++
+	return c, ""
+}
+
+// close forgets a circuit.
+func (r *hopRelay) close(c *relayedCircuit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.circuits[c]; !ok {
+		return
+	}
+	delete(r.circuits, c)
+	for _, p := range []peer.ID{c.src, c.dst} {
+		if r.perPeer[p]--; r.perPeer[p] == 0 {
+			delete(r.perPeer, p)
+		}
+	}
+}
+
+// Stats is a snapshot of the relay's activity.
+type Stats struct {
+	Active  []CircuitStats `json:"active"`
+	Total   uint64         `json:"total"`
+	Refused uint64         `json:"refused"`
+}
+
+// CircuitStats describes an open circuit.
+type CircuitStats struct {
+	Src    string    `json:"src"`
+	Dst    string    `json:"dst"`
+	Opened time.Time `json:"opened"`
+	Up     int64     `json:"up"`   // bytes from src to dst
+	Down   int64     `json:"down"` // bytes from dst to src
+}
+
+// Stats returns the open circuits, oldest first, and counters.
+func (r *hopRelay) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := Stats{Active: []CircuitStats{}, Total: r.total, Refused: r.refused}
+	for c := range r.circuits {
+		st.Active = append(st.Active, CircuitStats{
+			Src:    c.src.Pretty(),
+			Dst:    c.dst.Pretty(),
+			Opened: c.opened,
+			Up:     atomic.LoadInt64(&c.up),
+			Down:   atomic.LoadInt64(&c.down),
+		})
+	}
+	sort.Slice(st.Active, func(i, j int) bool { return st.Active[i].Opened.Before(st.Active[j].Opened) })
+	return st
+}
+
+// readMsg reads a length-prefixed protobuf message.
+func readMsg(r msgio.Reader, msg proto.Message) error {
+	data, err := r.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer r.ReleaseMsg(data)
+	return proto.Unmarshal(data, msg)
+}
+
+// writeStatus sends a status message.
+func writeStatus(s network.Stream, code pb.CircuitRelay_Status) error {
+	return ggio.NewDelimitedWriter(s).WriteMsg(&pb.CircuitRelay{
+		Type: pb.CircuitRelay_STATUS.Enum(),
+		Code: code.Enum(),
+	})
+}
+
+// respond sends a status message and closes the stream.
+func respond(s network.Stream, code pb.CircuitRelay_Status) {
+	if err := writeStatus(s, code); err != nil {
+		s.Reset()
+		return
+	}
+	s.Close()
+}
+
+// limiter spreads bytes over time at a fixed rate, allowing a burst of a
+// tenth of a second. A nil limiter doesn't limit anything.
+type limiter struct {
+	mu   sync.Mutex
+	rate float64 // bytes per second
+	next time.Time
+}
+
+const burst = 100 * time.Millisecond
+
+func newLimiter(rate int) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{rate: float64(rate)}
+}
+
+// wait blocks until n more bytes fit in the rate.
+func (l *limiter) wait(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now.Add(-burst)) {
+		l.next = now.Add(-burst)
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	d := l.next.Sub(now)
+	l.mu.Unlock()
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
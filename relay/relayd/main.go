@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+)
+
+func main() {
+	listen := flag.String("listen", "/ip4/0.0.0.0/tcp/4100,/ip6/::/tcp/4100", "comma separated multiaddrs to listen on")
+	keyFile := flag.String("id", defaultKeyFile(), "load the relay key from this file, creating it if needed")
+	var limits Limits
+	flag.IntVar(&limits.Circuits, "max-circuits", 128, "circuits open at a time, 0 for no limit")
+	flag.IntVar(&limits.CircuitsPerPeer, "max-circuits-per-peer", 8, "circuits a single peer may be an end of at a time, 0 for no limit")
+	flag.IntVar(&limits.CircuitRate, "circuit-rate", 0, "bandwidth of a circuit in bytes per second and per direction, 0 for no limit")
+	flag.IntVar(&limits.Rate, "rate", 0, "bandwidth of all circuits together in bytes per second and per direction, 0 for no limit")
+	allow := flag.String("allow", "", "comma separated peer IDs allowed to use the relay, everybody if neither this nor -allow-file is given")
+	allowFile := flag.String("allow-file", "", "read allowed peer IDs from this file, one per line")
+	statsAddr := flag.String("stats", "", "serve the active circuits as JSON over HTTP on this address, e.g. 127.0.0.1:4101")
+	statsEvery := flag.Duration("stats-every", time.Minute, "how often to log a summary of the circuits, 0 to disable")
+	configFile := flag.String("config", "", "build the host from this configuration file, YAML or JSON, instead of -listen; the key is still that of -id unless the file names one")
+	flag.Parse()
+
+	var err error
+	if limits.Allow, err = readAllowlist(*allow, *allowFile); err != nil {
+		log.Fatalln(err)
+	}
+
+	cfg := &hostconfig.Config{ListenAddrs: strings.Split(*listen, ",")}
+	if *configFile != "" {
+		if cfg, err = hostconfig.Load(*configFile); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	// Clients reach peers through the relay by its peer ID, it must not
+	// change when the relay restarts.
+	if cfg.Identity.File == "" && cfg.Identity.Key == nil {
+		cfg.Identity.File = *keyFile
+	}
+	// The relay protocol is ours to handle, go-libp2p-circuit would
+	// register its own handler.
+	cfg.Relay.Disabled = true
+
+	h, err := hostconfig.New(context.Background(), cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	r := newHopRelay(h, limits)
+
+	fmt.Println("Relay running. Peers reachable through it listen on, and are dialed at:")
+	for _, a := range h.Addrs() {
+		fmt.Printf(" - %s/p2p/%s/p2p-circuit\n", a, h.ID().Pretty())
+	}
+	if len(limits.Allow) > 0 {
+		fmt.Printf("%d peers allowed\n", len(limits.Allow))
+	}
+
+	if *statsAddr != "" {
+		http.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(r.Stats())
+		})
+		go func() {
+			log.Fatalln(http.ListenAndServe(*statsAddr, nil))
+		}()
+	}
+
+	if *statsEvery <= 0 {
+		select {} // hang forever
+	}
+	for range time.Tick(*statsEvery) {
+		logStats(r.Stats())
+	}
+}
+
+// defaultKeyFile is where the relay key is kept unless -id says otherwise.
+func defaultKeyFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "relayd.key"
+	}
+	return filepath.Join(dir, "libp2p-relayd", "identity")
+}
+
+// readAllowlist parses the peer IDs of -allow and -allow-file. Empty lines
+// and lines starting with # are skipped in the file.
+func readAllowlist(list, path string) (map[peer.ID]bool, error) {
+	ids := strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' })
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				ids = append(ids, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	allow := make(map[peer.ID]bool, len(ids))
+	for _, s := range ids {
+		id, err := peer.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("allowlist: %q: %w", s, err)
+		}
+		allow[id] = true
+	}
+	return allow, nil
+}
+
+// logStats logs a summary of the circuits, and each open one.
+func logStats(st Stats) {
+	log.Printf("%d circuits open, %d relayed and %d refused since the start\n", len(st.Active), st.Total, st.Refused)
+	for _, c := range st.Active {
+		log.Printf("  %s -> %s  %s, %d bytes up, %d down\n", c.Src, c.Dst, time.Since(c.Opened).Round(time.Second), c.Up, c.Down)
+	}
+}
@@ -0,0 +1,311 @@
+// Package relaydial connects to peers directly when it can, and through
+// circuit relays when it can't.
+//
+// A peer behind a NAT has no address others can dial, but it can keep a
+// connection to a relay, and be reached at <relay>/p2p-circuit/p2p/<peer>.
+// The swarm dials all the addresses of a peer at once, so a Dialer dials
+// them in stages instead: the direct addresses first, then through each
+// relay in turn, and reports which path worked.
+package relaydial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+
+	swarm "github.com/libp2p/go-libp2p-swarm"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// DefaultTimeout bounds each stage of a dial, unless the Dialer says
+// otherwise.
+const DefaultTimeout = 15 * time.Second
+
+// Path tells how a peer was reached.
+type Path struct {
+	// Relay is the relay the connection goes through, empty for a direct
+	// connection.
+	Relay peer.ID
+	// Addr is the remote address of the connection.
+	Addr ma.Multiaddr
+}
+
+// Direct tells whether the connection goes through no relay.
+func (p Path) Direct() bool { return p.Relay == "" }
+
+func (p Path) String() string {
+	if p.Direct() {
+		return "direct, " + p.Addr.String()
+	}
+	return "relayed through " + p.Relay.Pretty()
+}
+
+// Dialer connects to peers, through relays if need be. The host must have
+// the relay transport enabled, which libp2p.New does unless told not to.
+type Dialer struct {
+	host   host.Host
+	relays []peer.AddrInfo
+
+	// Timeout bounds each stage of a dial, the direct one and each relay.
+	Timeout time.Duration
+}
+
+// NewDialer returns a Dialer trying the relays in the given order, once the
+// direct addresses failed. The relays' addresses are added to the peerstore.
+func NewDialer(h host.Host, relays []peer.AddrInfo) *Dialer {
+	for _, r := range relays {
+		h.Peerstore().AddAddrs(r.ID, r.Addrs, peerstore.PermanentAddrTTL)
+	}
+	return &Dialer{host: h, relays: relays, Timeout: DefaultTimeout}
+}
+
+// Relays returns the relays of the Dialer.
+func (d *Dialer) Relays() []peer.AddrInfo { return d.relays }
+
+// stage is a set of addresses dialed together.
+type stage struct {
+	relay peer.ID // empty for the direct addresses
+	addrs []ma.Multiaddr
+}
+
+// Connect connects to pi, unless we are connected already, and returns the
+// path of the connection. The addresses of pi are added to those the
+// peerstore knows. Circuit addresses among them are tried before the relays
+// of the Dialer.
+//
+// While it dials, Connect keeps only the addresses of the current stage in
+// the peerstore, other dials to the peer at the same time would only see
+// those. Afterwards the peerstore holds the direct addresses again, and the
+// circuit address that worked, if any.
+func (d *Dialer) Connect(ctx context.Context, pi peer.AddrInfo) (Path, error) {
+	if path, ok := d.connected(pi.ID); ok {
+		return path, nil
+	}
+
+	ps := d.host.Peerstore()
+	known := append(ps.Addrs(pi.ID), pi.Addrs...)
+	stages := d.stages(known)
+	if len(stages) == 0 {
+		return Path{}, fmt.Errorf("no address nor relay to reach %s", pi.ID.Pretty())
+	}
+
+	var direct []ma.Multiaddr
+	if stages[0].relay == "" {
+		direct = stages[0].addrs
+	}
+	var worked ma.Multiaddr
+	defer func() {
+		ps.ClearAddrs(pi.ID)
+		ps.AddAddrs(pi.ID, direct, peerstore.AddressTTL)
+		if worked != nil {
+			ps.AddAddr(pi.ID, worked, peerstore.AddressTTL)
+		}
+	}()
+
+	var failures []string
+	for _, st := range stages {
+		ps.ClearAddrs(pi.ID)
+		ps.AddAddrs(pi.ID, st.addrs, peerstore.TempAddrTTL)
+		// A failed dial puts the addresses in backoff, and the swarm refuses
+		// to dial them again for a while. We dial each stage on purpose,
+		// once, and the peerstore only holds its addresses.
+		clearBackoff(d.host, pi.ID)
+
+		err := d.dial(ctx, pi.ID)
+		if err == nil {
+			path, _ := d.connected(pi.ID)
+			if !path.Direct() {
+				worked = path.Addr
+			}
+			return path, nil
+		}
+		if ctx.Err() != nil {
+			return Path{}, ctx.Err()
+		}
+		if st.relay == "" {
+			failures = append(failures, "direct: "+err.Error())
+		} else {
+			failures = append(failures, "through "+st.relay.Pretty()+": "+err.Error())
+		}
+	}
+	return Path{}, &Error{Peer: pi.ID, Failures: failures}
+}
+
+// dial runs a single stage.
+func (d *Dialer) dial(ctx context.Context, p peer.ID) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+	_, err := d.host.Network().DialPeer(ctx, p)
+	return err
+}
+
+// stages orders the known addresses of a peer into the stages of a dial:
+// the direct addresses, the circuit addresses grouped by relay, and a
+// circuit through each relay of the Dialer not used yet.
+func (d *Dialer) stages(known []ma.Multiaddr) []stage {
+	var direct stage
+	var relayed []stage
+	index := make(map[peer.ID]int)
+	seen := make(map[string]bool)
+	for _, a := range known {
+		if seen[string(a.Bytes())] {
+			continue
+		}
+		seen[string(a.Bytes())] = true
+
+		relay, ok := RelayOf(a)
+		switch {
+		case !ok:
+			direct.addrs = append(direct.addrs, a)
+		case relay == "":
+			// A circuit through no relay in particular can't be dialed.
+		default:
+			i, ok := index[relay]
+			if !ok {
+				i = len(relayed)
+				index[relay] = i
+				relayed = append(relayed, stage{relay: relay})
+			}
+			relayed[i].addrs = append(relayed[i].addrs, a)
+		}
+	}
+	for _, r := range d.relays {
+		if _, ok := index[r.ID]; ok || r.ID == d.host.ID() {
+			continue
+		}
+		index[r.ID] = len(relayed)
+		relayed = append(relayed, stage{relay: r.ID, addrs: []ma.Multiaddr{CircuitAddr(r.ID)}})
+	}
+
+	if len(direct.addrs) == 0 {
+		return relayed
+	}
+	return append([]stage{direct}, relayed...)
+}
+
+// connected returns the path of our connection to p.
+func (d *Dialer) connected(p peer.ID) (Path, bool) {
+	return PathTo(d.host, p)
+}
+
+// PathTo returns the path of the connection of h to p, the direct one if
+// there are several. It returns false if h isn't connected to p.
+func PathTo(h host.Host, p peer.ID) (Path, bool) {
+	var path Path
+	found := false
+	for _, c := range h.Network().ConnsToPeer(p) {
+		relay, ok := RelayOf(c.RemoteMultiaddr())
+		if !ok {
+			return Path{Addr: c.RemoteMultiaddr()}, true
+		}
+		if !found {
+			path, found = Path{Relay: relay, Addr: c.RemoteMultiaddr()}, true
+		}
+	}
+	return path, found
+}
+
+// Error is returned when none of the stages of a dial worked.
+type Error struct {
+	Peer     peer.ID
+	Failures []string // why each stage failed, in order
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("can't reach %s: %s", e.Peer.Pretty(), strings.Join(e.Failures, "; "))
+}
+
+// clearBackoff forgets the failed dials to p, if the host's network is a
+// swarm.
+func clearBackoff(h host.Host, p peer.ID) {
+	if s, ok := h.Network().(interface{ Backoff() *swarm.DialBackoff }); ok {
+		s.Backoff().Clear(p)
+	}
+}
+
+// CircuitAddr returns the address of the circuit through relay, to be
+// dialed with the peer ID of the destination. The relay's own addresses
+// are taken from the peerstore.
+func CircuitAddr(relay peer.ID) ma.Multiaddr {
+	a, err := ma.NewMultiaddr("/p2p/" + relay.Pretty() + "/p2p-circuit")
+	if err != nil {
+		panic(err) // can't happen with a valid peer ID
+	}
+	return a
+}
+
+// RelayOf tells whether a is a circuit address, and through which relay.
+// The relay is empty if the address doesn't name one.
+func RelayOf(a ma.Multiaddr) (peer.ID, bool) {
+	relayAddr, rest := ma.SplitFunc(a, func(c ma.Component) bool {
+		return c.Protocol().Code == ma.P_CIRCUIT
+	})
+	if rest == nil {
+		return "", false
+	}
+	if relayAddr == nil {
+		return "", true
+	}
+	s, err := relayAddr.ValueForProtocol(ma.P_P2P)
+	if err != nil {
+		return "", true
+	}
+	relay, err := peer.Decode(s)
+	if err != nil {
+		return "", true
+	}
+	return relay, true
+}
+
+// ConnectRelays connects h to the relays, so that it can be reached through
+// them, and returns the circuit addresses others dial it at. It fails if
+// none of the relays could be reached.
+func ConnectRelays(ctx context.Context, h host.Host, relays []peer.AddrInfo) ([]ma.Multiaddr, error) {
+	var addrs []ma.Multiaddr
+	var failures []string
+	for _, r := range relays {
+		if err := h.Connect(ctx, r); err != nil {
+			failures = append(failures, r.ID.Pretty()+": "+err.Error())
+			continue
+		}
+		// Relays drop peers like any other, unless asked not to.
+		h.ConnManager().Protect(r.ID, "relaydial")
+		self, err := ma.NewMultiaddr("/p2p/" + h.ID().Pretty())
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, CircuitAddr(r.ID).Encapsulate(self))
+	}
+	if len(addrs) == 0 && len(relays) > 0 {
+		return nil, errors.New("no relay reachable: " + strings.Join(failures, "; "))
+	}
+	return addrs, nil
+}
+
+// ParseRelays parses relay multiaddrs ending with /p2p/<peer ID>,
+// separated by commas.
+func ParseRelays(list string) ([]peer.AddrInfo, error) {
+	var relays []peer.AddrInfo
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("relay %q: %w", s, err)
+		}
+		pi, err := peer.AddrInfoFromP2pAddr(a)
+		if err != nil {
+			return nil, fmt.Errorf("relay %q: %w", s, err)
+		}
+		relays = append(relays, *pi)
+	}
+	return relays, nil
+}
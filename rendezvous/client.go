@@ -0,0 +1,249 @@
+package rendezvous
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/discovery"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/record"
+
+	ggio "github.com/gogo/protobuf/io"
+	pb "github.com/libp2p/go-libp2p-examples/rendezvous/pb"
+)
+
+// pageSize is the number of registrations FindPeers asks for at a time.
+const pageSize = 100
+
+// Client registers with, and discovers peers through, a rendezvous server.
+// It implements discovery.Discovery, so it can replace a RoutingDiscovery.
+type Client struct {
+	host   host.Host
+	server peer.ID
+}
+
+var _ discovery.Discovery = (*Client)(nil)
+
+// NewClient returns a client of the rendezvous server with the given peer
+// ID. The host must know the server's addresses, or be connected to it.
+func NewClient(h host.Host, server peer.ID) *Client {
+	return &Client{host: h, server: server}
+}
+
+// Register lists us under ns for ttl, or for the server's default TTL if ttl
+// is zero. It returns how long the server keeps the registration.
+func (c *Client) Register(ctx context.Context, ns string, ttl time.Duration) (time.Duration, error) {
+	rec, err := c.signedRecord()
+	if err != nil {
+		return 0, err
+	}
+
+	s, err := c.host.NewStream(ctx, c.server, Protocol)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	resp, err := roundTrip(s, &pb.Message{
+		Type: pb.Message_REGISTER,
+		Register: &pb.Message_Register{
+			Ns:               ns,
+			SignedPeerRecord: rec,
+			Ttl:              uint64(ttl / time.Second),
+		},
+	}, pb.Message_REGISTER_RESPONSE)
+	if err != nil {
+		return 0, err
+	}
+	r := resp.RegisterResponse
+	if r == nil {
+		return 0, errors.New("rendezvous: empty register response")
+	}
+	if r.Status != pb.Message_OK {
+		return 0, fmt.Errorf("rendezvous: registration refused: %s: %s", r.Status, r.StatusText)
+	}
+	return time.Duration(r.Ttl) * time.Second, nil
+}
+
+// Unregister removes us from ns.
+func (c *Client) Unregister(ctx context.Context, ns string) error {
+	s, err := c.host.NewStream(ctx, c.server, Protocol)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	err = ggio.NewDelimitedWriter(s).WriteMsg(&pb.Message{
+		Type:       pb.Message_UNREGISTER,
+		Unregister: &pb.Message_Unregister{Ns: ns, Id: []byte(c.host.ID())},
+	})
+	if err == nil {
+		err = s.CloseWrite()
+	}
+	if err != nil {
+		s.Reset()
+		return err
+	}
+
+	// There is no response. Wait for the server to close its side, so we
+	// know it has read the request before the caller shuts the host down.
+	_, err = io.Copy(ioutil.Discard, s)
+	return err
+}
+
+// Discover returns up to limit peers registered under ns, or under any
+// namespace if ns is empty, and a cookie. Passing the cookie to the next call
+// only returns the peers that registered since. A limit of zero lets the
+// server pick.
+func (c *Client) Discover(ctx context.Context, ns string, limit int, cookie []byte) ([]peer.AddrInfo, []byte, error) {
+	s, err := c.host.NewStream(ctx, c.server, Protocol)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer s.Close()
+	peers, cookie, _, err := c.discover(s, ns, limit, cookie)
+	return peers, cookie, err
+}
+
+// discover sends a discover request on s. Besides the peers and the cookie,
+// it returns the number of registrations in the response, which includes
+// those dropped because of an invalid record.
+func (c *Client) discover(s network.Stream, ns string, limit int, cookie []byte) ([]peer.AddrInfo, []byte, int, error) {
+	resp, err := roundTrip(s, &pb.Message{
+		Type: pb.Message_DISCOVER,
+		Discover: &pb.Message_Discover{
+			Ns:     ns,
+			Limit:  uint64(limit),
+			Cookie: cookie,
+		},
+	}, pb.Message_DISCOVER_RESPONSE)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	r := resp.DiscoverResponse
+	if r == nil {
+		return nil, nil, 0, errors.New("rendezvous: empty discover response")
+	}
+	if r.Status != pb.Message_OK {
+		return nil, nil, 0, fmt.Errorf("rendezvous: discover failed: %s: %s", r.Status, r.StatusText)
+	}
+
+	peers := make([]peer.AddrInfo, 0, len(r.Registrations))
+	for _, reg := range r.Registrations {
+		// Don't trust the server with the addresses, only with the
+		// records the peers signed themselves.
+		_, rec, err := record.ConsumeEnvelope(reg.SignedPeerRecord, peer.PeerRecordEnvelopeDomain)
+		if err != nil {
+			continue
+		}
+		if pr, ok := rec.(*peer.PeerRecord); ok {
+			peers = append(peers, peer.AddrInfo{ID: pr.PeerID, Addrs: pr.Addrs})
+		}
+	}
+	return peers, r.Cookie, len(r.Registrations), nil
+}
+
+// Advertise registers us under ns, for the TTL option if given. It is
+// Register, for the discovery.Advertiser interface.
+func (c *Client) Advertise(ctx context.Context, ns string, opts ...discovery.Option) (time.Duration, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return 0, err
+	}
+	return c.Register(ctx, ns, options.Ttl)
+}
+
+// FindPeers returns all peers registered under ns, or at most as many as the
+// Limit option. The server is asked page by page, over a single stream, until
+// it has nothing more: it may return fewer registrations per page than asked
+// for, so a short page doesn't mean it was the last one.
+func (c *Client) FindPeers(ctx context.Context, ns string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+
+	s, err := c.host.NewStream(ctx, c.server, Protocol)
+	if err != nil {
+		return nil, err
+	}
+	page, cookie, n, err := c.discover(s, ns, pageSize, nil)
+	if err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	ch := make(chan peer.AddrInfo, pageSize)
+	go func() {
+		defer close(ch)
+		defer s.Close()
+
+		sent := 0
+		for {
+			for _, pi := range page {
+				if options.Limit > 0 && sent == options.Limit {
+					return
+				}
+				select {
+				case ch <- pi:
+					sent++
+				case <-ctx.Done():
+					return
+				}
+			}
+			if n == 0 {
+				return
+			}
+			prev := cookie
+			var err error
+			if page, cookie, n, err = c.discover(s, ns, pageSize, cookie); err != nil {
+				s.Reset()
+				return
+			}
+			if bytes.Equal(cookie, prev) {
+				// The server didn't move on, asking again would loop.
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// signedRecord returns our addresses, signed with our key.
+func (c *Client) signedRecord() ([]byte, error) {
+	priv := c.host.Peerstore().PrivKey(c.host.ID())
+	if priv == nil {
+		return nil, errors.New("rendezvous: host has no private key")
+	}
+	rec := peer.PeerRecordFromAddrInfo(peer.AddrInfo{ID: c.host.ID(), Addrs: c.host.Addrs()})
+	env, err := record.Seal(rec, priv)
+	if err != nil {
+		return nil, err
+	}
+	return env.Marshal()
+}
+
+// roundTrip sends a request and reads the response of the expected type.
+func roundTrip(s network.Stream, req *pb.Message, expect pb.Message_MessageType) (*pb.Message, error) {
+	if err := ggio.NewDelimitedWriter(s).WriteMsg(req); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	resp := &pb.Message{}
+	if err := ggio.NewDelimitedReader(s, maxMessageSize).ReadMsg(resp); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if resp.Type != expect {
+		s.Reset()
+		return nil, fmt.Errorf("rendezvous: got a %s message, expected %s", resp.Type, expect)
+	}
+	return resp, nil
+}
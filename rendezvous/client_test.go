@@ -0,0 +1,52 @@
+package rendezvous
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/libp2p/go-libp2p-examples/harness"
+)
+
+// TestFindPeersShortPages checks that FindPeers gets every registration from
+// a server that returns fewer per page than it is asked for.
+func TestFindPeersShortPages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	const n = 7
+	nw, err := harness.New(ctx, n+1, harness.Mocknet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { nw.Close() })
+	if err := nw.Connect(ctx, harness.Star(0)); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(nw.Host(0), Options{MaxDiscoverLimit: 3})
+	t.Cleanup(func() { s.Close() })
+
+	want := make(map[peer.ID]bool)
+	for i := 1; i <= n; i++ {
+		if _, err := NewClient(nw.Host(i), nw.Host(0).ID()).Register(ctx, "test", 0); err != nil {
+			t.Fatalf("host %d: %s", i, err)
+		}
+		want[nw.Host(i).ID()] = true
+	}
+
+	ch, err := NewClient(nw.Host(1), nw.Host(0).ID()).FindPeers(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pi := range ch {
+		if !want[pi.ID] {
+			t.Errorf("found %s, unknown or twice", pi.ID)
+		}
+		delete(want, pi.ID)
+	}
+	if len(want) > 0 {
+		t.Errorf("%d of %d registered peers not found", len(want), n)
+	}
+}
@@ -0,0 +1,505 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: rendezvous.proto
+
+package rendezvous_pb
+
+import (
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+type Message_MessageType int32
+
+const (
+	Message_REGISTER          Message_MessageType = 0
+	Message_REGISTER_RESPONSE Message_MessageType = 1
+	Message_UNREGISTER        Message_MessageType = 2
+	Message_DISCOVER          Message_MessageType = 3
+	Message_DISCOVER_RESPONSE Message_MessageType = 4
+)
+
+var Message_MessageType_name = map[int32]string{
+	0: "REGISTER",
+	1: "REGISTER_RESPONSE",
+	2: "UNREGISTER",
+	3: "DISCOVER",
+	4: "DISCOVER_RESPONSE",
+}
+
+var Message_MessageType_value = map[string]int32{
+	"REGISTER":          0,
+	"REGISTER_RESPONSE": 1,
+	"UNREGISTER":        2,
+	"DISCOVER":          3,
+	"DISCOVER_RESPONSE": 4,
+}
+
+func (x Message_MessageType) String() string {
+	return proto.EnumName(Message_MessageType_name, int32(x))
+}
+
+func (Message_MessageType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_ef0a1d5737df1c36, []int{0, 0}
+}
+
+type Message_ResponseStatus int32
+
+const (
+	Message_OK                           Message_ResponseStatus = 0
+	Message_E_INVALID_NAMESPACE          Message_ResponseStatus = 100
+	Message_E_INVALID_SIGNED_PEER_RECORD Message_ResponseStatus = 101
+	Message_E_INVALID_TTL                Message_ResponseStatus = 102
+	Message_E_INVALID_COOKIE             Message_ResponseStatus = 103
+	Message_E_NOT_AUTHORIZED             Message_ResponseStatus = 200
+	Message_E_INTERNAL_ERROR             Message_ResponseStatus = 300
+	Message_E_UNAVAILABLE                Message_ResponseStatus = 400
+)
+
+var Message_ResponseStatus_name = map[int32]string{
+	0:   "OK",
+	100: "E_INVALID_NAMESPACE",
+	101: "E_INVALID_SIGNED_PEER_RECORD",
+	102: "E_INVALID_TTL",
+	103: "E_INVALID_COOKIE",
+	200: "E_NOT_AUTHORIZED",
+	300: "E_INTERNAL_ERROR",
+	400: "E_UNAVAILABLE",
+}
+
+var Message_ResponseStatus_value = map[string]int32{
+	"OK":                           0,
+	"E_INVALID_NAMESPACE":          100,
+	"E_INVALID_SIGNED_PEER_RECORD": 101,
+	"E_INVALID_TTL":                102,
+	"E_INVALID_COOKIE":             103,
+	"E_NOT_AUTHORIZED":             200,
+	"E_INTERNAL_ERROR":             300,
+	"E_UNAVAILABLE":                400,
+}
+
+func (x Message_ResponseStatus) String() string {
+	return proto.EnumName(Message_ResponseStatus_name, int32(x))
+}
+
+func (Message_ResponseStatus) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_ef0a1d5737df1c36, []int{0, 1}
+}
+
+// every message of the /rendezvous/1.0.0 protocol, as defined in the libp2p
+// rendezvous spec. messages are length-prefixed with an unsigned varint, a
+// stream may carry several requests, each answered before the next one
+type Message struct {
+	Type                 Message_MessageType       `protobuf:"varint,1,opt,name=type,proto3,enum=rendezvous.pb.Message_MessageType" json:"type,omitempty"`
+	Register             *Message_Register         `protobuf:"bytes,2,opt,name=register,proto3" json:"register,omitempty"`
+	RegisterResponse     *Message_RegisterResponse `protobuf:"bytes,3,opt,name=registerResponse,proto3" json:"registerResponse,omitempty"`
+	Unregister           *Message_Unregister       `protobuf:"bytes,4,opt,name=unregister,proto3" json:"unregister,omitempty"`
+	Discover             *Message_Discover         `protobuf:"bytes,5,opt,name=discover,proto3" json:"discover,omitempty"`
+	DiscoverResponse     *Message_DiscoverResponse `protobuf:"bytes,6,opt,name=discoverResponse,proto3" json:"discoverResponse,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
+	XXX_unrecognized     []byte                    `json:"-"`
+	XXX_sizecache        int32                     `json:"-"`
+}
+
+func (m *Message) Reset()         { *m = Message{} }
+func (m *Message) String() string { return proto.CompactTextString(m) }
+func (*Message) ProtoMessage()    {}
+func (*Message) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ef0a1d5737df1c36, []int{0}
+}
+func (m *Message) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Message.Unmarshal(m, b)
+}
+func (m *Message) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Message.Marshal(b, m, deterministic)
+}
+func (m *Message) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message.Merge(m, src)
+}
+func (m *Message) XXX_Size() int {
+	return xxx_messageInfo_Message.Size(m)
+}
+func (m *Message) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message proto.InternalMessageInfo
+
+func (m *Message) GetType() Message_MessageType {
+	if m != nil {
+		return m.Type
+	}
+	return Message_REGISTER
+}
+
+func (m *Message) GetRegister() *Message_Register {
+	if m != nil {
+		return m.Register
+	}
+	return nil
+}
+
+func (m *Message) GetRegisterResponse() *Message_RegisterResponse {
+	if m != nil {
+		return m.RegisterResponse
+	}
+	return nil
+}
+
+func (m *Message) GetUnregister() *Message_Unregister {
+	if m != nil {
+		return m.Unregister
+	}
+	return nil
+}
+
+func (m *Message) GetDiscover() *Message_Discover {
+	if m != nil {
+		return m.Discover
+	}
+	return nil
+}
+
+func (m *Message) GetDiscoverResponse() *Message_DiscoverResponse {
+	if m != nil {
+		return m.DiscoverResponse
+	}
+	return nil
+}
+
+// asks the server to list the sender under a namespace
+type Message_Register struct {
+	Ns                   string   `protobuf:"bytes,1,opt,name=ns,proto3" json:"ns,omitempty"`
+	SignedPeerRecord     []byte   `protobuf:"bytes,2,opt,name=signedPeerRecord,proto3" json:"signedPeerRecord,omitempty"`
+	Ttl                  uint64   `protobuf:"varint,3,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Message_Register) Reset()         { *m = Message_Register{} }
+func (m *Message_Register) String() string { return proto.CompactTextString(m) }
+func (*Message_Register) ProtoMessage()    {}
+func (*Message_Register) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ef0a1d5737df1c36, []int{0, 0}
+}
+func (m *Message_Register) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Message_Register.Unmarshal(m, b)
+}
+func (m *Message_Register) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Message_Register.Marshal(b, m, deterministic)
+}
+func (m *Message_Register) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message_Register.Merge(m, src)
+}
+func (m *Message_Register) XXX_Size() int {
+	return xxx_messageInfo_Message_Register.Size(m)
+}
+func (m *Message_Register) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message_Register.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message_Register proto.InternalMessageInfo
+
+func (m *Message_Register) GetNs() string {
+	if m != nil {
+		return m.Ns
+	}
+	return ""
+}
+
+func (m *Message_Register) GetSignedPeerRecord() []byte {
+	if m != nil {
+		return m.SignedPeerRecord
+	}
+	return nil
+}
+
+func (m *Message_Register) GetTtl() uint64 {
+	if m != nil {
+		return m.Ttl
+	}
+	return 0
+}
+
+type Message_RegisterResponse struct {
+	Status               Message_ResponseStatus `protobuf:"varint,1,opt,name=status,proto3,enum=rendezvous.pb.Message_ResponseStatus" json:"status,omitempty"`
+	StatusText           string                 `protobuf:"bytes,2,opt,name=statusText,proto3" json:"statusText,omitempty"`
+	Ttl                  uint64                 `protobuf:"varint,3,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
+}
+
+func (m *Message_RegisterResponse) Reset()         { *m = Message_RegisterResponse{} }
+func (m *Message_RegisterResponse) String() string { return proto.CompactTextString(m) }
+func (*Message_RegisterResponse) ProtoMessage()    {}
+func (*Message_RegisterResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ef0a1d5737df1c36, []int{0, 1}
+}
+func (m *Message_RegisterResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Message_RegisterResponse.Unmarshal(m, b)
+}
+func (m *Message_RegisterResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Message_RegisterResponse.Marshal(b, m, deterministic)
+}
+func (m *Message_RegisterResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message_RegisterResponse.Merge(m, src)
+}
+func (m *Message_RegisterResponse) XXX_Size() int {
+	return xxx_messageInfo_Message_RegisterResponse.Size(m)
+}
+func (m *Message_RegisterResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message_RegisterResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message_RegisterResponse proto.InternalMessageInfo
+
+func (m *Message_RegisterResponse) GetStatus() Message_ResponseStatus {
+	if m != nil {
+		return m.Status
+	}
+	return Message_OK
+}
+
+func (m *Message_RegisterResponse) GetStatusText() string {
+	if m != nil {
+		return m.StatusText
+	}
+	return ""
+}
+
+func (m *Message_RegisterResponse) GetTtl() uint64 {
+	if m != nil {
+		return m.Ttl
+	}
+	return 0
+}
+
+// removes the sender from a namespace. there is no response
+type Message_Unregister struct {
+	Ns                   string   `protobuf:"bytes,1,opt,name=ns,proto3" json:"ns,omitempty"`
+	Id                   []byte   `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Message_Unregister) Reset()         { *m = Message_Unregister{} }
+func (m *Message_Unregister) String() string { return proto.CompactTextString(m) }
+func (*Message_Unregister) ProtoMessage()    {}
+func (*Message_Unregister) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ef0a1d5737df1c36, []int{0, 2}
+}
+func (m *Message_Unregister) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Message_Unregister.Unmarshal(m, b)
+}
+func (m *Message_Unregister) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Message_Unregister.Marshal(b, m, deterministic)
+}
+func (m *Message_Unregister) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message_Unregister.Merge(m, src)
+}
+func (m *Message_Unregister) XXX_Size() int {
+	return xxx_messageInfo_Message_Unregister.Size(m)
+}
+func (m *Message_Unregister) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message_Unregister.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message_Unregister proto.InternalMessageInfo
+
+func (m *Message_Unregister) GetNs() string {
+	if m != nil {
+		return m.Ns
+	}
+	return ""
+}
+
+func (m *Message_Unregister) GetId() []byte {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+// lists the registrations of a namespace, or of all namespaces if ns is empty
+type Message_Discover struct {
+	Ns                   string   `protobuf:"bytes,1,opt,name=ns,proto3" json:"ns,omitempty"`
+	Limit                uint64   `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cookie               []byte   `protobuf:"bytes,3,opt,name=cookie,proto3" json:"cookie,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Message_Discover) Reset()         { *m = Message_Discover{} }
+func (m *Message_Discover) String() string { return proto.CompactTextString(m) }
+func (*Message_Discover) ProtoMessage()    {}
+func (*Message_Discover) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ef0a1d5737df1c36, []int{0, 3}
+}
+func (m *Message_Discover) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Message_Discover.Unmarshal(m, b)
+}
+func (m *Message_Discover) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Message_Discover.Marshal(b, m, deterministic)
+}
+func (m *Message_Discover) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message_Discover.Merge(m, src)
+}
+func (m *Message_Discover) XXX_Size() int {
+	return xxx_messageInfo_Message_Discover.Size(m)
+}
+func (m *Message_Discover) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message_Discover.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message_Discover proto.InternalMessageInfo
+
+func (m *Message_Discover) GetNs() string {
+	if m != nil {
+		return m.Ns
+	}
+	return ""
+}
+
+func (m *Message_Discover) GetLimit() uint64 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *Message_Discover) GetCookie() []byte {
+	if m != nil {
+		return m.Cookie
+	}
+	return nil
+}
+
+type Message_DiscoverResponse struct {
+	Registrations        []*Message_Register    `protobuf:"bytes,1,rep,name=registrations,proto3" json:"registrations,omitempty"`
+	Cookie               []byte                 `protobuf:"bytes,2,opt,name=cookie,proto3" json:"cookie,omitempty"`
+	Status               Message_ResponseStatus `protobuf:"varint,3,opt,name=status,proto3,enum=rendezvous.pb.Message_ResponseStatus" json:"status,omitempty"`
+	StatusText           string                 `protobuf:"bytes,4,opt,name=statusText,proto3" json:"statusText,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
+}
+
+func (m *Message_DiscoverResponse) Reset()         { *m = Message_DiscoverResponse{} }
+func (m *Message_DiscoverResponse) String() string { return proto.CompactTextString(m) }
+func (*Message_DiscoverResponse) ProtoMessage()    {}
+func (*Message_DiscoverResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ef0a1d5737df1c36, []int{0, 4}
+}
+func (m *Message_DiscoverResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Message_DiscoverResponse.Unmarshal(m, b)
+}
+func (m *Message_DiscoverResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Message_DiscoverResponse.Marshal(b, m, deterministic)
+}
+func (m *Message_DiscoverResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message_DiscoverResponse.Merge(m, src)
+}
+func (m *Message_DiscoverResponse) XXX_Size() int {
+	return xxx_messageInfo_Message_DiscoverResponse.Size(m)
+}
+func (m *Message_DiscoverResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message_DiscoverResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message_DiscoverResponse proto.InternalMessageInfo
+
+func (m *Message_DiscoverResponse) GetRegistrations() []*Message_Register {
+	if m != nil {
+		return m.Registrations
+	}
+	return nil
+}
+
+func (m *Message_DiscoverResponse) GetCookie() []byte {
+	if m != nil {
+		return m.Cookie
+	}
+	return nil
+}
+
+func (m *Message_DiscoverResponse) GetStatus() Message_ResponseStatus {
+	if m != nil {
+		return m.Status
+	}
+	return Message_OK
+}
+
+func (m *Message_DiscoverResponse) GetStatusText() string {
+	if m != nil {
+		return m.StatusText
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterEnum("rendezvous.pb.Message_MessageType", Message_MessageType_name, Message_MessageType_value)
+	proto.RegisterEnum("rendezvous.pb.Message_ResponseStatus", Message_ResponseStatus_name, Message_ResponseStatus_value)
+	proto.RegisterType((*Message)(nil), "rendezvous.pb.Message")
+	proto.RegisterType((*Message_Register)(nil), "rendezvous.pb.Message.Register")
+	proto.RegisterType((*Message_RegisterResponse)(nil), "rendezvous.pb.Message.RegisterResponse")
+	proto.RegisterType((*Message_Unregister)(nil), "rendezvous.pb.Message.Unregister")
+	proto.RegisterType((*Message_Discover)(nil), "rendezvous.pb.Message.Discover")
+	proto.RegisterType((*Message_DiscoverResponse)(nil), "rendezvous.pb.Message.DiscoverResponse")
+}
+
+func init() { proto.RegisterFile("rendezvous.proto", fileDescriptor_ef0a1d5737df1c36) }
+
+var fileDescriptor_ef0a1d5737df1c36 = []byte{
+	// 576 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x54, 0xcd, 0x6e, 0xd3, 0x40,
+	0x10, 0xae, 0x7f, 0x1a, 0xd2, 0x69, 0x1b, 0x6d, 0x97, 0x16, 0xa2, 0x08, 0x41, 0x88, 0x84, 0xa8,
+	0x10, 0xca, 0xa1, 0x48, 0x5c, 0x10, 0x07, 0x13, 0xaf, 0x5a, 0xab, 0xa9, 0x1d, 0x8d, 0x9d, 0x08,
+	0x71, 0xb1, 0xd2, 0x78, 0x89, 0x2c, 0x8a, 0x1d, 0x79, 0x9d, 0x8a, 0x72, 0xe5, 0x05, 0x78, 0x10,
+	0x9e, 0x81, 0x33, 0x47, 0x5e, 0x08, 0x09, 0xf9, 0x37, 0x4e, 0x42, 0x0a, 0x12, 0x27, 0xcf, 0x8c,
+	0xbf, 0xef, 0xdb, 0xf9, 0x66, 0xd6, 0x06, 0x12, 0xf1, 0xc0, 0xe3, 0x9f, 0xaf, 0xc3, 0xb9, 0xe8,
+	0xce, 0xa2, 0x30, 0x0e, 0xe9, 0x7e, 0xb5, 0x72, 0xd9, 0xf9, 0xb5, 0x03, 0x77, 0x2e, 0xb8, 0x10,
+	0xe3, 0x29, 0xa7, 0x2f, 0x41, 0x8d, 0x6f, 0x66, 0xbc, 0x29, 0xb5, 0xa5, 0xe3, 0xc6, 0x49, 0xa7,
+	0xbb, 0x84, 0xec, 0xe6, 0xa8, 0xe2, 0xe9, 0xdc, 0xcc, 0x38, 0xa6, 0x78, 0xfa, 0x0a, 0xea, 0x11,
+	0x9f, 0xfa, 0x22, 0xe6, 0x51, 0x53, 0x6e, 0x4b, 0xc7, 0xbb, 0x27, 0x8f, 0x36, 0x70, 0x31, 0x87,
+	0x61, 0x49, 0xa0, 0x36, 0x90, 0x22, 0x46, 0x2e, 0x66, 0x61, 0x20, 0x78, 0x53, 0x49, 0x45, 0x9e,
+	0xfe, 0x4d, 0x24, 0x87, 0xe3, 0x9a, 0x00, 0xd5, 0x00, 0xe6, 0x41, 0xd9, 0x93, 0x9a, 0xca, 0x3d,
+	0xde, 0x20, 0x37, 0x2c, 0x81, 0x58, 0x21, 0x25, 0xa6, 0x3c, 0x5f, 0x4c, 0xc2, 0x6b, 0x1e, 0x35,
+	0xb7, 0x6f, 0x35, 0xa5, 0xe7, 0x30, 0x2c, 0x09, 0x89, 0xa9, 0x22, 0x2e, 0x4d, 0xd5, 0x6e, 0x35,
+	0xa5, 0xaf, 0xc0, 0x71, 0x4d, 0xa0, 0xf5, 0x16, 0xea, 0x85, 0x75, 0xda, 0x00, 0x39, 0x10, 0xe9,
+	0xa2, 0x76, 0x50, 0x0e, 0x04, 0x7d, 0x06, 0x44, 0xf8, 0xd3, 0x80, 0x7b, 0x03, 0x9e, 0x30, 0x26,
+	0x61, 0xe4, 0xa5, 0xab, 0xd8, 0xc3, 0xb5, 0x3a, 0x25, 0xa0, 0xc4, 0xf1, 0x55, 0x3a, 0x64, 0x15,
+	0x93, 0xb0, 0xf5, 0x45, 0x02, 0xb2, 0x3a, 0x55, 0xfa, 0x1a, 0x6a, 0x22, 0x1e, 0xc7, 0x73, 0x91,
+	0xdf, 0x87, 0x27, 0x1b, 0xd7, 0x91, 0x11, 0xec, 0x14, 0x8c, 0x39, 0x89, 0x3e, 0x04, 0xc8, 0x22,
+	0x87, 0x7f, 0x8a, 0xd3, 0x5e, 0x76, 0xb0, 0x52, 0xf9, 0x43, 0x17, 0xcf, 0x01, 0x16, 0xbb, 0x58,
+	0x73, 0xd8, 0x00, 0xd9, 0x2f, 0x3c, 0xc9, 0xbe, 0xd7, 0x3a, 0x83, 0x7a, 0x31, 0xb3, 0x35, 0xec,
+	0x21, 0x6c, 0x5f, 0xf9, 0x1f, 0xfd, 0xec, 0x58, 0x15, 0xb3, 0x84, 0xde, 0x83, 0xda, 0x24, 0x0c,
+	0x3f, 0xf8, 0xd9, 0xfd, 0xda, 0xc3, 0x3c, 0x6b, 0xfd, 0x94, 0x80, 0xac, 0x8e, 0x9f, 0x32, 0xd8,
+	0xcf, 0x5a, 0x89, 0xc6, 0xb1, 0x1f, 0xa6, 0xea, 0xca, 0xbf, 0x5c, 0xec, 0x65, 0x56, 0xe5, 0x4c,
+	0xb9, 0x7a, 0x66, 0x65, 0xb8, 0xca, 0xff, 0x0f, 0x57, 0x5d, 0x1d, 0x6e, 0x67, 0x0a, 0xbb, 0x95,
+	0xcf, 0x94, 0xee, 0x41, 0x1d, 0xd9, 0xa9, 0x61, 0x3b, 0x0c, 0xc9, 0x16, 0x3d, 0x82, 0x83, 0x22,
+	0x73, 0x91, 0xd9, 0x03, 0xcb, 0xb4, 0x19, 0x91, 0x68, 0x03, 0x60, 0x68, 0x96, 0x30, 0x39, 0x21,
+	0xe9, 0x86, 0xdd, 0xb3, 0x46, 0x0c, 0x89, 0x92, 0x90, 0x8a, 0x6c, 0x41, 0x52, 0x3b, 0xdf, 0x25,
+	0x68, 0x2c, 0xf7, 0x48, 0x6b, 0x20, 0x5b, 0xe7, 0x64, 0x8b, 0xde, 0x87, 0xbb, 0xcc, 0x35, 0xcc,
+	0x91, 0xd6, 0x37, 0x74, 0xd7, 0xd4, 0x2e, 0x98, 0x3d, 0xd0, 0x7a, 0x8c, 0x78, 0xb4, 0x0d, 0x0f,
+	0x16, 0x2f, 0x6c, 0xe3, 0xd4, 0x64, 0xba, 0x3b, 0x60, 0xa9, 0x6e, 0xcf, 0x42, 0x9d, 0x70, 0x7a,
+	0x00, 0xfb, 0x0b, 0x84, 0xe3, 0xf4, 0xc9, 0x7b, 0x7a, 0x08, 0x64, 0x51, 0xea, 0x59, 0xd6, 0xb9,
+	0xc1, 0xc8, 0x94, 0x1e, 0x25, 0x55, 0xd3, 0x72, 0x5c, 0x6d, 0xe8, 0x9c, 0x59, 0x68, 0xbc, 0x63,
+	0x3a, 0xf9, 0x21, 0x65, 0x65, 0xc3, 0x74, 0x18, 0x9a, 0x5a, 0xdf, 0x65, 0x88, 0x16, 0x92, 0x6f,
+	0x32, 0xa5, 0x89, 0xec, 0xd0, 0xd4, 0x46, 0x9a, 0xd1, 0xd7, 0xde, 0xf4, 0x19, 0xf9, 0xaa, 0x5c,
+	0xd6, 0xd2, 0xbf, 0xe2, 0x8b, 0xdf, 0x03, 0x00, 0xa5, 0x9d, 0x47, 0xae, 0x29, 0x05, 0x00, 0x00,
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+	"github.com/libp2p/go-libp2p-examples/rendezvous"
+)
+
+func main() {
+	port := flag.Int("l", 4200, "libp2p listen port")
+	keyFile := flag.String("id", "", "load the host key from this file, creating it if needed")
+	ttl := flag.Duration("max-ttl", rendezvous.MaxTTL, "longest time to keep a registration")
+	maxRegistrations := flag.Int("max-registrations", 100, "namespaces a single peer may be registered under at a time")
+	configFile := flag.String("config", "", "build the host from this configuration file, YAML or JSON, instead of -l and -id")
+	flag.Parse()
+
+	// Clients dial the server by peer ID. With a key file, it stays the
+	// same when the server restarts.
+	cfg := &hostconfig.Config{
+		Identity: hostconfig.Identity{File: *keyFile},
+		ListenAddrs: []string{
+			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", *port),
+			fmt.Sprintf("/ip6/::/tcp/%d", *port),
+		},
+	}
+	if *configFile != "" {
+		var err error
+		if cfg, err = hostconfig.Load(*configFile); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	h, err := hostconfig.New(context.Background(), cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	rendezvous.NewServer(h, rendezvous.Options{
+		MaxTTL:           *ttl,
+		MaxRegistrations: *maxRegistrations,
+	})
+
+	fmt.Println("Rendezvous server running, pass one of these addresses to the chat with -rendezvous-server:")
+	for _, a := range h.Addrs() {
+		fmt.Printf(" - %s/p2p/%s\n", a, h.ID().Pretty())
+	}
+	if cfg.Identity.File == "" {
+		fmt.Println("\nThe peer ID changes on every start, use -id to keep it.")
+	}
+
+	select {} // hang forever
+}
@@ -0,0 +1,393 @@
+// Package rendezvous implements the libp2p rendezvous protocol: a rendezvous
+// server keeps, for every namespace, the list of peers that registered under
+// it, and hands it to peers that ask. It is a small, self-hosted alternative
+// to announcing and looking up namespaces on the public DHT.
+package rendezvous
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/record"
+
+	ggio "github.com/gogo/protobuf/io"
+	pb "github.com/libp2p/go-libp2p-examples/rendezvous/pb"
+)
+
+// Protocol is the protocol ID of the rendezvous protocol.
+const Protocol = "/rendezvous/1.0.0"
+
+// Limits set by the rendezvous spec.
+const (
+	// MaxNamespaceLength is the longest namespace, in bytes.
+	MaxNamespaceLength = 255
+	// DefaultTTL is how long a registration is kept if the peer doesn't
+	// ask for a TTL.
+	DefaultTTL = 2 * time.Hour
+	// MinTTL is the shortest TTL a peer may ask for.
+	MinTTL = 2 * time.Minute
+	// MaxTTL is the longest TTL a peer may ask for.
+	MaxTTL = 72 * time.Hour
+)
+
+// maxMessageSize bounds the protocol messages. A discover response can carry
+// many registrations.
+const maxMessageSize = 4 << 20
+
+// Options configures a rendezvous server. The zero value is usable.
+type Options struct {
+	// MaxTTL is the longest time a registration is kept, at most the
+	// spec's MaxTTL. Defaults to MaxTTL.
+	MaxTTL time.Duration
+	// MaxRegistrations is the number of namespaces a single peer may be
+	// registered under at a time. Defaults to 100.
+	MaxRegistrations int
+	// MaxDiscoverLimit is the most registrations returned by a single
+	// discover request. Defaults to 1000.
+	MaxDiscoverLimit int
+}
+
+// Server is a rendezvous point.
+type Server struct {
+	host host.Host
+	opts Options
+	done chan struct{}
+
+	mu         sync.Mutex
+	namespaces map[string]map[peer.ID]*registration
+	counts     map[peer.ID]int // registrations by peer
+	seq        uint64          // of the latest registration
+	closed     bool
+}
+
+type registration struct {
+	ns      string
+	peer    peer.ID
+	record  []byte // the signed peer record, as received
+	ttl     time.Duration
+	expires time.Time
+	seq     uint64 // orders registrations, for discover cookies
+}
+
+// statusError is a failed request, and the status to answer it with.
+type statusError struct {
+	status pb.Message_ResponseStatus
+	text   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.status, e.text)
+}
+
+func newStatusError(status pb.Message_ResponseStatus, format string, args ...interface{}) *statusError {
+	return &statusError{status: status, text: fmt.Sprintf(format, args...)}
+}
+
+// NewServer attaches a rendezvous server to the given host.
+func NewServer(h host.Host, opts Options) *Server {
+	if opts.MaxTTL <= 0 || opts.MaxTTL > MaxTTL {
+		opts.MaxTTL = MaxTTL
+	}
+	if opts.MaxRegistrations <= 0 {
+		opts.MaxRegistrations = 100
+	}
+	if opts.MaxDiscoverLimit <= 0 {
+		opts.MaxDiscoverLimit = 1000
+	}
+
+	s := &Server{
+		host:       h,
+		opts:       opts,
+		done:       make(chan struct{}),
+		namespaces: make(map[string]map[peer.ID]*registration),
+		counts:     make(map[peer.ID]int),
+	}
+	h.SetStreamHandler(Protocol, s.handleStream)
+	go s.expireLoop()
+	return s
+}
+
+// Close removes the stream handler from the host.
+func (s *Server) Close() error {
+	s.host.RemoveStreamHandler(Protocol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	return nil
+}
+
+// Stats returns the number of registrations and namespaces.
+func (s *Server) Stats() (registrations, namespaces int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, regs := range s.namespaces {
+		registrations += len(regs)
+	}
+	return registrations, len(s.namespaces)
+}
+
+// handleStream answers requests until the peer closes the stream.
+func (s *Server) handleStream(stream network.Stream) {
+	defer stream.Close()
+
+	reader := ggio.NewDelimitedReader(stream, maxMessageSize)
+	writer := ggio.NewDelimitedWriter(stream)
+
+	// The connection is authenticated, registrations are made for and
+	// charged to whoever opened the stream.
+	from := stream.Conn().RemotePeer()
+	for {
+		req := &pb.Message{}
+		if err := reader.ReadMsg(req); err != nil {
+			return
+		}
+
+		var resp *pb.Message
+		switch req.Type {
+		case pb.Message_REGISTER:
+			resp = s.register(from, req.Register)
+		case pb.Message_UNREGISTER:
+			s.unregister(from, req.Unregister)
+		case pb.Message_DISCOVER:
+			resp = s.discover(req.Discover)
+		default:
+			log.Printf("Unexpected %s message from %s\n", req.Type, from.Pretty())
+			stream.Reset()
+			return
+		}
+
+		if resp == nil {
+			continue
+		}
+		if err := writer.WriteMsg(resp); err != nil {
+			stream.Reset()
+			return
+		}
+	}
+}
+
+func (s *Server) register(from peer.ID, req *pb.Message_Register) *pb.Message {
+	resp := &pb.Message_RegisterResponse{}
+	ttl, err := s.add(from, req)
+	if err != nil {
+		log.Printf("Refused registration of %s: %s\n", from.Pretty(), err)
+		resp.Status, resp.StatusText = err.status, err.text
+	} else {
+		resp.Ttl = uint64(ttl / time.Second)
+	}
+	return &pb.Message{Type: pb.Message_REGISTER_RESPONSE, RegisterResponse: resp}
+}
+
+func (s *Server) add(from peer.ID, req *pb.Message_Register) (time.Duration, *statusError) {
+	if req == nil {
+		return 0, newStatusError(pb.Message_E_INTERNAL_ERROR, "missing register message")
+	}
+	if err := checkNamespace(req.Ns); err != nil {
+		return 0, err
+	}
+
+	ttl := time.Duration(req.Ttl) * time.Second
+	if req.Ttl == 0 {
+		ttl = DefaultTTL
+	}
+	if ttl < MinTTL || ttl > MaxTTL {
+		return 0, newStatusError(pb.Message_E_INVALID_TTL, "ttl must be between %s and %s", MinTTL, MaxTTL)
+	}
+	if ttl > s.opts.MaxTTL {
+		ttl = s.opts.MaxTTL
+	}
+
+	// The record must be signed by the peer registering, so nobody can
+	// register somebody else, or advertise addresses in its name.
+	_, rec, err := record.ConsumeEnvelope(req.SignedPeerRecord, peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		return 0, newStatusError(pb.Message_E_INVALID_SIGNED_PEER_RECORD, "%s", err)
+	}
+	pr, ok := rec.(*peer.PeerRecord)
+	if !ok || pr.PeerID != from {
+		return 0, newStatusError(pb.Message_E_INVALID_SIGNED_PEER_RECORD, "record is not signed by %s", from.Pretty())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	regs := s.namespaces[req.Ns]
+	if regs == nil {
+		regs = make(map[peer.ID]*registration)
+		s.namespaces[req.Ns] = regs
+	}
+	if regs[from] == nil {
+		if s.counts[from] >= s.opts.MaxRegistrations {
+			if len(regs) == 0 {
+				delete(s.namespaces, req.Ns)
+			}
+			return 0, newStatusError(pb.Message_E_NOT_AUTHORIZED, "at most %d registrations per peer", s.opts.MaxRegistrations)
+		}
+		s.counts[from]++
+	}
+
+	// Registering again replaces the previous registration, and moves it
+	// past the cookies handed out so far.
+	s.seq++
+	regs[from] = &registration{
+		ns:      req.Ns,
+		peer:    from,
+		record:  req.SignedPeerRecord,
+		ttl:     ttl,
+		expires: time.Now().Add(ttl),
+		seq:     s.seq,
+	}
+	log.Printf("Registered %s under %q for %s\n", from.Pretty(), req.Ns, ttl)
+	return ttl, nil
+}
+
+func (s *Server) unregister(from peer.ID, req *pb.Message_Unregister) {
+	if req == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.namespaces[req.Ns][from] != nil {
+		s.remove(req.Ns, from)
+		log.Printf("Unregistered %s from %q\n", from.Pretty(), req.Ns)
+	}
+}
+
+func (s *Server) discover(req *pb.Message_Discover) *pb.Message {
+	resp := &pb.Message_DiscoverResponse{}
+	regs, cookie, err := s.find(req)
+	if err != nil {
+		resp.Status, resp.StatusText = err.status, err.text
+	}
+	for _, r := range regs {
+		resp.Registrations = append(resp.Registrations, &pb.Message_Register{
+			Ns:               r.ns,
+			SignedPeerRecord: r.record,
+			Ttl:              uint64(time.Until(r.expires) / time.Second),
+		})
+	}
+	resp.Cookie = cookie
+	return &pb.Message{Type: pb.Message_DISCOVER_RESPONSE, DiscoverResponse: resp}
+}
+
+// find returns the registrations of a discover request, oldest first, and the
+// cookie to get the following ones.
+func (s *Server) find(req *pb.Message_Discover) ([]*registration, []byte, *statusError) {
+	if req == nil {
+		return nil, nil, newStatusError(pb.Message_E_INTERNAL_ERROR, "missing discover message")
+	}
+	if req.Ns != "" {
+		if err := checkNamespace(req.Ns); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var after uint64
+	if len(req.Cookie) > 0 {
+		var ok bool
+		if after, ok = parseCookie(req.Cookie, req.Ns); !ok {
+			return nil, nil, newStatusError(pb.Message_E_INVALID_COOKIE, "cookie is not from a discover request for %q", req.Ns)
+		}
+	}
+
+	limit := s.opts.MaxDiscoverLimit
+	if req.Limit > 0 && req.Limit < uint64(limit) {
+		limit = int(req.Limit)
+	}
+
+	s.mu.Lock()
+	var found []*registration
+	now := time.Now()
+	collect := func(regs map[peer.ID]*registration) {
+		for _, r := range regs {
+			if r.seq > after && now.Before(r.expires) {
+				found = append(found, r)
+			}
+		}
+	}
+	if req.Ns == "" {
+		for _, regs := range s.namespaces {
+			collect(regs)
+		}
+	} else {
+		collect(s.namespaces[req.Ns])
+	}
+	s.mu.Unlock()
+
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	if len(found) > 0 {
+		after = found[len(found)-1].seq
+	}
+	return found, makeCookie(after, req.Ns), nil
+}
+
+// remove deletes a registration. s.mu must be held.
+func (s *Server) remove(ns string, p peer.ID) {
+	delete(s.namespaces[ns], p)
+	if len(s.namespaces[ns]) == 0 {
+		delete(s.namespaces, ns)
+	}
+	if s.counts[p]--; s.counts[p] <= 0 {
+		delete(s.counts, p)
+	}
+}
+
+// expireLoop drops expired registrations every minute.
+func (s *Server) expireLoop() {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			s.mu.Lock()
+			for ns, regs := range s.namespaces {
+				for p, r := range regs {
+					if !now.Before(r.expires) {
+						s.remove(ns, p)
+					}
+				}
+			}
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func checkNamespace(ns string) *statusError {
+	if ns == "" || len(ns) > MaxNamespaceLength {
+		return newStatusError(pb.Message_E_INVALID_NAMESPACE, "namespace must be between 1 and %d bytes", MaxNamespaceLength)
+	}
+	return nil
+}
+
+// A cookie is the sequence number of the last registration returned,
+// followed by the namespace it is valid for. Clients treat it as opaque.
+func makeCookie(seq uint64, ns string) []byte {
+	c := make([]byte, 8+len(ns))
+	binary.BigEndian.PutUint64(c, seq)
+	copy(c[8:], ns)
+	return c
+}
+
+func parseCookie(c []byte, ns string) (uint64, bool) {
+	if len(c) < 8 || string(c[8:]) != ns {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(c), true
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+
+	"github.com/ipfs/go-cid"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-msgio"
+)
+
+// blockProtocol serves blocks by CID. Each request on a stream is a CID,
+// each response the block, or nothing if we don't have it. Both are
+// prefixed with their length as a varint. There can be many requests on a
+// single stream.
+const blockProtocol = "/routed-echo/blocks/1.0.0"
+
+// reprovideInterval is how often we announce the content again. The DHT
+// forgets provider records after a day.
+const reprovideInterval = 12 * time.Hour
+
+// maxProviders is how many providers we try before giving up on a fetch.
+const maxProviders = 20
+
+// serveBlocks answers block requests on h from blocks.
+func serveBlocks(h host.Host, blocks map[cid.Cid][]byte) {
+	h.SetStreamHandler(blockProtocol, func(s network.Stream) {
+		defer s.Close()
+		r := msgio.NewVarintReaderSize(s, maxBlockSize)
+		w := msgio.NewVarintWriter(s)
+		for {
+			req, err := r.ReadMsg()
+			if err != nil {
+				return
+			}
+			c, err := cid.Cast(req)
+			if err != nil {
+				s.Reset()
+				return
+			}
+			// An unknown CID gets an empty response.
+			if err := w.WriteMsg(blocks[c]); err != nil {
+				s.Reset()
+				return
+			}
+		}
+	})
+}
+
+// provideFile reads the file at path, serves its blocks, and announces on
+// the DHT that we provide it. It returns the file's CID.
+func provideFile(ctx context.Context, h host.Host, d *dht.IpfsDHT, path string) (cid.Cid, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cid.Undef, err
+	}
+	root, blocks, err := importFile(data)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("%s: %w", path, err)
+	}
+	serveBlocks(h, blocks)
+
+	// Only the root is announced, whoever has it has the chunks too.
+	announce := func() error {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		return d.Provide(ctx, root, true)
+	}
+	if err := announce(); err != nil {
+		return cid.Undef, fmt.Errorf("announcing %s: %w", root, err)
+	}
+	go func() {
+		ticker := time.NewTicker(reprovideInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := announce(); err != nil {
+					log.Printf("announcing %s: %s\n", root, err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return root, nil
+}
+
+// fetchFile finds the providers of root on the DHT, and downloads and
+// verifies the file from the first one that has all of it.
+func fetchFile(ctx context.Context, h host.Host, d *dht.IpfsDHT, root cid.Cid) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tried := 0
+	for p := range d.FindProvidersAsync(ctx, root, maxProviders) {
+		if p.ID == h.ID() {
+			continue
+		}
+		tried++
+		log.Printf("fetching %s from %s\n", root, p.ID.Pretty())
+		data, err := fetchFrom(ctx, h, p, root)
+		if err == nil {
+			return data, nil
+		}
+		log.Printf("  failed: %s\n", err)
+	}
+	if tried == 0 {
+		return nil, fmt.Errorf("no provider found for %s", root)
+	}
+	return nil, fmt.Errorf("none of the %d providers of %s could serve it", tried, root)
+}
+
+// fetchFrom downloads the file root from a single provider, over a single
+// stream.
+func fetchFrom(ctx context.Context, h host.Host, p peer.AddrInfo, root cid.Cid) ([]byte, error) {
+	h.Peerstore().AddAddrs(p.ID, p.Addrs, peerstore.TempAddrTTL)
+	s, err := h.NewStream(ctx, p.ID, blockProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	r := msgio.NewVarintReaderSize(s, maxBlockSize)
+	w := msgio.NewVarintWriter(s)
+
+	get := func(c cid.Cid) ([]byte, error) {
+		if err := w.WriteMsg(c.Bytes()); err != nil {
+			return nil, err
+		}
+		data, err := r.ReadMsg()
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyBlock(c, data); err != nil {
+			if len(data) == 0 {
+				return nil, fmt.Errorf("block %s: not found", c)
+			}
+			return nil, err
+		}
+		return data, nil
+	}
+
+	data, err := get(root)
+	if err != nil {
+		s.Reset()
+		return nil, err
+	}
+	switch root.Type() {
+	case cid.Raw:
+		return data, nil
+	case dagJSON:
+	default:
+		s.Reset()
+		return nil, fmt.Errorf("%s: unsupported codec %#x", root, root.Type())
+	}
+
+	links, size, err := decodeRoot(data)
+	if err != nil {
+		s.Reset()
+		return nil, err
+	}
+	var file bytes.Buffer
+	for _, c := range links {
+		chunk, err := get(c)
+		if err != nil {
+			s.Reset()
+			return nil, err
+		}
+		file.Write(chunk)
+	}
+	if uint64(file.Len()) != size {
+		return nil, errors.New("file size doesn't match its root block")
+	}
+	return file.Bytes(), nil
+}
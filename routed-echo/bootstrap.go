@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
@@ -8,11 +9,17 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/discovery"
 
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -32,6 +39,74 @@ var (
 	LOCAL_PEER_ENDPOINT = "http://localhost:5001/api/v0/id"
 )
 
+// BootstrapEnv is the environment variable holding extra bootstrap peers,
+// as multiaddrs separated by commas or spaces.
+const BootstrapEnv = "ROUTED_ECHO_BOOTSTRAP"
+
+// mdnsServiceTag is the mDNS service routed-echo hosts announce themselves
+// under, so they can bootstrap from each other on a local network.
+const mdnsServiceTag = "routed-echo"
+
+// dialTimeout bounds a single bootstrap connection attempt.
+const dialTimeout = 15 * time.Second
+
+// routingTableWait bounds how long we wait for a bootstrap peer to join the
+// DHT routing table.
+const routingTableWait = 10 * time.Second
+
+// bootstrapConfig says where to look for bootstrap peers. All sources are
+// merged.
+type bootstrapConfig struct {
+	File      string        // config file, one multiaddr per line
+	Addrs     string        // multiaddrs separated by commas
+	Global    bool          // add the IPFS bootstrap peers
+	LocalIPFS bool          // ask the local go-ipfs daemon for its address
+	CacheFile string        // last known good peers, read and rewritten
+	MDNS      time.Duration // how long to look for peers on the local network, 0 disables mDNS
+	Min       int           // connections needed for bootstrapping to succeed
+	Self      bool          // we are the bootstrap peer of a local network
+}
+
+// choose picks where to bootstrap from, given -bootstrap-self and -global:
+// nowhere for the bootstrap peer itself, the IPFS bootstrap peers, the peers
+// given in the configuration, or else the local go-ipfs daemon. It returns
+// the flags the next host needs to join the same network.
+func (cfg *bootstrapConfig) choose(self, global bool) (hintFlags string) {
+	switch {
+	case self:
+		log.Println("acting as the bootstrap peer")
+		cfg.Self = true
+		cfg.Min = 0
+	case global:
+		log.Println("using global bootstrap")
+		cfg.Global = true
+		hintFlags = " -global"
+	case cfg.Addrs != "" || cfg.File != "" || os.Getenv(BootstrapEnv) != "":
+		// The peers we were given make the network, there's no need for
+		// an ipfs daemon.
+		log.Println("using the given bootstrap peers")
+		if cfg.Addrs != "" {
+			hintFlags = " -bootstrap " + cfg.Addrs
+		}
+	default:
+		log.Println("using local bootstrap")
+		cfg.LocalIPFS = true
+	}
+	return hintFlags
+}
+
+// bootstrapPeer is a candidate bootstrap peer, and where we heard of it.
+type bootstrapPeer struct {
+	peer.AddrInfo
+	sources []string
+}
+
+// bootstrapReport is the outcome of bootstrapping.
+type bootstrapReport struct {
+	Connected []*bootstrapPeer
+	Failed    map[*bootstrapPeer]error
+}
+
 // Borrowed from ipfs code to parse the results of the command `ipfs id`
 type IdOutput struct {
 	ID              string
@@ -41,51 +116,207 @@ type IdOutput struct {
 	ProtocolVersion string
 }
 
-// quick and dirty function to get the local ipfs daemons address for bootstrapping
-func getLocalPeerInfo() []peer.AddrInfo {
-	resp, err := http.Get(LOCAL_PEER_ENDPOINT)
+// getLocalPeerInfo gets the local ipfs daemons address for bootstrapping
+func getLocalPeerInfo() ([]peer.AddrInfo, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(LOCAL_PEER_ENDPOINT)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
 	var js IdOutput
 	err = json.Unmarshal(body, &js)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
 	for _, addr := range js.Addresses {
 		// For some reason, possibly NAT traversal, we need to grab the loopback ip address
-		if addr[0:8] == "/ip4/127" {
-			return convertPeers([]string{addr})
+		if strings.HasPrefix(addr, "/ip4/127") {
+			return parsePeers([]string{addr})
 		}
 	}
-	log.Fatalln(err)
-	return make([]peer.AddrInfo, 1) // not reachable, but keeps the compiler happy
+	return nil, errors.New("the ipfs daemon has no loopback address")
 }
 
 func convertPeers(peers []string) []peer.AddrInfo {
-	pinfos := make([]peer.AddrInfo, len(peers))
-	for i, addr := range peers {
-		maddr := ma.StringCast(addr)
+	pinfos, err := parsePeers(peers)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return pinfos
+}
+
+// parsePeers parses multiaddrs ending with /p2p/<peer ID>.
+func parsePeers(peers []string) ([]peer.AddrInfo, error) {
+	pinfos := make([]peer.AddrInfo, 0, len(peers))
+	for _, addr := range peers {
+		maddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", addr, err)
+		}
 		p, err := peer.AddrInfoFromP2pAddr(maddr)
 		if err != nil {
-			log.Fatalln(err)
+			return nil, fmt.Errorf("%q: %w", addr, err)
 		}
-		pinfos[i] = *p
+		pinfos = append(pinfos, *p)
 	}
-	return pinfos
+	return pinfos, nil
+}
+
+// readPeerFile reads a file of multiaddrs, one per line. Empty lines and
+// lines starting with # are skipped.
+func readPeerFile(path string) ([]peer.AddrInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var addrs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			addrs = append(addrs, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return parsePeers(addrs)
+}
+
+// defaultCacheFile is where the last known good peers are kept unless
+// -peer-cache says otherwise.
+func defaultCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "routed-echo", "peers")
 }
 
-// This code is borrowed from the go-ipfs bootstrap process
-func bootstrapConnect(ctx context.Context, ph host.Host, peers []peer.AddrInfo) error {
-	if len(peers) < 1 {
-		return errors.New("not enough bootstrap peers")
+// gatherBootstrapPeers collects the candidate bootstrap peers from every
+// configured source. A source that fails is logged and skipped.
+func gatherBootstrapPeers(ctx context.Context, h host.Host, cfg bootstrapConfig) []*bootstrapPeer {
+	var order []*bootstrapPeer
+	byID := make(map[peer.ID]*bootstrapPeer)
+	add := func(source string, pis []peer.AddrInfo) {
+		for _, pi := range pis {
+			if pi.ID == h.ID() {
+				continue
+			}
+			bp := byID[pi.ID]
+			if bp == nil {
+				bp = &bootstrapPeer{AddrInfo: peer.AddrInfo{ID: pi.ID}}
+				byID[pi.ID] = bp
+				order = append(order, bp)
+			}
+			bp.Addrs = uniqueAddrs(append(bp.Addrs, pi.Addrs...))
+			if n := len(bp.sources); n == 0 || bp.sources[n-1] != source {
+				bp.sources = append(bp.sources, source)
+			}
+		}
+	}
+	fromList := func(source, list string) {
+		fields := strings.FieldsFunc(list, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\n' || r == '\t'
+		})
+		pis, err := parsePeers(fields)
+		if err != nil {
+			log.Printf("bootstrap: ignoring %s: %s\n", source, err)
+			return
+		}
+		add(source, pis)
+	}
+	fromFile := func(source, path string) {
+		pis, err := readPeerFile(path)
+		if err != nil {
+			if !(source == "cache" && os.IsNotExist(err)) {
+				log.Printf("bootstrap: ignoring %s: %s\n", source, err)
+			}
+			return
+		}
+		add(source, pis)
 	}
 
-	errs := make(chan error, len(peers))
+	if cfg.File != "" {
+		fromFile("config", cfg.File)
+	}
+	fromList("$"+BootstrapEnv, os.Getenv(BootstrapEnv))
+	fromList("-bootstrap", cfg.Addrs)
+	if cfg.CacheFile != "" {
+		fromFile("cache", cfg.CacheFile)
+	}
+	if cfg.Global {
+		add("global", IPFS_PEERS)
+	}
+	if cfg.LocalIPFS {
+		pis, err := getLocalPeerInfo()
+		if err != nil {
+			log.Printf("bootstrap: no local ipfs daemon: %s\n", err)
+		}
+		add("ipfs", pis)
+	}
+	if cfg.MDNS > 0 {
+		pis, err := findLocalPeers(ctx, h, cfg.MDNS)
+		if err != nil {
+			log.Printf("bootstrap: mDNS failed: %s\n", err)
+		}
+		add("mdns", pis)
+	}
+	return order
+}
+
+type mdnsNotifee chan peer.AddrInfo
+
+func (n mdnsNotifee) HandlePeerFound(pi peer.AddrInfo) {
+	select {
+	case n <- pi:
+	default:
+	}
+}
+
+// findLocalPeers announces h on the local network with mDNS, and returns the
+// other routed-echo hosts found within wait. The announcements go on until
+// ctx is done, so that hosts started later find this one.
+func findLocalPeers(ctx context.Context, h host.Host, wait time.Duration) ([]peer.AddrInfo, error) {
+	service, err := discovery.NewMdnsService(ctx, h, wait/2, mdnsServiceTag)
+	if err != nil {
+		return nil, err
+	}
+	found := make(mdnsNotifee, 32)
+	service.RegisterNotifee(found)
+	defer service.UnregisterNotifee(found)
+
+	var pis []peer.AddrInfo
+	timeout := time.After(wait)
+	for {
+		select {
+		case pi := <-found:
+			pis = append(pis, pi)
+		case <-timeout:
+			return pis, nil
+		case <-ctx.Done():
+			return pis, ctx.Err()
+		}
+	}
+}
+
+// bootstrapConnect connects to all peers concurrently. It fails if fewer
+// than min connections succeed, the report tells which ones failed either
+// way.
+func bootstrapConnect(ctx context.Context, ph host.Host, peers []*bootstrapPeer, min int) (*bootstrapReport, error) {
+	report := &bootstrapReport{Failed: make(map[*bootstrapPeer]error)}
+	if len(peers) < min {
+		return report, fmt.Errorf("need %d bootstrap peers, only found %d", min, len(peers))
+	}
+
+	var mu sync.Mutex
 	var wg sync.WaitGroup
 	for _, p := range peers {
 
@@ -95,36 +326,103 @@ func bootstrapConnect(ctx context.Context, ph host.Host, peers []peer.AddrInfo)
 		// Also, performed asynchronously for dial speed.
 
 		wg.Add(1)
-		go func(p peer.AddrInfo) {
+		go func(p *bootstrapPeer) {
 			defer wg.Done()
-			defer log.Println(ctx, "bootstrapDial", ph.ID(), p.ID)
 			log.Printf("%s bootstrapping to %s", ph.ID(), p.ID)
 
 			ph.Peerstore().AddAddrs(p.ID, p.Addrs, peerstore.PermanentAddrTTL)
-			if err := ph.Connect(ctx, p); err != nil {
-				log.Println(ctx, "bootstrapDialFailed", p.ID)
-				log.Printf("failed to bootstrap with %v: %s", p.ID, err)
-				errs <- err
+			dctx, cancel := context.WithTimeout(ctx, dialTimeout)
+			err := ph.Connect(dctx, p.AddrInfo)
+			cancel()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Failed[p] = err
 				return
 			}
-			log.Println(ctx, "bootstrapDialSuccess", p.ID)
-			log.Printf("bootstrapped with %v", p.ID)
+			report.Connected = append(report.Connected, p)
 		}(p)
 	}
 	wg.Wait()
 
-	// our failure condition is when no connection attempt succeeded.
-	// So drain the errs channel, counting the results.
-	close(errs)
-	count := 0
-	var err error
-	for err = range errs {
-		if err != nil {
-			count++
+	if len(report.Connected) < min {
+		return report, fmt.Errorf("failed to bootstrap: connected to %d peers, need %d", len(report.Connected), min)
+	}
+	return report, nil
+}
+
+// waitForRoutingTable waits until size reports at least one peer, and tells
+// whether it did within timeout.
+func waitForRoutingTable(ctx context.Context, size func() int, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for size() == 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// Log prints which peers we connected to, and why the others failed.
+func (r *bootstrapReport) Log() {
+	log.Printf("bootstrap: connected to %d of %d peers\n", len(r.Connected), len(r.Connected)+len(r.Failed))
+	for _, p := range r.Connected {
+		log.Printf("  ok      %s (%s)\n", p.ID.Pretty(), strings.Join(p.sources, ", "))
+	}
+
+	failed := make([]*bootstrapPeer, 0, len(r.Failed))
+	for p := range r.Failed {
+		failed = append(failed, p)
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].ID < failed[j].ID })
+	for _, p := range failed {
+		log.Printf("  failed  %s (%s): %s\n", p.ID.Pretty(), strings.Join(p.sources, ", "), r.Failed[p])
+	}
+}
+
+// saveKnownGood writes the peers we connected to into the cache file, with
+// the addresses the connections used, so that they are tried first next
+// time. The file is left alone if no connection succeeded.
+func saveKnownGood(path string, h host.Host, peers []*bootstrapPeer) error {
+	if path == "" || len(peers) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("# last known good bootstrap peers, rewritten by routed-echo\n")
+	for _, p := range peers {
+		addrs := make([]ma.Multiaddr, 0, len(p.Addrs))
+		for _, c := range h.Network().ConnsToPeer(p.ID) {
+			addrs = append(addrs, c.RemoteMultiaddr())
+		}
+		if len(addrs) == 0 {
+			addrs = p.Addrs
+		}
+		for _, a := range uniqueAddrs(addrs) {
+			fmt.Fprintf(&b, "%s/p2p/%s\n", a, p.ID.Pretty())
 		}
 	}
-	if count == len(peers) {
-		return fmt.Errorf("failed to bootstrap. %s", err)
+	return ioutil.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// uniqueAddrs removes duplicate addresses, keeping the first of each.
+func uniqueAddrs(addrs []ma.Multiaddr) []ma.Multiaddr {
+	seen := make(map[string]bool, len(addrs))
+	out := addrs[:0]
+	for _, a := range addrs {
+		if !seen[string(a.Bytes())] {
+			seen[string(a.Bytes())] = true
+			out = append(out, a)
+		}
 	}
-	return nil
+	return out
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// chunkSize is the size of the blocks files are split into.
+const chunkSize = 256 << 10
+
+// maxBlockSize bounds the blocks we make and accept. It leaves room for the
+// root block of a file of a few gigabytes.
+const maxBlockSize = 1 << 20
+
+// dagJSON is the multicodec of DAG-JSON, which the root block of a file of
+// several chunks is encoded in. go-cid has no constant for it.
+const dagJSON = 0x0129
+
+// fileRoot is the root block of a file of several chunks: links to the raw
+// chunks, in order, and the file size. In DAG-JSON, a link is an object
+// with a single "/" key.
+type fileRoot struct {
+	Links []link `json:"links"`
+	Size  uint64 `json:"size"`
+}
+
+type link struct {
+	CID string `json:"/"`
+}
+
+// blockCID returns the CIDv1 of a block, hashed with SHA2-256.
+func blockCID(codec uint64, data []byte) (cid.Cid, error) {
+	prefix := cid.Prefix{Version: 1, Codec: codec, MhType: mh.SHA2_256, MhLength: -1}
+	return prefix.Sum(data)
+}
+
+// verifyBlock checks that data is the block c names.
+func verifyBlock(c cid.Cid, data []byte) error {
+	got, err := c.Prefix().Sum(data)
+	if err != nil {
+		return err
+	}
+	if !got.Equals(c) {
+		return fmt.Errorf("block %s: content doesn't match, got %s", c, got)
+	}
+	return nil
+}
+
+// importFile splits data into blocks, and returns the CID of the file and
+// every block by CID. A file that fits in a single chunk is a single raw
+// block, otherwise it is a DAG-JSON root linking to its chunks.
+func importFile(data []byte) (cid.Cid, map[cid.Cid][]byte, error) {
+	blocks := make(map[cid.Cid][]byte)
+	if len(data) <= chunkSize {
+		c, err := blockCID(cid.Raw, data)
+		if err != nil {
+			return cid.Undef, nil, err
+		}
+		blocks[c] = data
+		return c, blocks, nil
+	}
+
+	root := fileRoot{Size: uint64(len(data))}
+	for off := 0; off < len(data); off += chunkSize {
+		end := off + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		c, err := blockCID(cid.Raw, data[off:end])
+		if err != nil {
+			return cid.Undef, nil, err
+		}
+		blocks[c] = data[off:end]
+		root.Links = append(root.Links, link{CID: c.String()})
+	}
+
+	b, err := json.Marshal(root)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	if len(b) > maxBlockSize {
+		return cid.Undef, nil, errors.New("file too large")
+	}
+	c, err := blockCID(dagJSON, b)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	blocks[c] = b
+	return c, blocks, nil
+}
+
+// decodeRoot returns the chunks a root block links to.
+func decodeRoot(data []byte) ([]cid.Cid, uint64, error) {
+	var root fileRoot
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, 0, fmt.Errorf("bad root block: %w", err)
+	}
+	links := make([]cid.Cid, 0, len(root.Links))
+	for _, l := range root.Links {
+		c, err := cid.Decode(l.CID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("bad root block: %w", err)
+		}
+		if c.Type() != cid.Raw {
+			return nil, 0, fmt.Errorf("bad root block: %s is not a raw block", c)
+		}
+		links = append(links, c)
+	}
+	return links, root.Size, nil
+}
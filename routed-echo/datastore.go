@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	ds "github.com/ipfs/go-datastore"
+	dsync "github.com/ipfs/go-datastore/sync"
+	badger "github.com/ipfs/go-ds-badger"
+)
+
+// routingTableKey is where the peers of the DHT routing table are saved, next
+// to the DHT's own records.
+var routingTableKey = ds.NewKey("/routed-echo/routing-table")
+
+// saveInterval is how often the routing table is saved while the host runs.
+const saveInterval = time.Minute
+
+// openDatastore opens the badger datastore in dir, creating it if needed.
+// Without a directory, the records are kept in memory and lost on exit.
+func openDatastore(dir string) (ds.Batching, error) {
+	if dir == "" {
+		return dsync.MutexWrap(ds.NewMapDatastore()), nil
+	}
+	d, err := badger.NewDatastore(dir, &badger.DefaultOptions)
+	if err != nil {
+		return nil, fmt.Errorf("datastore %s: %w", dir, err)
+	}
+	return d, nil
+}
+
+// loadRoutingTable returns the routing table peers saved by the previous run,
+// as bootstrap peers.
+func loadRoutingTable(d ds.Datastore) ([]*bootstrapPeer, error) {
+	data, err := d.Get(routingTableKey)
+	if err == ds.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pis, err := parsePeers(strings.Fields(string(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	// parsePeers returns one AddrInfo per address, merge them by peer.
+	var peers []*bootstrapPeer
+	byID := make(map[peer.ID]*bootstrapPeer)
+	for _, pi := range pis {
+		bp := byID[pi.ID]
+		if bp == nil {
+			bp = &bootstrapPeer{AddrInfo: peer.AddrInfo{ID: pi.ID}, sources: []string{"routing table"}}
+			byID[pi.ID] = bp
+			peers = append(peers, bp)
+		}
+		bp.Addrs = append(bp.Addrs, pi.Addrs...)
+	}
+	return peers, nil
+}
+
+// saveRoutingTable writes the peers of the routing table to d, with the
+// addresses the peerstore knows for them. An empty routing table doesn't
+// replace the saved one: a host that lost all its peers is better off
+// retrying its old neighbourhood.
+func saveRoutingTable(d ds.Datastore, h host.Host, peers []peer.ID) error {
+	var b strings.Builder
+	for _, p := range peers {
+		addrs := h.Peerstore().Addrs(p)
+		for _, c := range h.Network().ConnsToPeer(p) {
+			addrs = append(addrs, c.RemoteMultiaddr())
+		}
+		for _, a := range uniqueAddrs(addrs) {
+			fmt.Fprintf(&b, "%s/p2p/%s\n", a, p.Pretty())
+		}
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	if err := d.Put(routingTableKey, []byte(b.String())); err != nil {
+		return err
+	}
+	return d.Sync(routingTableKey)
+}
+
+// keepSavingRoutingTable saves the routing table every saveInterval until
+// ctx is done.
+func keepSavingRoutingTable(ctx context.Context, d ds.Datastore, h host.Host, peers func() []peer.ID) {
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := saveRoutingTable(d, h, peers()); err != nil {
+				log.Printf("datastore: can't save the routing table: %s\n", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/routing"
+	swarm "github.com/libp2p/go-libp2p-swarm"
+)
+
+// lookupTrace is what we saw of a peer lookup, and of the dial that follows.
+type lookupTrace struct {
+	Target string `json:"target"`
+	// Queried lists the DHT peers we asked, in the order we asked them.
+	Queried []*queriedPeer `json:"queried"`
+	// Path goes from a peer of our routing table to the first peer that
+	// returned the target, Hops is its length.
+	Path []string `json:"path,omitempty"`
+	Hops int      `json:"hops"`
+	// Addrs are the target addresses the DHT peers returned.
+	Addrs        []string      `json:"addrs"`
+	DialFailures []dialFailure `json:"dialFailures"`
+	Connected    string        `json:"connectedAddr,omitempty"`
+	// FoundMs is when the target was first returned, TotalMs when the
+	// lookup and the dial were over, in milliseconds since the start.
+	FoundMs float64 `json:"foundMs,omitempty"`
+	TotalMs float64 `json:"totalMs"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// queriedPeer is a DHT peer the lookup reached, or tried to.
+type queriedPeer struct {
+	ID string `json:"id"`
+	// Hop is 1 for peers from our routing table, or one more than the hop
+	// of the peer that returned it.
+	Hop            int     `json:"hop"`
+	LearnedFrom    string  `json:"learnedFrom,omitempty"`
+	Answered       bool    `json:"answered"`
+	RTTMs          float64 `json:"rttMs,omitempty"`
+	CloserPeers    int     `json:"closerPeers"`
+	ReturnedTarget bool    `json:"returnedTarget"`
+	Error          string  `json:"error,omitempty"`
+
+	sent time.Time
+}
+
+// dialFailure is a dial that failed, to a DHT peer during the lookup or to
+// one of the target's addresses afterwards.
+type dialFailure struct {
+	Peer  string `json:"peer"`
+	Addr  string `json:"addr,omitempty"`
+	Error string `json:"error"`
+}
+
+// traceFindPeer connects to target through the routed host h, which looks
+// it up in the DHT, and records the lookup's query events. Addresses the
+// peerstore already has for target are forgotten first, otherwise h would
+// dial them without a lookup.
+func traceFindPeer(ctx context.Context, h host.Host, target peer.ID) *lookupTrace {
+	t := &lookupTrace{
+		Target:       target.Pretty(),
+		Queried:      []*queriedPeer{},
+		Addrs:        []string{},
+		DialFailures: []dialFailure{},
+	}
+	if h.Network().Connectedness(target) == network.Connected {
+		t.Error = "already connected, there is nothing to look up"
+		return t
+	}
+	h.Peerstore().ClearAddrs(target)
+
+	ctx, cancel := context.WithCancel(ctx)
+	ectx, events := routing.RegisterForQueryEvents(ctx)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t.record(events, target, start)
+	}()
+
+	err := h.Connect(ectx, peer.AddrInfo{ID: target})
+	elapsed := time.Since(start)
+	cancel()
+	wg.Wait()
+
+	t.TotalMs = millis(elapsed)
+	if err != nil {
+		t.Error = err.Error()
+		var derr *swarm.DialError
+		if errors.As(err, &derr) {
+			t.Error = fmt.Sprintf("failed to dial %s", target.Pretty())
+			if derr.Cause != nil {
+				t.Error += ": " + derr.Cause.Error()
+			}
+			for _, te := range derr.DialErrors {
+				t.DialFailures = append(t.DialFailures, dialFailure{
+					Peer:  target.Pretty(),
+					Addr:  te.Address.String(),
+					Error: te.Cause.Error(),
+				})
+			}
+		}
+	}
+	for _, c := range h.Network().ConnsToPeer(target) {
+		t.Connected = c.RemoteMultiaddr().String()
+		break
+	}
+	return t
+}
+
+// record consumes the query events until the channel is closed.
+func (t *lookupTrace) record(events <-chan *routing.QueryEvent, target peer.ID, start time.Time) {
+	byID := make(map[peer.ID]*queriedPeer)
+	learnedFrom := make(map[peer.ID]peer.ID)
+	get := func(p peer.ID) *queriedPeer {
+		q := byID[p]
+		if q == nil {
+			q = &queriedPeer{ID: p.Pretty(), Hop: 1}
+			if from, ok := learnedFrom[p]; ok {
+				q.LearnedFrom = from.Pretty()
+				q.Hop = byID[from].Hop + 1
+			}
+			byID[p] = q
+			t.Queried = append(t.Queried, q)
+		}
+		return q
+	}
+	seenAddrs := make(map[string]bool)
+
+	for ev := range events {
+		switch ev.Type {
+		case routing.DialingPeer:
+			get(ev.ID)
+		case routing.SendingQuery:
+			get(ev.ID).sent = time.Now()
+		case routing.QueryError:
+			if ev.ID == "" {
+				// The lookup as a whole failed, h.Connect returns the
+				// same error.
+				continue
+			}
+			q := get(ev.ID)
+			q.Error = ev.Extra
+			t.DialFailures = append(t.DialFailures, dialFailure{Peer: q.ID, Error: ev.Extra})
+		case routing.PeerResponse:
+			q := get(ev.ID)
+			q.Answered = true
+			if !q.sent.IsZero() {
+				q.RTTMs = millis(time.Since(q.sent))
+			}
+			q.CloserPeers = len(ev.Responses)
+			for _, pi := range ev.Responses {
+				if _, ok := learnedFrom[pi.ID]; !ok {
+					learnedFrom[pi.ID] = ev.ID
+				}
+				if pi.ID != target {
+					continue
+				}
+				q.ReturnedTarget = true
+				if t.Path == nil {
+					t.FoundMs = millis(time.Since(start))
+					for p := ev.ID; ; {
+						t.Path = append([]string{p.Pretty()}, t.Path...)
+						from, ok := learnedFrom[p]
+						if !ok || byID[p].Hop == 1 {
+							break
+						}
+						p = from
+					}
+					t.Hops = len(t.Path)
+				}
+				for _, a := range pi.Addrs {
+					if !seenAddrs[a.String()] {
+						seenAddrs[a.String()] = true
+						t.Addrs = append(t.Addrs, a.String())
+					}
+				}
+			}
+		}
+	}
+}
+
+// WriteTo prints the trace for people.
+func (t *lookupTrace) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	if t.Error == "" {
+		fmt.Fprintf(&b, "findpeer %s: connected in %s\n", t.Target, fmtMillis(t.TotalMs))
+	} else {
+		fmt.Fprintf(&b, "findpeer %s: failed after %s: %s\n", t.Target, fmtMillis(t.TotalMs), t.Error)
+	}
+
+	if t.Path != nil {
+		fmt.Fprintf(&b, "\nfound after %s, %d hops: %s\n", fmtMillis(t.FoundMs), t.Hops, strings.Join(t.Path, " -> "))
+	} else if len(t.Queried) > 0 {
+		b.WriteString("\nno DHT peer returned the target\n")
+	}
+
+	if len(t.Queried) > 0 {
+		fmt.Fprintf(&b, "\nqueried %d DHT peers:\n", len(t.Queried))
+		queried := append([]*queriedPeer(nil), t.Queried...)
+		sort.SliceStable(queried, func(i, j int) bool { return queried[i].Hop < queried[j].Hop })
+		for _, q := range queried {
+			var status string
+			switch {
+			case q.ID == t.Target && q.Error == "":
+				// Dialing the target ends the lookup, whether it speaks
+				// the DHT protocol or not.
+				status = "the target, reached"
+			case q.Error != "":
+				status = "dial failed"
+			case !q.Answered:
+				status = "no answer"
+			default:
+				status = fmt.Sprintf("answered in %s with %d peers", fmtMillis(q.RTTMs), q.CloserPeers)
+				if q.ReturnedTarget {
+					status += ", target included"
+				}
+			}
+			fmt.Fprintf(&b, "  hop %d  %s  %s\n", q.Hop, q.ID, status)
+		}
+	}
+
+	if len(t.Addrs) > 0 {
+		b.WriteString("\naddresses returned:\n")
+		for _, a := range t.Addrs {
+			fmt.Fprintf(&b, "  %s\n", a)
+		}
+	}
+
+	if len(t.DialFailures) > 0 {
+		b.WriteString("\nfailed dials:\n")
+		for _, d := range t.DialFailures {
+			if d.Addr != "" {
+				fmt.Fprintf(&b, "  %s %s: %s\n", d.Peer, d.Addr, d.Error)
+			} else {
+				fmt.Fprintf(&b, "  %s: %s\n", d.Peer, strings.Replace(d.Error, "\n", "\n    ", -1))
+			}
+		}
+	}
+
+	if t.Connected != "" {
+		fmt.Fprintf(&b, "\nconnected to %s\n", t.Connected)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func fmtMillis(ms float64) string {
+	return time.Duration(ms * float64(time.Millisecond)).Round(100 * time.Microsecond).String()
+}
@@ -4,102 +4,215 @@ import (
 	"bufio"
 	"context"
 	"crypto/rand"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	mrand "math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/libp2p/go-libp2p"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
 
-	ds "github.com/ipfs/go-datastore"
-	dsync "github.com/ipfs/go-datastore/sync"
 	golog "github.com/ipfs/go-log/v2"
 
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	rhost "github.com/libp2p/go-libp2p/p2p/host/routed"
 	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
 )
 
-// makeRoutedHost creates a LibP2P host with a random peer ID listening on the
-// given multiaddress. It will use secio if secio is true. It will bootstrap using the
-// provided PeerInfo
-func makeRoutedHost(listenPort int, randseed int64, bootstrapPeers []peer.AddrInfo, globalFlag string) (host.Host, error) {
-
-	// If the seed is zero, use real cryptographic randomness. Otherwise, use a
-	// deterministic randomness source to make generated keys stay the same
-	// across multiple runs
-	var r io.Reader
-	if randseed == 0 {
-		r = rand.Reader
-	} else {
-		r = mrand.New(mrand.NewSource(randseed))
-	}
-
-	// Generate a key pair for this host. We will use it at least
-	// to obtain a valid host ID.
-	priv, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, r)
-	if err != nil {
-		return nil, err
-	}
+// makeRoutedHost creates a LibP2P host as cfg says. Unless cfg names an
+// identity, the host gets a random peer ID, derived from randseed if it
+// isn't zero. It keeps the DHT records in the datastore in dataDir,
+// or in memory if dataDir is empty. It will rejoin the routing table saved
+// there by the previous run, or else bootstrap using the peers found from
+// the sources in bcfg.
+//
+// Besides the host and its DHT, it returns a function that saves the routing
+// table, and closes the DHT, the host and the datastore.
+func makeRoutedHost(cfg *hostconfig.Config, randseed int64, dataDir string, bcfg bootstrapConfig) (_ host.Host, _ *dht.IpfsDHT, _ func(), err error) {
+	if cfg.Identity.File == "" && cfg.Identity.Key == nil {
+		// If the seed is zero, use real cryptographic randomness. Otherwise,
+		// use a deterministic randomness source to make generated keys stay
+		// the same across multiple runs
+		var r io.Reader
+		if randseed == 0 {
+			r = rand.Reader
+		} else {
+			r = mrand.New(mrand.NewSource(randseed))
+		}
 
-	opts := []libp2p.Option{
-		libp2p.ListenAddrStrings(fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", listenPort)),
-		libp2p.Identity(priv),
-		libp2p.DefaultTransports,
-		libp2p.DefaultMuxers,
-		libp2p.DefaultSecurity,
-		libp2p.NATPortMap(),
+		// Generate a key pair for this host. We will use it at least
+		// to obtain a valid host ID.
+		priv, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, r)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		cfg.Identity.Key = priv
 	}
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Whatever was started is stopped again if we fail half way.
+	var (
+		basicHost *hostconfig.Host
+		dstore    ds.Batching
+		kad       *dht.IpfsDHT
+	)
+	defer func() {
+		if err == nil {
+			return
+		}
+		cancel()
+		if kad != nil {
+			kad.Close()
+		}
+		if basicHost != nil {
+			basicHost.Close()
+		}
+		if dstore != nil {
+			dstore.Close()
+		}
+	}()
 
-	basicHost, err := libp2p.New(ctx, opts...)
-	if err != nil {
-		return nil, err
+	if basicHost, err = hostconfig.New(ctx, cfg); err != nil {
+		return nil, nil, nil, err
 	}
 
-	// Construct a datastore (needed by the DHT). This is just a simple, in-memory thread-safe datastore.
-	dstore := dsync.MutexWrap(ds.NewMapDatastore())
+	// Construct a datastore (needed by the DHT). Provider records, and the
+	// routing table saved below, outlive the host if it is on disk.
+	if dstore, err = openDatastore(dataDir); err != nil {
+		return nil, nil, nil, err
+	}
 
-	// Make the DHT
-	dht := dht.NewDHT(ctx, basicHost, dstore)
+	// Make the DHT. On a private network, it would only ever act as a
+	// client, a bootstrap host must answer the other hosts' queries.
+	mode := dht.ModeAuto
+	if bcfg.Self {
+		mode = dht.ModeServer
+	}
+	if kad, err = dht.New(ctx, basicHost, dht.Datastore(dstore), dht.Mode(mode)); err != nil {
+		return nil, nil, nil, err
+	}
 
 	// Make the routed host
-	routedHost := rhost.Wrap(basicHost, dht)
+	routedHost := rhost.Wrap(basicHost, kad)
 
-	// connect to the chosen ipfs nodes
-	err = bootstrapConnect(ctx, routedHost, bootstrapPeers)
+	// The neighbourhood we had before a restart is as good a start as the
+	// bootstrap peers, and doesn't put any load on them.
+	rejoined := false
+	previous, err := loadRoutingTable(dstore)
 	if err != nil {
-		return nil, err
+		log.Printf("datastore: can't load the routing table: %s\n", err)
+	}
+	if len(previous) > 0 {
+		report, err := bootstrapConnect(ctx, routedHost, previous, bcfg.Min)
+		report.Log()
+		rejoined = err == nil && len(report.Connected) > 0
+		if !rejoined {
+			log.Println("can't rejoin the previous routing table, bootstrapping")
+		}
+	}
+
+	if !rejoined {
+		// connect to the bootstrap peers, and remember those that worked
+		bootstrapPeers := gatherBootstrapPeers(ctx, routedHost, bcfg)
+		report, err := bootstrapConnect(ctx, routedHost, bootstrapPeers, bcfg.Min)
+		report.Log()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err := saveKnownGood(bcfg.CacheFile, routedHost, report.Connected); err != nil {
+			log.Printf("bootstrap: can't save the peer cache: %s\n", err)
+		}
 	}
 
 	// Bootstrap the host
-	err = dht.Bootstrap(ctx)
-	if err != nil {
-		return nil, err
+	if err = kad.Bootstrap(ctx); err != nil {
+		return nil, nil, nil, err
 	}
 
+	// The peers we connected to only join the routing table once they said
+	// they speak the DHT protocol, lookups made before that fail.
+	if len(routedHost.Network().Peers()) > 0 && !waitForRoutingTable(ctx, kad.RoutingTable().Size, routingTableWait) {
+		log.Println("none of our peers runs a DHT server, looking up peers will fail")
+	}
+
+	peers := kad.RoutingTable().ListPeers
+	go keepSavingRoutingTable(ctx, dstore, routedHost, peers)
+	shutdown := func() {
+		cancel()
+		if err := saveRoutingTable(dstore, routedHost, peers()); err != nil {
+			log.Printf("datastore: can't save the routing table: %s\n", err)
+		}
+		kad.Close()
+		basicHost.Close()
+		if err := dstore.Close(); err != nil {
+			log.Printf("datastore: %s\n", err)
+		}
+	}
+
+	return routedHost, kad, shutdown, nil
+}
+
+// printHints prints the addresses of h, and the command line to start the
+// next host with: one joining h if it is the bootstrap peer, or else one
+// dialing it. hintFlags are added to the latter.
+func printHints(h host.Host, self bool, listenPort int, hintFlags string) {
 	// Build host multiaddress
-	hostAddr, _ := ma.NewMultiaddr(fmt.Sprintf("/ipfs/%s", routedHost.ID().Pretty()))
+	hostAddr, _ := ma.NewMultiaddr(fmt.Sprintf("/ipfs/%s", h.ID().Pretty()))
 
 	// Now we can build a full multiaddress to reach this host
 	// by encapsulating both addresses:
-	// addr := routedHost.Addrs()[0]
-	addrs := routedHost.Addrs()
+	// addr := h.Addrs()[0]
+	addrs := h.Addrs()
 	log.Println("I can be reached at:")
 	for _, addr := range addrs {
 		log.Println(addr.Encapsulate(hostAddr))
 	}
 
-	log.Printf("Now run \"./routed-echo -l %d -d %s%s\" on a different terminal\n", listenPort+1, routedHost.ID().Pretty(), globalFlag)
+	if self {
+		// The others only need our address to join, pick the one that works
+		// on this machine.
+		self := addrs[0]
+		for _, addr := range addrs {
+			if manet.IsIPLoopback(addr) {
+				self = addr
+				break
+			}
+		}
+		log.Printf("Now run \"./routed-echo -l %d -bootstrap %s\" on a different terminal\n", listenPort+1, self.Encapsulate(hostAddr))
+	} else {
+		log.Printf("Now run \"./routed-echo -l %d -d %s%s\" on a different terminal\n", listenPort+1, h.ID().Pretty(), hintFlags)
+	}
+}
 
-	return routedHost, nil
+// options are the command line flags, besides those configuring the
+// bootstrap.
+type options struct {
+	listenPort int
+	target     string
+	findPeer   string
+	jsonOut    bool
+	provide    string
+	fetch      string
+	output     string
+	seed       int64
+	global     bool
+	self       bool
+	dataDir    string
+	configFile string
 }
 
 func main() {
@@ -109,32 +222,64 @@ func main() {
 	golog.SetAllLoggers(golog.LevelInfo) // Change to INFO for extra info
 
 	// Parse options from the command line
-	listenF := flag.Int("l", 0, "wait for incoming connections")
-	target := flag.String("d", "", "target peer to dial")
-	seed := flag.Int64("seed", 0, "set random seed for id generation")
-	global := flag.Bool("global", false, "use global ipfs peers for bootstrapping")
+	var opts options
+	flag.IntVar(&opts.listenPort, "l", 0, "wait for incoming connections")
+	flag.StringVar(&opts.target, "d", "", "target peer to dial")
+	flag.StringVar(&opts.findPeer, "findpeer", "", "look up this peer in the DHT, dial it and report how it went, instead of echoing")
+	flag.BoolVar(&opts.jsonOut, "json", false, "print the -findpeer report as JSON")
+	flag.StringVar(&opts.provide, "provide", "", "announce this file on the DHT and serve it, along with echoing")
+	flag.StringVar(&opts.fetch, "fetch", "", "download the file with this CID from its providers, instead of echoing")
+	flag.StringVar(&opts.output, "o", "", "write the -fetch file there instead of to stdout")
+	flag.Int64Var(&opts.seed, "seed", 0, "set random seed for id generation")
+	flag.BoolVar(&opts.global, "global", false, "use global ipfs peers for bootstrapping")
+	flag.BoolVar(&opts.self, "bootstrap-self", false, "be the bootstrap peer and DHT server of a local network, other hosts join it with -bootstrap")
+	var bcfg bootstrapConfig
+	flag.StringVar(&bcfg.Addrs, "bootstrap", "", "comma separated multiaddrs of bootstrap peers, also read from $"+BootstrapEnv)
+	flag.StringVar(&bcfg.File, "bootstrap-file", "", "read bootstrap peers from this file, one multiaddr per line")
+	flag.StringVar(&bcfg.CacheFile, "peer-cache", defaultCacheFile(), "remember the bootstrap peers that worked in this file, empty to disable")
+	flag.DurationVar(&bcfg.MDNS, "mdns", 3*time.Second, "how long to look for bootstrap peers on the local network, 0 to disable")
+	flag.IntVar(&bcfg.Min, "bootstrap-min", 1, "bootstrap peers that must be reached, 0 to start alone")
+	flag.StringVar(&opts.dataDir, "datastore", "", "keep the DHT records and routing table in a badger datastore in this directory, instead of in memory")
+	flag.StringVar(&opts.configFile, "config", "", "take the host settings from this YAML or JSON file; -l fills in missing listen addresses, and -seed a missing identity")
 	flag.Parse()
 
-	if *listenF == 0 {
+	if opts.listenPort == 0 {
 		log.Fatal("Please provide a port to bind on with -l")
 	}
 
-	// Make a host that listens on the given multiaddress
-	var bootstrapPeers []peer.AddrInfo
-	var globalFlag string
-	if *global {
-		log.Println("using global bootstrap")
-		bootstrapPeers = IPFS_PEERS
-		globalFlag = " -global"
-	} else {
-		log.Println("using local bootstrap")
-		bootstrapPeers = getLocalPeerInfo()
-		globalFlag = ""
+	if err := run(opts, bcfg); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// run makes the host and does what the flags ask for. The routing table is
+// saved, and the host and datastore closed, however it returns.
+func run(opts options, bcfg bootstrapConfig) error {
+	// Without a configuration file, the host listens on -l, and asks the
+	// router to forward the port.
+	cfg := &hostconfig.Config{NAT: hostconfig.NAT{PortMap: true}}
+	if opts.configFile != "" {
+		var err error
+		if cfg, err = hostconfig.Load(opts.configFile); err != nil {
+			return err
+		}
+		if cfg.Routing != "" {
+			return fmt.Errorf("%s: routed-echo makes its own DHT, on the datastore, remove routing", opts.configFile)
+		}
+	}
+
+	if len(cfg.ListenAddrs) == 0 {
+		cfg.ListenAddrs = []string{fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", opts.listenPort)}
 	}
-	ha, err := makeRoutedHost(*listenF, *seed, bootstrapPeers, globalFlag)
+
+	// Make a host that listens on the given multiaddress
+	hintFlags := bcfg.choose(opts.self, opts.global)
+	ha, kad, shutdown, err := makeRoutedHost(cfg, opts.seed, opts.dataDir, bcfg)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer shutdown()
+	printHints(ha, bcfg.Self, opts.listenPort, hintFlags)
 
 	// Set a stream handler on host A. /echo/1.0.0 is
 	// a user-defined protocol name.
@@ -148,15 +293,73 @@ func main() {
 		}
 	})
 
-	if *target == "" {
+	if opts.provide != "" {
+		c, err := provideFile(context.Background(), ha, kad, opts.provide)
+		if err != nil {
+			return err
+		}
+		log.Printf("providing %s as %s\n", opts.provide, c)
+		log.Printf("Now run \"./routed-echo -l %d -fetch %s%s\" on a different terminal\n", opts.listenPort+1, c, hintFlags)
+	}
+
+	if opts.fetch != "" {
+		c, err := cid.Decode(opts.fetch)
+		if err != nil {
+			return err
+		}
+		data, err := fetchFile(context.Background(), ha, kad, c)
+		if err != nil {
+			return err
+		}
+		if opts.output == "" {
+			_, err = os.Stdout.Write(data)
+		} else {
+			err = ioutil.WriteFile(opts.output, data, 0644)
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("fetched %s, %d bytes\n", c, len(data))
+		return nil
+	}
+
+	if opts.findPeer != "" {
+		peerid, err := peer.IDB58Decode(opts.findPeer)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		trace := traceFindPeer(ctx, ha, peerid)
+		cancel()
+		if opts.jsonOut {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(trace)
+		} else {
+			_, err = trace.WriteTo(os.Stdout)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+		if trace.Error != "" {
+			return fmt.Errorf("can't reach %s: %s", peerid.Pretty(), trace.Error)
+		}
+		return nil
+	}
+
+	if opts.target == "" {
 		log.Println("listening for connections")
-		select {} // hang forever
+		// Wait for Ctrl-C, and save the routing table before leaving.
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		<-c
+		return nil
 	}
 	/**** This is where the listener code ends ****/
 
-	peerid, err := peer.IDB58Decode(*target)
+	peerid, err := peer.IDB58Decode(opts.target)
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
 
 	// peerinfo := peer.AddrInfo{ID: peerid}
@@ -165,22 +368,22 @@ func main() {
 	// it should be handled on host A by the handler we set above because
 	// we use the same /echo/1.0.0 protocol
 	s, err := ha.NewStream(context.Background(), peerid, "/echo/1.0.0")
-
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
 
 	_, err = s.Write([]byte("Hello, world!\n"))
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
 
 	out, err := ioutil.ReadAll(s)
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
 
 	log.Printf("read reply: %q\n", out)
+	return nil
 }
 
 // doEcho reads a line of data from a stream and writes it back
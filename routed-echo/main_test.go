@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+
+	"github.com/libp2p/go-libp2p-examples/hostconfig"
+)
+
+// TestBootstrapSelf starts a bootstrap peer and two hosts joining it, all on
+// loopback, and checks that one host finds the other by peer ID through the
+// DHT of the bootstrap peer, with no other source of addresses.
+func TestBootstrapSelf(t *testing.T) {
+	t.Setenv(BootstrapEnv, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	start := func(bcfg bootstrapConfig) (host.Host, *dht.IpfsDHT) {
+		t.Helper()
+		cfg := &hostconfig.Config{ListenAddrs: []string{"/ip4/127.0.0.1/tcp/0"}}
+		h, kad, shutdown, err := makeRoutedHost(cfg, 0, "", bcfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(shutdown)
+		return h, kad
+	}
+
+	var server bootstrapConfig
+	server.choose(true, false)
+	bootstrap, _ := start(server)
+
+	join := bootstrapConfig{Min: 1}
+	for _, a := range bootstrap.Addrs() {
+		join.Addrs += a.String() + "/p2p/" + bootstrap.ID().Pretty() + ","
+	}
+	if hints := join.choose(false, false); hints == "" {
+		t.Fatal("no -bootstrap hint for the next host")
+	}
+	a, _ := start(join)
+	b, kadB := start(join)
+
+	a.SetStreamHandler("/echo/1.0.0", func(s network.Stream) {
+		if err := doEcho(s); err != nil {
+			s.Reset()
+			return
+		}
+		s.Close()
+	})
+
+	if len(b.Peerstore().Addrs(a.ID())) > 0 {
+		t.Fatal("b knows the addresses of a before looking it up")
+	}
+	pi, err := kadB.FindPeer(ctx, a.ID())
+	if err != nil {
+		t.Fatalf("looking up a: %s", err)
+	}
+	if len(pi.Addrs) == 0 {
+		t.Fatal("the DHT returned no addresses for a")
+	}
+
+	// The routed host looks the peer up itself, the ID is enough.
+	if err := b.Connect(ctx, peer.AddrInfo{ID: a.ID()}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := b.NewStream(ctx, a.ID(), "/echo/1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Write([]byte("Hello, world!\n")); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := bufio.NewReader(s).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Hello, world!\n" {
+		t.Fatalf("read reply %q", reply)
+	}
+}